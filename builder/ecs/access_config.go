@@ -6,30 +6,137 @@
 package ecs
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
-	"runtime"
+	"strings"
 	"time"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/endpoints"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	"github.com/hashicorp/packer-plugin-alicloud/version"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
-	"github.com/mitchellh/go-homedir"
+	"golang.org/x/net/http/httpproxy"
 )
 
+// The "AssumeRoleConfig" object configures the RAM role which is assumed with
+// the credentials, for all the API requests of the plugin. The credentials of
+// the role are refreshed before they expire.
+type AssumeRoleConfig struct {
+	// The ARN of the RAM role to assume.
+	RoleArn string `mapstructure:"role_arn" required:"true"`
+	// The name of the role session. Defaults to `packer-` followed by a random
+	// suffix.
+	SessionName string `mapstructure:"session_name" required:"false"`
+	// The external ID which is required by the trust policy of the role.
+	ExternalId string `mapstructure:"external_id" required:"false"`
+	// An inline policy in JSON which further restricts the permissions of the
+	// role session.
+	Policy string `mapstructure:"policy" required:"false"`
+	// The duration of the role session in seconds, from 900 to 43200, which
+	// must not exceed the maximum session duration of the role. Defaults to
+	// 3600.
+	SessionExpiration int `mapstructure:"session_expiration" required:"false"`
+}
+
+func (r *AssumeRoleConfig) Prepare() []error {
+	if r.RoleArn == "" {
+		if r.SessionName != "" || r.ExternalId != "" || r.Policy != "" || r.SessionExpiration != 0 {
+			return []error{fmt.Errorf("role_arn must be set in assume_role.")}
+		}
+		return nil
+	}
+
+	var errs []error
+	if r.SessionExpiration == 0 {
+		r.SessionExpiration = DefaultRoleSessionExpiration
+	}
+	if r.SessionExpiration < MinRoleSessionExpiration || r.SessionExpiration > MaxRoleSessionExpiration {
+		errs = append(errs, fmt.Errorf("session_expiration of assume_role must be between %d and %d seconds.",
+			MinRoleSessionExpiration, MaxRoleSessionExpiration))
+	}
+	if r.Policy != "" && !json.Valid([]byte(r.Policy)) {
+		errs = append(errs, fmt.Errorf("policy of assume_role must be a valid JSON document."))
+	}
+
+	return errs
+}
+
+// The "EndpointsConfig" object overrides the endpoints of the services, e.g.
+// to use the VPC endpoints from a VPC without internet access, or the
+// endpoints of finance or government clouds. The endpoints are host names,
+// such as `ecs-vpc.cn-hangzhou.aliyuncs.com`.
+type EndpointsConfig struct {
+	// The endpoint of ECS.
+	Ecs string `mapstructure:"ecs" required:"false"`
+	// The endpoint of VPC.
+	Vpc string `mapstructure:"vpc" required:"false"`
+	// The endpoint of OSS, which is used by the import post-processor. It is
+	// reached over HTTPS unless it starts with `http://`.
+	Oss string `mapstructure:"oss" required:"false"`
+	// The endpoint of RAM.
+	Ram string `mapstructure:"ram" required:"false"`
+	// The endpoint of STS, which is used to assume RAM roles.
+	Sts string `mapstructure:"sts" required:"false"`
+	// The endpoint of KMS, which is used to check the KMS keys in the
+	// preflight validation.
+	Kms string `mapstructure:"kms" required:"false"`
+	// Whether to use the internal endpoint of OSS of the region, e.g.
+	// `oss-cn-hangzhou-internal.aliyuncs.com`, which is only reachable from
+	// the region. It is ignored if `oss` is set. The default value is false.
+	OssInternal bool `mapstructure:"oss_internal" required:"false"`
+}
+
+// OssEndpoint returns the OSS endpoint of the region. The endpoint set by the
+// user keeps its scheme.
+func (e *EndpointsConfig) OssEndpoint(region string) string {
+	if e.Oss != "" {
+		return e.Oss
+	}
+
+	if !strings.HasPrefix(region, "oss-") {
+		region = "oss-" + region
+	}
+	if e.OssInternal {
+		return region + "-internal.aliyuncs.com"
+	}
+	return region + ".aliyuncs.com"
+}
+
 // Config of alicloud
 type AlicloudAccessConfig struct {
-	// Alicloud access key must be provided unless `profile` is set, but it can
-	// also be sourced from the `ALICLOUD_ACCESS_KEY` environment variable.
+	// Alicloud access key must be provided unless `profile` is set or the
+	// credentials are found by the default credential chain, but it can also
+	// be sourced from the `ALICLOUD_ACCESS_KEY` environment variable. The
+	// default credential chain looks for the credentials in the
+	// `ALIBABA_CLOUD_ACCESS_KEY_ID`, `ALIBABA_CLOUD_ACCESS_KEY_SECRET` and
+	// `ALIBABA_CLOUD_SECURITY_TOKEN` environment variables, then the OIDC
+	// token of RAM Roles for Service Accounts (RRSA) in ACK pods, set by
+	// `ALIBABA_CLOUD_ROLE_ARN`, `ALIBABA_CLOUD_OIDC_PROVIDER_ARN` and
+	// `ALIBABA_CLOUD_OIDC_TOKEN_FILE`, then the `ALIBABA_CLOUD_CREDENTIALS_URI`
+	// environment variable, then the `ALIBABA_CLOUD_PROFILE` or `default`
+	// profile of the `~/.alibabacloud/credentials` file, and finally the RAM
+	// role of the ECS instance which runs Packer, which is set by
+	// `ALIBABA_CLOUD_ECS_METADATA` or detected from the instance metadata.
 	AlicloudAccessKey string `mapstructure:"access_key" required:"true"`
-	// Alicloud secret key must be provided unless `profile` is set, but it can
-	// also be sourced from the `ALICLOUD_SECRET_KEY` environment variable.
+	// Alicloud secret key must be provided unless `profile` is set or the
+	// credentials are found by the default credential chain, but it can also
+	// be sourced from the `ALICLOUD_SECRET_KEY` environment variable.
 	AlicloudSecretKey string `mapstructure:"secret_key" required:"true"`
 	// Alicloud region must be provided unless `profile` is set, but it can
-	// also be sourced from the `ALICLOUD_REGION` environment variable.
+	// also be sourced from the `ALICLOUD_REGION` or `ALIBABA_CLOUD_REGION_ID`
+	// environment variable.
 	AlicloudRegion string `mapstructure:"region" required:"true"`
 	// Alicloud RamRole must be provided for EcsRamRole mode unless `profile` is set.
 	AlicloudRamRole string `mapstructure:"ram_role_name" required:"true"`
@@ -44,19 +151,62 @@ type AlicloudAccessConfig struct {
 	// value is false.
 	AlicloudSkipImageValidation bool `mapstructure:"skip_image_validation" required:"false"`
 	// Alicloud profile must be set unless `access_key` is set; it can also be
-	// sourced from the `ALICLOUD_PROFILE` environment variable.
+	// sourced from the `ALICLOUD_PROFILE` environment variable. The profile is
+	// looked up in the Alibaba Cloud CLI configuration file, then in the
+	// `~/.alibabacloud/credentials` file. The `AK`, `StsToken`, `EcsRamRole`,
+	// `RamRoleArn`, `ChainableRamRoleArn`, `CredentialsURI` and `OIDC` modes of
+	// the CLI are supported.
 	AlicloudProfile string `mapstructure:"profile" required:"false"`
-	// Alicloud shared credentials file path. If this file exists, access and
-	// secret keys will be read from this file.
+	// Alicloud shared credentials file path, the configuration file of the
+	// Alibaba Cloud CLI, `~/.aliyun/config.json` by default. If this file
+	// exists, the profile will be read from this file.
 	AlicloudSharedCredentialsFile string `mapstructure:"shared_credentials_file" required:"false"`
 	// STS access token, can be set through template or by exporting as
 	// environment variable such as `export SECURITY_TOKEN=value`.
 	SecurityToken string `mapstructure:"security_token" required:"false"`
 	// This option is useful if you use a cloud provider whose API is
 	// compatible with aliyun ECS. Specify another endpoint with this option.
+	// It is the same as `ecs` of `endpoints`.
 	CustomEndpointEcs string `mapstructure:"custom_endpoint_ecs" required:"false"`
-
-	client *ClientWrapper
+	// The endpoints of the services. See the
+	// [Endpoints](#endpoints-configuration) section below. For example, to
+	// use the VPC endpoints:
+	//
+	// ```hcl
+	// endpoints {
+	//   ecs          = "ecs-vpc.cn-hangzhou.aliyuncs.com"
+	//   vpc          = "vpc-vpc.cn-hangzhou.aliyuncs.com"
+	//   oss_internal = true
+	// }
+	// ```
+	Endpoints EndpointsConfig `mapstructure:"endpoints" required:"false"`
+	// The proxy of the HTTP requests to the APIs. Defaults to the
+	// `HTTP_PROXY` environment variable.
+	HttpProxy string `mapstructure:"http_proxy" required:"false"`
+	// The proxy of the HTTPS requests to the APIs. Defaults to the
+	// `HTTPS_PROXY` environment variable.
+	HttpsProxy string `mapstructure:"https_proxy" required:"false"`
+	// A comma separated list of the hosts which are requested without the
+	// proxy. Defaults to the `NO_PROXY` environment variable.
+	NoProxy string `mapstructure:"no_proxy" required:"false"`
+	// The path of a PEM file of the CA certificates which the HTTPS endpoints
+	// are verified with, instead of the CA certificates of the system.
+	CABundle string `mapstructure:"ca_bundle" required:"false"`
+	// The RAM role to assume with the credentials. See the
+	// [Assume Role](#assume-role-configuration) section below. For example:
+	//
+	// ```hcl
+	// assume_role {
+	//   role_arn           = "acs:ram::123456789012:role/packer"
+	//   external_id        = "packer"
+	//   session_expiration = 7200
+	// }
+	// ```
+	AssumeRole AssumeRoleConfig `mapstructure:"assume_role" required:"false"`
+
+	client  *ClientWrapper
+	signer  auth.Signer
+	session sessionCredentialsProvider
 }
 
 const Packer = "HashiCorp-Packer"
@@ -64,8 +214,6 @@ const DefaultRequestReadTimeout = 10 * time.Second
 
 // Client for AlicloudClient
 func (c *AlicloudAccessConfig) Client() (*ClientWrapper, error) {
-	var client *ecs.Client
-	var err error
 	if c.client != nil {
 		return c.client, nil
 	}
@@ -73,55 +221,224 @@ func (c *AlicloudAccessConfig) Client() (*ClientWrapper, error) {
 		c.SecurityToken = os.Getenv("SECURITY_TOKEN")
 	}
 
-	var getProviderConfig = func(str string, key string) string {
-		value, err := getConfigFromProfile(c, key)
-		if err == nil && value != nil {
-			str = value.(string)
+	// The profile may set the region and the ECS endpoint, so the mappings
+	// are registered once the credential is resolved
+	credential, err := c.resolveCredential()
+	if err != nil {
+		return nil, fmt.Errorf("Error resolving Alicloud credentials: %s", err)
+	}
+	c.addEndpointMappings()
+
+	if c.AssumeRole.RoleArn != "" {
+		stsClient, err := c.newStsClient(credential)
+		if err != nil {
+			return nil, err
+		}
+		session, err := startAssumeRoleSession(stsClient, c.AssumeRole)
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+		c.signer = newSessionSigner(session)
+	} else {
+		c.session, err = c.newSessionCredentialsProvider(credential)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := credential.(*sessionCredential); ok {
+			c.signer = newSessionSigner(c.session)
 		}
-		return str
+	}
+	if c.signer != nil {
+		credential = signedCredential()
 	}
 
-	c.AlicloudRegion = getProviderConfig(c.AlicloudRegion, "region_id")
-	c.SecurityToken = getProviderConfig(c.SecurityToken, "sts_token")
-	c.CustomEndpointEcs = getProviderConfig(c.CustomEndpointEcs, "endpoint")
+	client, err := ecs.NewClientWithOptions(c.AlicloudRegion, sdk.NewConfig(), credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configureClient(&client.Client); err != nil {
+		return nil, err
+	}
 
-	if c.CustomEndpointEcs != "" && c.AlicloudRegion != "" {
-		_ = endpoints.AddEndpointMapping(c.AlicloudRegion, "Ecs", c.CustomEndpointEcs)
+	vpcClient, err := vpc.NewClientWithOptions(c.AlicloudRegion, sdk.NewConfig(), credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configureClient(&vpcClient.Client); err != nil {
+		return nil, err
 	}
 
-	if c.AlicloudRamRole == "" {
-		c.AlicloudRamRole = getProviderConfig(c.AlicloudRamRole, "ram_role_name")
+	ramClient, err := ram.NewClientWithOptions(c.AlicloudRegion, sdk.NewConfig(), credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configureClient(&ramClient.Client); err != nil {
+		return nil, err
 	}
 
-	if c.AlicloudAccessKey == "" || c.AlicloudSecretKey == "" {
-		c.AlicloudAccessKey = getProviderConfig(c.AlicloudAccessKey, "access_key_id")
-		c.AlicloudSecretKey = getProviderConfig(c.AlicloudSecretKey, "access_key_secret")
+	kmsClient, err := kms.NewClientWithOptions(c.AlicloudRegion, sdk.NewConfig(), credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configureClient(&kmsClient.Client); err != nil {
+		return nil, err
 	}
 
-	if c.AlicloudRamRoleArn == "" || c.AlicloudRamSessionName == "" {
-		c.AlicloudRamRoleArn = getProviderConfig(c.AlicloudRamRole, "ram_role_arn")
-		c.AlicloudRamSessionName = getProviderConfig(c.AlicloudRamSessionName, "ram_session_name")
+	if c.signer != nil {
+		client.SetSigner(c.signer)
+		vpcClient.SetSigner(c.signer)
+		ramClient.SetSigner(c.signer)
+		kmsClient.SetSigner(c.signer)
 	}
 
-	if c.AlicloudRamRole != "" {
-		client, err = ecs.NewClientWithEcsRamRole(c.AlicloudRegion, c.AlicloudRamRole)
-	} else if c.AlicloudRamRoleArn != "" && c.AlicloudRamSessionName != "" {
-		client, err = ecs.NewClientWithRamRoleArn(
-			c.AlicloudRegion, c.AlicloudAccessKey,
-			c.AlicloudSecretKey, c.AlicloudRamRoleArn, c.AlicloudRamSessionName)
-	} else {
-		client, err = ecs.NewClientWithStsToken(c.AlicloudRegion, c.AlicloudAccessKey, c.AlicloudSecretKey, c.SecurityToken)
+	c.client = &ClientWrapper{
+		Client:    client,
+		VpcClient: vpcClient,
+		RamClient: ramClient,
+		KmsClient: kmsClient,
 	}
 
-	if err != nil {
-		return nil, err
+	return c.client, nil
+}
+
+// addEndpointMappings registers the custom endpoints of the services, which
+// are used by all the clients of the region.
+func (c *AlicloudAccessConfig) addEndpointMappings() {
+	if c.Endpoints.Ecs == "" {
+		c.Endpoints.Ecs = c.CustomEndpointEcs
+	}
+	if c.AlicloudRegion == "" {
+		return
 	}
 
+	for product, endpoint := range map[string]string{
+		"Ecs": c.Endpoints.Ecs,
+		"Vpc": c.Endpoints.Vpc,
+		"Ram": c.Endpoints.Ram,
+		"Sts": c.Endpoints.Sts,
+		"Kms": c.Endpoints.Kms,
+	} {
+		if endpoint != "" {
+			_ = endpoints.AddEndpointMapping(c.AlicloudRegion, product, endpoint)
+		}
+	}
+}
+
+// configureClient sets the user agent, the timeout, the proxy and the CA
+// certificates of the client of a service.
+func (c *AlicloudAccessConfig) configureClient(client *sdk.Client) error {
 	client.AppendUserAgent(Packer, version.PluginVersion.FormattedVersion())
 	client.SetReadTimeout(DefaultRequestReadTimeout)
-	c.client = &ClientWrapper{client}
 
-	return c.client, nil
+	if c.HttpProxy != "" {
+		client.SetHttpProxy(c.HttpProxy)
+	}
+	if c.HttpsProxy != "" {
+		client.SetHttpsProxy(c.HttpsProxy)
+	}
+	if c.NoProxy != "" {
+		client.SetNoProxy(c.NoProxy)
+	}
+
+	if c.CABundle != "" {
+		transport, err := c.HTTPTransport()
+		if err != nil {
+			return err
+		}
+		client.SetTransport(transport)
+	}
+
+	return nil
+}
+
+// newStsClient returns the STS client of the credential. It may be created
+// while the credential of a profile is resolved, so the custom endpoints
+// known so far are registered first.
+func (c *AlicloudAccessConfig) newStsClient(credential auth.Credential) (*sts.Client, error) {
+	c.addEndpointMappings()
+
+	session, ok := credential.(*sessionCredential)
+	if ok {
+		credential = signedCredential()
+	}
+
+	stsClient, err := sts.NewClientWithOptions(c.AlicloudRegion, sdk.NewConfig(), credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configureClient(&stsClient.Client); err != nil {
+		return nil, err
+	}
+	if ok {
+		stsClient.SetSigner(newSessionSigner(session.provider))
+	}
+
+	return stsClient, nil
+}
+
+// signedCredential is the credential of the clients whose requests are signed
+// by a signer of the plugin, since the SDK only creates the clients with its
+// own credentials.
+func signedCredential() auth.Credential {
+	return credentials.NewAccessKeyCredential("", "")
+}
+
+// HTTPTransport returns a new transport with the proxy and the CA
+// certificates of the config, for the clients which are not built with the
+// Alibaba Cloud SDK.
+func (c *AlicloudAccessConfig) HTTPTransport() (*http.Transport, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if c.HttpProxy != "" {
+		proxyConfig.HTTPProxy = c.HttpProxy
+	}
+	if c.HttpsProxy != "" {
+		proxyConfig.HTTPSProxy = c.HttpsProxy
+	}
+	if c.NoProxy != "" {
+		proxyConfig.NoProxy = c.NoProxy
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(request *http.Request) (*url.URL, error) {
+		return proxyFunc(request.URL)
+	}
+
+	if c.CABundle != "" {
+		certPool, err := loadCABundle(c.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: certPool}
+	}
+
+	return transport, nil
+}
+
+func (c *AlicloudAccessConfig) httpClient() (*http.Client, error) {
+	transport, err := c.HTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   DefaultRequestReadTimeout,
+		Transport: transport,
+	}, nil
+}
+
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CA bundle: %s", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificate found in CA bundle %s", path)
+	}
+	return certPool, nil
 }
 
 func (c *AlicloudAccessConfig) Prepare(ctx *interpolate.Context) []error {
@@ -133,6 +450,30 @@ func (c *AlicloudAccessConfig) Prepare(ctx *interpolate.Context) []error {
 	if c.AlicloudRegion == "" {
 		c.AlicloudRegion = os.Getenv("ALICLOUD_REGION")
 	}
+	if c.AlicloudRegion == "" {
+		c.AlicloudRegion = os.Getenv(EnvRegionId)
+	}
+
+	errs = append(errs, c.AssumeRole.Prepare()...)
+
+	if c.CustomEndpointEcs != "" && c.Endpoints.Ecs != "" && c.CustomEndpointEcs != c.Endpoints.Ecs {
+		errs = append(errs, fmt.Errorf("custom_endpoint_ecs and ecs of endpoints are different, only one of them should be set."))
+	}
+
+	for name, proxy := range map[string]string{"http_proxy": c.HttpProxy, "https_proxy": c.HttpsProxy} {
+		if proxy == "" {
+			continue
+		}
+		if proxyURL, err := url.Parse(proxy); err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be a URL such as http://proxy.example.com:3128.", name))
+		}
+	}
+
+	if c.CABundle != "" {
+		if _, err := loadCABundle(c.CABundle); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
 	if c.AlicloudRegion == "" {
 		errs = append(errs, fmt.Errorf("region option or ALICLOUD_REGION must be provided in template file or environment variables."))
@@ -158,9 +499,9 @@ func (c *AlicloudAccessConfig) Config() error {
 	if c.AlicloudSharedCredentialsFile == "" {
 		c.AlicloudSharedCredentialsFile = os.Getenv("ALICLOUD_SHARED_CREDENTIALS_FILE")
 	}
-	if (c.AlicloudAccessKey == "" || c.AlicloudSecretKey == "") && c.AlicloudProfile == "" && c.AlicloudRamRole == "" {
-		return fmt.Errorf("(ALICLOUD_ACCESS_KEY and ALICLOUD_SECRET_KEY) or ram_role_name option must be set in template file or environment variables.")
-	}
+	// Without explicit credentials, the default credential chain is only
+	// resolved when the client is created, since finding the RAM role of the
+	// ECS instance takes a request to its metadata service
 	return nil
 
 }
@@ -200,66 +541,3 @@ func (c *AlicloudAccessConfig) getSupportedRegions() ([]string, error) {
 
 	return validRegions, nil
 }
-
-func getConfigFromProfile(c *AlicloudAccessConfig, ProfileKey string) (interface{}, error) {
-	providerConfig := make(map[string]interface{})
-	current := c.AlicloudProfile
-	if current != "" {
-		profilePath, err := homedir.Expand(c.AlicloudSharedCredentialsFile)
-		if err != nil {
-			return nil, err
-		}
-		if profilePath == "" {
-			profilePath = fmt.Sprintf("%s/.aliyun/config.json", os.Getenv("HOME"))
-			if runtime.GOOS == "windows" {
-				profilePath = fmt.Sprintf("%s/.aliyun/config.json", os.Getenv("USERPROFILE"))
-			}
-		}
-		_, err = os.Stat(profilePath)
-		if !os.IsNotExist(err) {
-			data, err := ioutil.ReadFile(profilePath)
-			if err != nil {
-				return nil, err
-			}
-			config := map[string]interface{}{}
-			err = json.Unmarshal(data, &config)
-			if err != nil {
-				return nil, err
-			}
-			for _, v := range config["profiles"].([]interface{}) {
-				if current == v.(map[string]interface{})["name"] {
-					providerConfig = v.(map[string]interface{})
-				}
-			}
-		}
-	}
-	mode := ""
-	if v, ok := providerConfig["mode"]; ok {
-		mode = v.(string)
-	} else {
-		return v, nil
-	}
-	switch ProfileKey {
-	case "access_key_id", "access_key_secret":
-		if mode == "EcsRamRole" {
-			return "", nil
-		}
-	case "ram_role_name":
-		if mode != "EcsRamRole" {
-			return "", nil
-		}
-	case "sts_token":
-		if mode != "StsToken" {
-			return "", nil
-		}
-	case "ram_role_arn", "ram_session_name":
-		if mode != "RamRoleArn" {
-			return "", nil
-		}
-	case "expired_seconds":
-		if mode != "RamRoleArn" {
-			return float64(0), nil
-		}
-	}
-	return providerConfig[ProfileKey], nil
-}
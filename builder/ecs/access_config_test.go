@@ -4,8 +4,12 @@
 package ecs
 
 import (
+	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/endpoints"
 )
 
 func testAlicloudAccessConfig() *AlicloudAccessConfig {
@@ -50,7 +54,13 @@ func TestAlicloudAccessConfigPrepareRegion(t *testing.T) {
 		defer os.Setenv("ALICLOUD_PROFILE", v)
 	}
 	c.AlicloudAccessKey = ""
-	if err := c.Prepare(nil); err == nil {
+	// Without credentials, the default credential chain is only resolved, and
+	// the missing credentials reported, when the client is created
+	testCredentialEnvironment(t, "")
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if _, err := c.Client(); err == nil {
 		t.Fatalf("should have err")
 	}
 
@@ -67,3 +77,123 @@ func TestAlicloudAccessConfigPrepareRegion(t *testing.T) {
 
 	c.AlicloudSkipValidation = false
 }
+
+func TestAlicloudAccessConfigPrepareAssumeRole(t *testing.T) {
+	c := testAlicloudAccessConfig()
+	c.AlicloudRegion = "cn-beijing"
+
+	c.AssumeRole.SessionName = "packer"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+
+	c.AssumeRole.RoleArn = "acs:ram::123456:role/packer"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if c.AssumeRole.SessionExpiration != DefaultRoleSessionExpiration {
+		t.Fatalf("session_expiration should default to %d", DefaultRoleSessionExpiration)
+	}
+
+	c.AssumeRole.SessionExpiration = 600
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+
+	c.AssumeRole.SessionExpiration = 7200
+	c.AssumeRole.Policy = "{"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+
+	c.AssumeRole.Policy = `{"Version": "1", "Statement": []}`
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+}
+
+func TestAlicloudAccessConfigPrepareEndpoints(t *testing.T) {
+	c := testAlicloudAccessConfig()
+	c.AlicloudRegion = "cn-beijing"
+
+	c.CustomEndpointEcs = "ecs.example.com"
+	c.Endpoints.Ecs = "ecs-vpc.cn-beijing.aliyuncs.com"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+	c.CustomEndpointEcs = ""
+
+	c.HttpsProxy = "proxy.example.com"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+
+	c.HttpsProxy = "http://proxy.example.com:3128"
+	c.NoProxy = "ecs-vpc.cn-beijing.aliyuncs.com"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	transport, err := c.HTTPTransport()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	request, _ := http.NewRequest("GET", "https://oss-cn-beijing.aliyuncs.com", nil)
+	if proxy, _ := transport.Proxy(request); proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Fatalf("expected the https_proxy, got %v", proxy)
+	}
+	request, _ = http.NewRequest("GET", "https://ecs-vpc.cn-beijing.aliyuncs.com", nil)
+	if proxy, _ := transport.Proxy(request); proxy != nil {
+		t.Fatalf("expected no proxy for no_proxy hosts, got %v", proxy)
+	}
+
+	c.CABundle = filepath.Join(t.TempDir(), "ca.pem")
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+
+	if err := os.WriteFile(c.CABundle, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("error writing CA bundle: %s", err)
+	}
+	if err := c.Prepare(nil); err == nil {
+		t.Fatalf("should have err")
+	}
+}
+
+func TestAlicloudAccessConfigClient_profileEndpoint(t *testing.T) {
+	home := testCredentialEnvironment(t, "")
+	writeTestFile(t, filepath.Join(home, ".aliyun", "config.json"), `{
+  "profiles": [
+    {"name": "default", "mode": "AK", "access_key_id": "ak", "access_key_secret": "sk",
+     "region_id": "cn-profile-test", "endpoint": "ecs.profile.example.com"}
+  ]
+}`)
+
+	c := &AlicloudAccessConfig{AlicloudProfile: "default"}
+	if _, err := c.Client(); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if c.AlicloudRegion != "cn-profile-test" {
+		t.Fatalf("the region should be read from the profile, got %q", c.AlicloudRegion)
+	}
+	if endpoint := endpoints.GetEndpointFromMap("cn-profile-test", "Ecs"); endpoint != "ecs.profile.example.com" {
+		t.Fatalf("the endpoint of the profile should be registered, got %q", endpoint)
+	}
+}
+
+func TestEndpointsConfigOssEndpoint(t *testing.T) {
+	endpoints := EndpointsConfig{}
+	if endpoint := endpoints.OssEndpoint("cn-beijing"); endpoint != "oss-cn-beijing.aliyuncs.com" {
+		t.Fatalf("unexpected endpoint: %s", endpoint)
+	}
+
+	endpoints.OssInternal = true
+	if endpoint := endpoints.OssEndpoint("oss-cn-beijing"); endpoint != "oss-cn-beijing-internal.aliyuncs.com" {
+		t.Fatalf("unexpected endpoint: %s", endpoint)
+	}
+
+	endpoints.Oss = "http://oss-cn-beijing-finance-1-internal.aliyuncs.com"
+	if endpoint := endpoints.OssEndpoint("cn-beijing"); endpoint != "http://oss-cn-beijing-finance-1-internal.aliyuncs.com" {
+		t.Fatalf("unexpected endpoint: %s", endpoint)
+	}
+}
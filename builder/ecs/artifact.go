@@ -22,6 +22,10 @@ type Artifact struct {
 
 	// Alcloud connection for performing API stuff.
 	Client *ClientWrapper
+
+	// StateData should store data such as GeneratedData
+	// to be shared with post-processors
+	StateData map[string]interface{}
 }
 
 func (a *Artifact) BuilderId() string {
@@ -59,7 +63,7 @@ func (a *Artifact) State(name string) interface{} {
 	case "atlas.artifact.metadata":
 		return a.stateAtlasMetadata()
 	default:
-		return nil
+		return a.StateData[name]
 	}
 }
 
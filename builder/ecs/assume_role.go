@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+)
+
+// The credentials of the role session are refreshed when less than this part
+// of the session duration is left
+const assumeRoleRefreshRatio = 0.2
+
+// assumeRoleFunc assumes the role and returns its credentials.
+type assumeRoleFunc func(request *sts.AssumeRoleRequest) (*sts.AssumeRoleResponse, error)
+
+// assumeRoleSession returns the credentials of the assumed RAM role, which
+// are refreshed before they expire. The same session is shared by all the
+// clients.
+type assumeRoleSession struct {
+	config     AssumeRoleConfig
+	assumeRole assumeRoleFunc
+
+	lock        sync.Mutex
+	credentials sts.Credentials
+	refreshAt   time.Time
+}
+
+// startAssumeRoleSession assumes the role at once, so that a wrong role is
+// reported before any request is sent.
+func startAssumeRoleSession(stsClient *sts.Client, config AssumeRoleConfig) (*assumeRoleSession, error) {
+	session := newAssumeRoleSession(stsClient, config)
+	if _, err := session.sessionCredentials(); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// newAssumeRoleSession returns the session, which assumes the role when the
+// credentials are first needed.
+func newAssumeRoleSession(stsClient *sts.Client, config AssumeRoleConfig) *assumeRoleSession {
+	session := &assumeRoleSession{
+		config:     config,
+		assumeRole: stsClient.AssumeRole,
+	}
+	if session.config.SessionName == "" {
+		session.config.SessionName = roleSessionName("")
+	}
+	if session.config.SessionExpiration == 0 {
+		session.config.SessionExpiration = DefaultRoleSessionExpiration
+	}
+
+	return session
+}
+
+func (s *assumeRoleSession) sessionCredentials() (SessionCredentials, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.credentials.AccessKeyId == "" || !time.Now().Before(s.refreshAt) {
+		if err := s.refresh(); err != nil {
+			return SessionCredentials{}, err
+		}
+	}
+
+	return SessionCredentials{
+		AccessKeyId:     s.credentials.AccessKeyId,
+		AccessKeySecret: s.credentials.AccessKeySecret,
+		SecurityToken:   s.credentials.SecurityToken,
+	}, nil
+}
+
+func (s *assumeRoleSession) refresh() error {
+	request := sts.CreateAssumeRoleRequest()
+	request.SetScheme(requests.HTTPS)
+	request.RoleArn = s.config.RoleArn
+	request.RoleSessionName = s.config.SessionName
+	request.ExternalId = s.config.ExternalId
+	request.Policy = s.config.Policy
+	request.DurationSeconds = requests.NewInteger(s.config.SessionExpiration)
+
+	response, err := s.assumeRole(request)
+	if err != nil {
+		return fmt.Errorf("Error assuming role %s: %s", s.config.RoleArn, err)
+	}
+
+	credentials := response.Credentials
+	if credentials.AccessKeyId == "" || credentials.AccessKeySecret == "" || credentials.SecurityToken == "" {
+		return fmt.Errorf("Error assuming role %s: the response has no credentials", s.config.RoleArn)
+	}
+
+	duration := time.Duration(s.config.SessionExpiration) * time.Second
+	if expiration, err := time.Parse(time.RFC3339, credentials.Expiration); err == nil {
+		duration = time.Until(expiration)
+	}
+
+	s.credentials = credentials
+	s.refreshAt = time.Now().Add(time.Duration(float64(duration) * (1 - assumeRoleRefreshRatio)))
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/signers"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+)
+
+func TestAssumeRoleSession_refresh(t *testing.T) {
+	var calls int
+	var lastRequest *sts.AssumeRoleRequest
+	session := &assumeRoleSession{
+		config: AssumeRoleConfig{
+			RoleArn:           "acs:ram::123456:role/packer",
+			SessionName:       "packer",
+			ExternalId:        "external",
+			SessionExpiration: 3600,
+		},
+		assumeRole: func(request *sts.AssumeRoleRequest) (*sts.AssumeRoleResponse, error) {
+			calls++
+			lastRequest = request
+			response := sts.CreateAssumeRoleResponse()
+			response.Credentials = sts.Credentials{
+				AccessKeyId:     fmt.Sprintf("ak-%d", calls),
+				AccessKeySecret: "sk",
+				SecurityToken:   "token",
+				Expiration:      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			}
+			return response, nil
+		},
+	}
+
+	credentials, err := session.sessionCredentials()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if credentials.AccessKeyId != "ak-1" || credentials.SecurityToken != "token" {
+		t.Fatalf("unexpected credentials: %#v", credentials)
+	}
+	if lastRequest.ExternalId != "external" || lastRequest.DurationSeconds != "3600" {
+		t.Fatalf("unexpected request: %#v", lastRequest)
+	}
+
+	if credentials, _ := session.sessionCredentials(); credentials.AccessKeyId != "ak-1" || calls != 1 {
+		t.Fatalf("the credentials shouldn't be refreshed before they expire")
+	}
+
+	session.refreshAt = time.Now().Add(-time.Second)
+	if credentials, _ := session.sessionCredentials(); credentials.AccessKeyId != "ak-2" || calls != 2 {
+		t.Fatalf("the credentials should be refreshed before they expire")
+	}
+
+	session.refreshAt = time.Now().Add(-time.Second)
+	session.assumeRole = func(*sts.AssumeRoleRequest) (*sts.AssumeRoleResponse, error) {
+		return nil, fmt.Errorf("NoPermission")
+	}
+	if _, err := session.sessionCredentials(); err == nil {
+		t.Fatalf("should have err")
+	}
+}
+
+// testRotatingSession returns new credentials on each call, as if every
+// request refreshed them.
+type testRotatingSession struct {
+	lock  sync.Mutex
+	calls int
+}
+
+func (s *testRotatingSession) sessionCredentials() (SessionCredentials, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.calls++
+	return SessionCredentials{
+		AccessKeyId:     fmt.Sprintf("ak-%d", s.calls),
+		AccessKeySecret: fmt.Sprintf("sk-%d", s.calls),
+		SecurityToken:   fmt.Sprintf("token-%d", s.calls),
+	}, nil
+}
+
+func TestSessionSigner_concurrent(t *testing.T) {
+	signer := newSessionSigner(&testRotatingSession{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				// Sign the requests in the order of the SDK, yielding in
+				// between so that the concurrent requests interleave
+				accessKeyId, err := signer.GetAccessKeyId()
+				if err != nil {
+					t.Errorf("shouldn't have err: %s", err)
+					return
+				}
+				runtime.Gosched()
+				token := signer.GetExtraParam()["SecurityToken"]
+				runtime.Gosched()
+				signature := signer.Sign("string", "&")
+
+				// The access key, the token and the signature of a request
+				// are all from the same credentials
+				suffix := strings.TrimPrefix(accessKeyId, "ak-")
+				if token != "token-"+suffix {
+					t.Errorf("the token %s doesn't match the access key %s", token, accessKeyId)
+				}
+				if signature != signers.ShaHmac1("string", "sk-"+suffix+"&") {
+					t.Errorf("the request with the access key %s isn't signed with its secret", accessKeyId)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,326 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+const (
+	ResourceTypeKeyPair       = "key_pair"
+	ResourceTypeVpc           = "vpc"
+	ResourceTypeVSwitch       = "vswitch"
+	ResourceTypeSecurityGroup = "security_group"
+	ResourceTypeInstance      = "instance"
+	ResourceTypeEip           = "eip"
+	ResourceTypeNatGateway    = "nat_gateway"
+	ResourceTypeSnatEntry     = "snat_entry"
+	ResourceTypeRamRole       = "ram_role"
+	ResourceTypeRamPolicy     = "ram_policy"
+	ResourceTypeImage         = "image"
+	ResourceTypeSnapshot      = "snapshot"
+)
+
+const (
+	BuildStatusSucceeded = "succeeded"
+	BuildStatusFailed    = "failed"
+	BuildStatusCancelled = "cancelled"
+)
+
+const ImageStatusNotFound = "NotFound"
+
+// BuildReport is the machine-readable summary of a build written to
+// `build_report_path`.
+type BuildReport struct {
+	BuildName      string                      `json:"build_name"`
+	BuilderId      string                      `json:"builder_id"`
+	Region         string                      `json:"region"`
+	Status         string                      `json:"status"`
+	Error          string                      `json:"error,omitempty"`
+	StartedAt      time.Time                   `json:"started_at"`
+	FinishedAt     time.Time                   `json:"finished_at"`
+	SourceImage    *BuildReportSourceImage     `json:"source_image,omitempty"`
+	Steps          []*BuildReportStep          `json:"steps"`
+	Resources      []*BuildReportResource      `json:"resources"`
+	Images         []*BuildReportImage         `json:"images"`
+	FailedCleanups []*BuildReportFailedCleanup `json:"failed_cleanups"`
+
+	lock sync.Mutex
+}
+
+type BuildReportSourceImage struct {
+	ImageId      string `json:"image_id"`
+	ImageName    string `json:"image_name"`
+	ImageFamily  string `json:"image_family,omitempty"`
+	OSName       string `json:"os_name,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+}
+
+type BuildReportStep struct {
+	Name              string     `json:"name"`
+	StartedAt         time.Time  `json:"started_at"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	Action            string     `json:"action,omitempty"`
+	CleanupStartedAt  *time.Time `json:"cleanup_started_at,omitempty"`
+	CleanupFinishedAt *time.Time `json:"cleanup_finished_at,omitempty"`
+}
+
+type BuildReportResource struct {
+	Type      string     `json:"type"`
+	Id        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type BuildReportImage struct {
+	Region      string   `json:"region"`
+	ImageId     string   `json:"image_id"`
+	Status      string   `json:"status"`
+	SnapshotIds []string `json:"snapshot_ids"`
+}
+
+type BuildReportFailedCleanup struct {
+	Type  string `json:"type"`
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+func NewBuildReport(buildName string, region string) *BuildReport {
+	return &BuildReport{
+		BuildName:      buildName,
+		BuilderId:      BuilderId,
+		Region:         region,
+		StartedAt:      time.Now().UTC(),
+		Steps:          []*BuildReportStep{},
+		Resources:      []*BuildReportResource{},
+		Images:         []*BuildReportImage{},
+		FailedCleanups: []*BuildReportFailedCleanup{},
+	}
+}
+
+func (r *BuildReport) stepStarted(name string) *BuildReportStep {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	step := &BuildReportStep{
+		Name:      name,
+		StartedAt: time.Now().UTC(),
+	}
+	r.Steps = append(r.Steps, step)
+	return step
+}
+
+func (r *BuildReport) stepFinished(step *BuildReportStep, action multistep.StepAction) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now().UTC()
+	step.FinishedAt = &now
+	if action == multistep.ActionHalt {
+		step.Action = "halt"
+	} else {
+		step.Action = "continue"
+	}
+}
+
+func (r *BuildReport) stepCleanup(step *BuildReportStep, cleanup func()) {
+	r.lock.Lock()
+	now := time.Now().UTC()
+	step.CleanupStartedAt = &now
+	r.lock.Unlock()
+
+	cleanup()
+
+	r.lock.Lock()
+	now = time.Now().UTC()
+	step.CleanupFinishedAt = &now
+	r.lock.Unlock()
+}
+
+func (r *BuildReport) resourceCreated(resourceType string, id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.Resources = append(r.Resources, &BuildReportResource{
+		Type:      resourceType,
+		Id:        id,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func (r *BuildReport) resourceDeleted(resourceType string, id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now().UTC()
+	for _, resource := range r.Resources {
+		if resource.Type == resourceType && resource.Id == id && resource.DeletedAt == nil {
+			resource.DeletedAt = &now
+			return
+		}
+	}
+}
+
+func (r *BuildReport) cleanupFailed(resourceType string, id string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.FailedCleanups = append(r.FailedCleanups, &BuildReportFailedCleanup{
+		Type:  resourceType,
+		Id:    id,
+		Error: err.Error(),
+	})
+}
+
+// finish records the outcome of the build along with the final status of the
+// images in every region.
+func (r *BuildReport) finish(state multistep.StateBag, client *ClientWrapper) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.FinishedAt = time.Now().UTC()
+	r.Status = BuildStatusSucceeded
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		r.Status = BuildStatusCancelled
+	}
+	if rawErr, ok := state.GetOk("error"); ok {
+		r.Status = BuildStatusFailed
+		r.Error = rawErr.(error).Error()
+	}
+
+	if image, ok := state.GetOk("source_image"); ok {
+		sourceImage := image.(*ecs.Image)
+		r.SourceImage = &BuildReportSourceImage{
+			ImageId:      sourceImage.ImageId,
+			ImageName:    sourceImage.ImageName,
+			ImageFamily:  sourceImage.ImageFamily,
+			OSName:       sourceImage.OSName,
+			Architecture: sourceImage.Architecture,
+		}
+	}
+
+	rawImages, ok := state.GetOk("alicloudimages")
+	if !ok {
+		return
+	}
+
+	for regionId, imageId := range rawImages.(map[string]string) {
+		reportImage := &BuildReportImage{
+			Region:      regionId,
+			ImageId:     imageId,
+			Status:      ImageStatusNotFound,
+			SnapshotIds: []string{},
+		}
+
+		describeImagesRequest := ecs.CreateDescribeImagesRequest()
+		describeImagesRequest.RegionId = regionId
+		describeImagesRequest.ImageId = imageId
+		describeImagesRequest.Status = ImageStatusQueried
+		imagesResponse, err := client.DescribeImages(describeImagesRequest)
+		if err != nil {
+			reportImage.Status = fmt.Sprintf("Unknown: %s", err)
+		} else if images := imagesResponse.Images.Image; len(images) > 0 {
+			reportImage.Status = images[0].Status
+			for _, device := range images[0].DiskDeviceMappings.DiskDeviceMapping {
+				if device.SnapshotId != "" {
+					reportImage.SnapshotIds = append(reportImage.SnapshotIds, device.SnapshotId)
+				}
+			}
+		}
+
+		r.Images = append(r.Images, reportImage)
+	}
+}
+
+func (r *BuildReport) Write(path string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// reportStep records the run and cleanup times of the wrapped step in the
+// build report.
+type reportStep struct {
+	multistep.Step
+	report *BuildReport
+
+	entry *BuildReportStep
+}
+
+func (s *reportStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	s.entry = s.report.stepStarted(stepName(s.Step))
+	action := s.Step.Run(ctx, state)
+	s.report.stepFinished(s.entry, action)
+	return action
+}
+
+func (s *reportStep) Cleanup(state multistep.StateBag) {
+	if s.entry == nil {
+		s.Step.Cleanup(state)
+		return
+	}
+
+	s.report.stepCleanup(s.entry, func() {
+		s.Step.Cleanup(state)
+	})
+}
+
+// applyResult forwards the result of the wrapped setup step, so that the setup
+// graph still applies it to the config.
+func (s *reportStep) applyResult(state multistep.StateBag) {
+	if result, ok := s.Step.(setupGraphResult); ok {
+		result.applyResult(state)
+	}
+}
+
+func withBuildReport(steps []multistep.Step, report *BuildReport) []multistep.Step {
+	reportSteps := make([]multistep.Step, 0, len(steps))
+	for _, step := range steps {
+		if graph, ok := step.(*stepSetupGraph); ok {
+			for _, node := range graph.Nodes {
+				node.Step = &reportStep{Step: node.Step, report: report}
+			}
+		}
+		reportSteps = append(reportSteps, &reportStep{Step: step, report: report})
+	}
+
+	return reportSteps
+}
+
+func stepName(step multistep.Step) string {
+	return reflect.Indirect(reflect.ValueOf(step)).Type().Name()
+}
+
+func reportResourceCreated(state multistep.StateBag, resourceType string, id string) {
+	if report, ok := state.GetOk("build_report"); ok {
+		report.(*BuildReport).resourceCreated(resourceType, id)
+	}
+}
+
+func reportResourceDeleted(state multistep.StateBag, resourceType string, id string) {
+	if report, ok := state.GetOk("build_report"); ok {
+		report.(*BuildReport).resourceDeleted(resourceType, id)
+	}
+}
+
+func reportCleanupFailed(state multistep.StateBag, resourceType string, id string, err error) {
+	if report, ok := state.GetOk("build_report"); ok {
+		report.(*BuildReport).cleanupFailed(resourceType, id, err)
+	}
+}
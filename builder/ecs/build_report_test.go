@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+type testReportStep struct {
+	action multistep.StepAction
+}
+
+func (s *testReportStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	reportResourceCreated(state, ResourceTypeVpc, "vpc-test")
+	return s.action
+}
+
+func (s *testReportStep) Cleanup(state multistep.StateBag) {
+	reportCleanupFailed(state, ResourceTypeVpc, "vpc-test", fmt.Errorf("DependencyViolation"))
+}
+
+func TestBuildReport_steps(t *testing.T) {
+	report := NewBuildReport("test", "cn-beijing")
+	state := new(multistep.BasicStateBag)
+	state.Put("build_report", report)
+
+	steps := withBuildReport([]multistep.Step{&testReportStep{action: multistep.ActionHalt}}, report)
+	runner := &multistep.BasicRunner{Steps: steps}
+	runner.Run(context.Background(), state)
+
+	if len(report.Steps) != 1 {
+		t.Fatalf("bad: %d steps", len(report.Steps))
+	}
+	step := report.Steps[0]
+	if step.Name != "testReportStep" || step.Action != "halt" {
+		t.Fatalf("bad: %#v", step)
+	}
+	if step.FinishedAt == nil || step.CleanupStartedAt == nil || step.CleanupFinishedAt == nil {
+		t.Fatalf("missing step times: %#v", step)
+	}
+
+	if len(report.Resources) != 1 || report.Resources[0].Id != "vpc-test" || report.Resources[0].DeletedAt != nil {
+		t.Fatalf("bad: %#v", report.Resources)
+	}
+	if len(report.FailedCleanups) != 1 || report.FailedCleanups[0].Error != "DependencyViolation" {
+		t.Fatalf("bad: %#v", report.FailedCleanups)
+	}
+}
+
+func TestBuildReport_setupGraph(t *testing.T) {
+	report := NewBuildReport("test", "cn-beijing")
+	state := testState(t)
+	state.Put("build_report", report)
+
+	events := &testGraphEvents{}
+	steps := withBuildReport([]multistep.Step{testSetupGraph(events, multistep.ActionContinue)}, report)
+	if action := steps[0].Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// The results of the wrapped setup steps are still applied
+	expected := []string{"apply security_group", "apply vpc", "apply key_pair"}
+	if applied := events.events[3:]; !reflect.DeepEqual(applied, expected) {
+		t.Fatalf("bad events: %v", events.events)
+	}
+	if len(report.Steps) != 4 {
+		t.Fatalf("the graph and its steps should be reported: %#v", report.Steps)
+	}
+}
+
+func TestBuildReport_resourceDeleted(t *testing.T) {
+	report := NewBuildReport("test", "cn-beijing")
+	state := new(multistep.BasicStateBag)
+
+	// Reporting without a report in the state is a no-op
+	reportResourceCreated(state, ResourceTypeInstance, "i-test")
+
+	state.Put("build_report", report)
+	reportResourceCreated(state, ResourceTypeInstance, "i-test")
+	reportResourceCreated(state, ResourceTypeKeyPair, "i-test")
+	reportResourceDeleted(state, ResourceTypeInstance, "i-test")
+
+	if report.Resources[0].DeletedAt == nil {
+		t.Fatal("instance should be deleted")
+	}
+	if report.Resources[1].DeletedAt != nil {
+		t.Fatal("key pair shouldn't be deleted")
+	}
+}
+
+func TestBuildReport_Write(t *testing.T) {
+	report := NewBuildReport("test", "cn-beijing")
+	state := new(multistep.BasicStateBag)
+	state.Put("error", fmt.Errorf("build failed"))
+	report.finish(state, nil)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := report.Write(path); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if result["status"] != BuildStatusFailed || result["error"] != "build failed" {
+		t.Fatalf("bad: %s", data)
+	}
+	if _, ok := result["failed_cleanups"].([]interface{}); !ok {
+		t.Fatalf("failed_cleanups should be a list: %s", data)
+	}
+}
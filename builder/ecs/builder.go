@@ -1,7 +1,7 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,AlicloudDiskDevice
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,AlicloudDiskDevice,AlicloudResourceFilter,MetadataOptions,InstanceTypeSelector,AssumeRoleConfig,EndpointsConfig,TimeoutsConfig
 
 // The alicloud  contains a packersdk.Builder implementation that
 // builds ecs images for alicloud.
@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
@@ -80,7 +81,18 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	}
 
 	packersdk.LogSecretFilter.Set(b.config.AlicloudAccessKey, b.config.AlicloudSecretKey)
-	return nil, nil, nil
+	if b.config.Comm.WinRMPassword != "" {
+		packersdk.LogSecretFilter.Set(b.config.Comm.WinRMPassword)
+	}
+
+	generatedData := []string{
+		"EstimatedHourlyPrice",
+		"PriceCurrency",
+		"BuildDuration",
+		"EstimatedCost",
+		"WinRMPassword",
+	}
+	return generatedData, nil, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
@@ -89,67 +101,153 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	if err != nil {
 		return nil, err
 	}
+	client.PollInterval = b.config.Timeouts.PollInterval
 	state := new(multistep.BasicStateBag)
 	state.Put("config", &b.config)
 	state.Put("client", client)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 	state.Put("networktype", b.chooseNetworkType())
+	if b.config.Comm.Type == "winrm" {
+		generatedData := &packerbuilderdata.GeneratedData{State: state}
+		generatedData.Put("WinRMPassword", b.config.Comm.WinRMPassword)
+	}
 	var steps []multistep.Step
 
 	// Build the steps
-	steps = []multistep.Step{
+	if b.config.AlicloudImageFamily != "" {
+		steps = append(steps, &stepCheckAlicloudImageFamily{
+			ImageFamily: b.config.AlicloudImageFamily,
+		})
+	} else {
+		steps = append(steps, &stepCheckAlicloudSourceImage{
+			SourceECSImageId: b.config.AlicloudSourceImage,
+		})
+	}
+	if !b.config.InstanceTypeSelector.Empty() {
+		steps = append(steps, &stepSelectAlicloudInstanceType{
+			RegionId: b.config.AlicloudRegion,
+			ZoneId:   b.config.ZoneId,
+			Selector: b.config.InstanceTypeSelector,
+		})
+	}
+	if b.config.LaunchTemplateSpecified() {
+		steps = append(steps, &stepCheckAlicloudLaunchTemplate{
+			LaunchTemplateId:      b.config.LaunchTemplateId,
+			LaunchTemplateName:    b.config.LaunchTemplateName,
+			LaunchTemplateVersion: b.config.LaunchTemplateVersion,
+		})
+	}
+	// The architecture is checked once the instance type is known, before
+	// any resource is created
+	steps = append(steps,
+		&stepCheckAlicloudImageArchitecture{},
 		&stepPreValidate{
 			AlicloudDestImageName: b.config.AlicloudImageName,
 			ForceDelete:           b.config.AlicloudImageForceDelete,
 		},
+		&stepEstimateAlicloudCost{
+			RegionId:                b.config.AlicloudRegion,
+			ZoneId:                  b.config.ZoneId,
+			IOOptimized:             b.config.IOOptimized,
+			InternetChargeType:      b.config.InternetChargeType,
+			InternetMaxBandwidthOut: b.config.InternetMaxBandwidthOut,
+			SpotStrategy:            b.config.SpotStrategy,
+			MaxHourlyPrice:          b.config.MaxHourlyPrice,
+			GeneratedData:           &packerbuilderdata.GeneratedData{State: state},
+		},
+	)
+
+	setupSteps := []*setupGraphNode{
+		{
+			Name: "key_pair",
+			Step: &stepConfigAlicloudKeyPair{
+				Debug:        b.config.PackerDebug,
+				Comm:         &b.config.Comm,
+				DebugKeyPath: fmt.Sprintf("ecs_%s.pem", b.config.PackerBuildName),
+				RegionId:     b.config.AlicloudRegion,
+			},
+		},
 	}
-
-	// Customer config image family skip image check
-	if b.config.AlicloudImageFamily != "" {
-		steps = append(steps,
-			&stepCheckAlicloudImageFamily{
-				ImageFamily: b.config.AlicloudImageFamily,
+	var securityGroupDependencies []string
+	// The vswitch and the security groups of the launch template are used
+	// unless they are set explicitly
+	if b.chooseNetworkType() == InstanceNetworkVpc && !b.config.UsesLaunchTemplateVSwitch() {
+		setupSteps = append(setupSteps,
+			&setupGraphNode{
+				Name: "vpc",
+				Step: &stepConfigAlicloudVPC{
+					VpcId:     b.config.VpcId,
+					CidrBlock: b.config.CidrBlock,
+					VpcName:   b.config.VpcName,
+					VpcFilter: b.config.VpcFilter,
+				},
+			},
+			&setupGraphNode{
+				Name: "vswitch",
+				Step: &stepConfigAlicloudVSwitch{
+					VSwitchId:        b.config.VSwitchId,
+					ZoneId:           b.config.ZoneId,
+					CidrBlock:        b.config.CidrBlock,
+					VSwitchName:      b.config.VSwitchName,
+					VSwitchFilter:    b.config.VSwitchFilter,
+					CidrPrefixLength: b.config.VSwitchCidrPrefixLength,
+				},
+				DependsOn: []string{"vpc"},
 			})
-	} else {
-		steps = append(steps,
-			&stepCheckAlicloudSourceImage{
-				SourceECSImageId: b.config.AlicloudSourceImage,
+		securityGroupDependencies = []string{"vpc"}
+		if b.config.TemporaryNatGateway {
+			setupSteps = append(setupSteps, &setupGraphNode{
+				Name: "nat_gateway",
+				Step: &stepConfigAlicloudNatGateway{
+					RegionId:                b.config.AlicloudRegion,
+					InternetChargeType:      b.config.InternetChargeType,
+					InternetMaxBandwidthOut: b.config.InternetMaxBandwidthOut,
+				},
+				DependsOn: []string{"vswitch"},
 			})
+		}
 	}
-	steps = append(steps,
-		&stepConfigAlicloudKeyPair{
-			Debug:        b.config.PackerDebug,
-			Comm:         &b.config.Comm,
-			DebugKeyPath: fmt.Sprintf("ecs_%s.pem", b.config.PackerBuildName),
-			RegionId:     b.config.AlicloudRegion,
+	securityGroupStep := &stepConfigAlicloudSecurityGroup{
+		SecurityGroupId:     b.config.SecurityGroupId,
+		SecurityGroupIds:    b.config.SecurityGroupIds,
+		SecurityGroupFilter: b.config.SecurityGroupFilter,
+		SecurityGroupName:   b.config.SecurityGroupName,
+		RegionId:            b.config.AlicloudRegion,
+	}
+	// Only WinRM is reachable when it is bootstrapped
+	var bootstrapUserData string
+	if b.config.WinRMBootstrap {
+		bootstrapUserData = winRMBootstrapUserData(b.config.Comm.WinRMPort)
+		securityGroupStep.IngressProtocol = IpProtocolTCP
+		securityGroupStep.IngressPortRange = fmt.Sprintf("%d/%d", b.config.Comm.WinRMPort, b.config.Comm.WinRMPort)
+	}
+	if !b.config.UsesLaunchTemplateSecurityGroups() {
+		setupSteps = append(setupSteps, &setupGraphNode{
+			Name:      "security_group",
+			Step:      securityGroupStep,
+			DependsOn: securityGroupDependencies,
 		})
-	if b.chooseNetworkType() == InstanceNetworkVpc {
-		steps = append(steps,
-			&stepConfigAlicloudVPC{
-				VpcId:     b.config.VpcId,
-				CidrBlock: b.config.CidrBlock,
-				VpcName:   b.config.VpcName,
+	}
+	if b.config.TemporaryRamRolePolicyDocument != "" {
+		setupSteps = append(setupSteps, &setupGraphNode{
+			Name: "ram_role",
+			Step: &stepConfigAlicloudRamRole{
+				PolicyDocument: b.config.TemporaryRamRolePolicyDocument,
 			},
-			&stepConfigAlicloudVSwitch{
-				VSwitchId:   b.config.VSwitchId,
-				ZoneId:      b.config.ZoneId,
-				CidrBlock:   b.config.CidrBlock,
-				VSwitchName: b.config.VSwitchName,
-			})
+		})
 	}
+	// The instance is only created once every setup step has finished
 	steps = append(steps,
-		&stepConfigAlicloudSecurityGroup{
-			SecurityGroupId:   b.config.SecurityGroupId,
-			SecurityGroupName: b.config.SecurityGroupName,
-			RegionId:          b.config.AlicloudRegion,
+		&stepSetupGraph{
+			Nodes: setupSteps,
 		},
 		&stepCreateAlicloudInstance{
 			IOOptimized:                 b.config.IOOptimized,
-			InstanceType:                b.config.InstanceType,
 			UserData:                    b.config.UserData,
 			UserDataFile:                b.config.UserDataFile,
-			RamRoleName:                 b.config.RamRoleName,
+			WinRMBootstrapUserData:      bootstrapUserData,
+			MetadataOptions:             b.config.MetadataOptions,
 			Tags:                        b.config.RunTags,
 			RegionId:                    b.config.AlicloudRegion,
 			InternetChargeType:          b.config.InternetChargeType,
@@ -158,6 +256,8 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			ZoneId:                      b.config.ZoneId,
 			SecurityEnhancementStrategy: b.config.SecurityEnhancementStrategy,
 			AlicloudImageFamily:         b.config.AlicloudImageFamily,
+			SpotStrategy:                b.config.SpotStrategy,
+			SpotPriceLimit:              b.config.SpotPriceLimit,
 		})
 	if b.chooseNetworkType() == InstanceNetworkVpc {
 		steps = append(steps, &stepConfigAlicloudEIP{
@@ -177,6 +277,11 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	steps = append(steps,
 		&stepAttachKeyPair{},
 		&stepRunAlicloudInstance{},
+		&stepCaptureAlicloudInstanceOutput{
+			ConsoleOutputPath: fmt.Sprintf("ecs_%s_console.log", b.config.PackerBuildName),
+			ScreenshotPath:    fmt.Sprintf("ecs_%s_screenshot.jpg", b.config.PackerBuildName),
+			Debug:             b.config.PackerDebug,
+		},
 		&communicator.StepConnect{
 			Config: &b.config.RunConfig.Comm,
 			Host: SSHHost(
@@ -202,7 +307,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 
 	if b.config.AlicloudImageIgnoreDataDisks {
 		steps = append(steps, &stepCreateAlicloudSnapshot{
-			WaitSnapshotReadyTimeout: b.getSnapshotReadyTimeout(),
+			WaitSnapshotReadyTimeout: b.config.Timeouts.Snapshot,
 		})
 	}
 
@@ -210,7 +315,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		steps = append(steps,
 			&stepCreateAlicloudImage{
 				AlicloudImageIgnoreDataDisks: b.config.AlicloudImageIgnoreDataDisks,
-				WaitSnapshotReadyTimeout:     b.getSnapshotReadyTimeout(),
+				WaitSnapshotReadyTimeout:     b.config.Timeouts.ImageCreate,
 				Tags:                         b.config.AlicloudImageTags,
 			},
 			&stepCreateTags{
@@ -221,7 +326,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 				AlicloudImageDestinationNames:   b.config.AlicloudImageDestinationNames,
 				KmsKeyIds:                       b.config.AlicloudKMSKeyCopyIds,
 				RegionId:                        b.config.AlicloudRegion,
-				WaitCopyingImageReadyTimeout:    b.getCopyingImageReadyTimeout(),
+				WaitCopyingImageReadyTimeout:    b.config.Timeouts.ImageCopy,
 			},
 			&stepShareAlicloudImage{
 				AlicloudImageShareAccounts:   b.config.AlicloudImageShareAccounts,
@@ -229,10 +334,26 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 				RegionId:                     b.config.AlicloudRegion,
 			})
 	}
+	var report *BuildReport
+	if b.config.BuildReportPath != "" {
+		report = NewBuildReport(b.config.PackerBuildName, b.config.AlicloudRegion)
+		state.Put("build_report", report)
+		steps = withBuildReport(steps, report)
+	}
+
 	// Run!
 	b.runner = commonsteps.NewRunner(steps, b.config.PackerConfig, ui)
 	b.runner.Run(ctx, state)
 
+	if report != nil {
+		report.finish(state, client)
+		if err := report.Write(b.config.BuildReportPath); err != nil {
+			ui.Error(fmt.Sprintf("Error writing build report to %s: %s", b.config.BuildReportPath, err))
+		} else {
+			ui.Say(fmt.Sprintf("Build report written to %s", b.config.BuildReportPath))
+		}
+	}
+
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk("error"); ok {
 		return nil, rawErr.(error)
@@ -248,6 +369,7 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		AlicloudImages: state.Get("alicloudimages").(map[string]string),
 		BuilderIdValue: BuilderId,
 		Client:         client,
+		StateData:      map[string]interface{}{"generated_data": state.Get("generated_data")},
 	}
 
 	return artifact, nil
@@ -267,34 +389,17 @@ func (b *Builder) isVpcNetRequired() bool {
 }
 
 func (b *Builder) isVpcSpecified() bool {
-	return b.config.VpcId != "" || b.config.VSwitchId != ""
+	return b.config.VpcId != "" || b.config.VSwitchId != "" || b.config.TemporaryNatGateway ||
+		!b.config.VpcFilter.Empty() || !b.config.VSwitchFilter.Empty()
 }
 
 func (b *Builder) isUserDataNeeded() bool {
-	// Public key setup requires userdata
-	if b.config.RunConfig.Comm.SSHPrivateKeyFile != "" {
-		return true
-	}
-
-	return b.config.UserData != "" || b.config.UserDataFile != ""
+	return b.config.UserData != "" || b.config.UserDataFile != "" || b.config.WinRMBootstrap
 }
 
 func (b *Builder) isKeyPairNeeded() bool {
-	return b.config.Comm.SSHKeyPairName != "" || b.config.Comm.SSHTemporaryKeyPairName != ""
-}
-
-func (b *Builder) getSnapshotReadyTimeout() int {
-	if b.config.WaitSnapshotReadyTimeout > 0 {
-		return b.config.WaitSnapshotReadyTimeout
-	}
-
-	return ALICLOUD_DEFAULT_LONG_TIMEOUT
-}
-
-func (b *Builder) getCopyingImageReadyTimeout() int {
-	if b.config.WaitCopyingImageReadyTimeout > 0 {
-		return b.config.WaitCopyingImageReadyTimeout
-	}
-
-	return ALICLOUD_DEFAULT_LONG_TIMEOUT
+	// The public key of a private key file or of the SSH agent is imported
+	// as a keypair
+	return b.config.Comm.SSHKeyPairName != "" || b.config.Comm.SSHTemporaryKeyPairName != "" ||
+		b.config.Comm.SSHPrivateKeyFile != "" || b.config.Comm.SSHAgentAuth
 }
@@ -6,6 +6,7 @@ package ecs
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	helperconfig "github.com/hashicorp/packer-plugin-sdk/template/config"
@@ -218,10 +219,11 @@ func TestBuilderPrepare_WaitSnapshotReadyTimeout(t *testing.T) {
 	if b.config.WaitSnapshotReadyTimeout != 0 {
 		t.Fatalf("wait_snapshot_ready_timeout is not set properly, expect: %d, actual: %d", 0, b.config.WaitSnapshotReadyTimeout)
 	}
-	if b.getSnapshotReadyTimeout() != ALICLOUD_DEFAULT_LONG_TIMEOUT {
-		t.Fatalf("default timeout is not set properly, expect: %d, actual: %d", ALICLOUD_DEFAULT_LONG_TIMEOUT, b.getSnapshotReadyTimeout())
+	if expected := time.Duration(ALICLOUD_DEFAULT_LONG_TIMEOUT) * time.Second; b.config.Timeouts.Snapshot != expected {
+		t.Fatalf("default timeout is not set properly, expect: %s, actual: %s", expected, b.config.Timeouts.Snapshot)
 	}
 
+	b = Builder{}
 	config["wait_snapshot_ready_timeout"] = ALICLOUD_DEFAULT_TIMEOUT
 	_, warnings, err = b.Prepare(config)
 	if len(warnings) > 0 {
@@ -235,7 +237,11 @@ func TestBuilderPrepare_WaitSnapshotReadyTimeout(t *testing.T) {
 		t.Fatalf("wait_snapshot_ready_timeout is not set properly, expect: %d, actual: %d", ALICLOUD_DEFAULT_TIMEOUT, b.config.WaitSnapshotReadyTimeout)
 	}
 
-	if b.getSnapshotReadyTimeout() != ALICLOUD_DEFAULT_TIMEOUT {
-		t.Fatalf("default timeout is not set properly, expect: %d, actual: %d", ALICLOUD_DEFAULT_TIMEOUT, b.getSnapshotReadyTimeout())
+	expected := time.Duration(ALICLOUD_DEFAULT_TIMEOUT) * time.Second
+	if b.config.Timeouts.Snapshot != expected {
+		t.Fatalf("snapshot timeout is not set properly, expect: %s, actual: %s", expected, b.config.Timeouts.Snapshot)
+	}
+	if b.config.Timeouts.ImageCreate != expected {
+		t.Fatalf("image_create timeout is not set properly, expect: %s, actual: %s", expected, b.config.Timeouts.ImageCreate)
 	}
 }
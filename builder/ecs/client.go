@@ -10,10 +10,23 @@ import (
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 )
 
 type ClientWrapper struct {
 	*ecs.Client
+	// VpcClient is used for the VPC APIs which aren't provided by ECS, such
+	// as filtering VPCs and vswitches by tags.
+	VpcClient *vpc.Client
+	// RamClient is used to manage the temporary RAM role of the instance.
+	RamClient *ram.Client
+	// KmsClient is used to check the KMS keys in the preflight validation.
+	KmsClient *kms.Client
+	// PollInterval is the default interval between the retries of the
+	// waiters.
+	PollInterval time.Duration
 }
 
 const (
@@ -65,6 +78,74 @@ const (
 	EipStatusAvailable     = "Available"
 )
 
+const (
+	NatGatewayStatusCreating  = "Creating"
+	NatGatewayStatusAvailable = "Available"
+	NatGatewayStatusDeleting  = "Deleting"
+)
+
+const (
+	NatGatewayTypeEnhanced            = "Enhanced"
+	NatGatewayNetworkTypeInternet     = "internet"
+	NatGatewayInternetChargeTypeByLcu = "PayByLcu"
+	EipInstanceTypeNat                = "Nat"
+)
+
+const (
+	SnatEntryStatusPending   = "Pending"
+	SnatEntryStatusAvailable = "Available"
+	SnatEntryStatusDeleting  = "Deleting"
+)
+
+const (
+	ResourceStatusAvailable            = "Available"
+	ResourceStatusCategoryWithoutStock = "WithoutStock"
+)
+
+const (
+	DestinationResourceInstanceType = "InstanceType"
+	DestinationResourceSystemDisk   = "SystemDisk"
+	DestinationResourceDataDisk     = "DataDisk"
+)
+
+const InstanceFamilyLevelCreditEntry = "CreditEntryLevel"
+
+const (
+	RamPolicyTypeCustom  = "Custom"
+	RamRoleNotExistError = "EntityNotExist.Role"
+	// RamRoleEcsTrustPolicy allows ECS instances to assume the role.
+	RamRoleEcsTrustPolicy = `{
+  "Statement": [
+    {
+      "Action": "sts:AssumeRole",
+      "Effect": "Allow",
+      "Principal": {
+        "Service": [
+          "ecs.aliyuncs.com"
+        ]
+      }
+    }
+  ],
+  "Version": "1"
+}`
+)
+
+const (
+	InstanceTypeOrderCheapest = "cheapest"
+	InstanceTypeOrderNewest   = "newest"
+)
+
+const (
+	ImageArchitectureX86_64 = "x86_64"
+	ImageArchitectureI386   = "i386"
+	ImageArchitectureArm64  = "arm64"
+)
+
+const (
+	CpuArchitectureX86 = "X86"
+	CpuArchitectureARM = "ARM"
+)
+
 const (
 	ImageOwnerSystem      = "system"
 	ImageOwnerSelf        = "self"
@@ -87,6 +168,18 @@ const (
 	DiskTypeData   = "data"
 )
 
+const (
+	ImageEncryptionModeCopy   = "copy"
+	ImageEncryptionModeNative = "native"
+)
+
+const (
+	DiskCategoryCloudEssd = "cloud_essd"
+	DiskCategoryCloudAuto = "cloud_auto"
+)
+
+var DiskPerformanceLevels = []string{"PL0", "PL1", "PL2", "PL3"}
+
 const (
 	TagResourceImage    = "image"
 	TagResourceInstance = "instance"
@@ -107,17 +200,87 @@ const (
 	NicTypeIntranet = "intranet"
 )
 
+const (
+	AccountAttributeMaxPostpaidVcpu      = "max-postpaid-instance-vcpu-count"
+	AccountAttributeUsedPostpaidVcpu     = "used-postpaid-instance-vcpu-count"
+	AccountAttributeMaxPostpaidDiskSize  = "max-postpaid-yundisk-capacity"
+	AccountAttributeUsedPostpaidDiskSize = "used-postpaid-yundisk-capacity"
+)
+
+const (
+	InstanceChargeTypePostPaid = "PostPaid"
+)
+
+const (
+	TenancyDefault = "default"
+	TenancyHost    = "host"
+)
+
+const (
+	CreditSpecificationStandard  = "Standard"
+	CreditSpecificationUnlimited = "Unlimited"
+)
+
+const (
+	SpotStrategyNoSpot         = "NoSpot"
+	SpotStrategyWithPriceLimit = "SpotWithPriceLimit"
+	SpotStrategyAsPriceGo      = "SpotAsPriceGo"
+)
+
+const (
+	PriceUnitHour = "Hour"
+)
+
+const (
+	DryRunOperationErrorCode = "DryRunOperation"
+)
+
+const (
+	KMSKeyNotFoundErrorCode = "Forbidden.KeyNotFound"
+	KMSKeyStateEnabled      = "Enabled"
+)
+
 const (
 	DefaultPortRange = "-1/-1"
 	DefaultCidrIp    = "0.0.0.0/0"
 	DefaultCidrBlock = "172.16.0.0/24"
 )
 
+const (
+	DefaultVSwitchCidrPrefixLength = 24
+	MinVSwitchCidrPrefixLength     = 16
+	MaxVSwitchCidrPrefixLength     = 29
+)
+
+const (
+	TemporaryKeyPairTypeRSA     = "rsa"
+	TemporaryKeyPairTypeED25519 = "ed25519"
+)
+
+// TemporaryKeyPairTypes are the key types which can be imported as keypairs
+var TemporaryKeyPairTypes = []string{TemporaryKeyPairTypeRSA, TemporaryKeyPairTypeED25519}
+
+const (
+	MetadataHttpEndpointEnabled  = "enabled"
+	MetadataHttpEndpointDisabled = "disabled"
+	MetadataHttpTokensRequired   = "required"
+	MetadataHttpTokensOptional   = "optional"
+	MinMetadataHopLimit          = 1
+	MaxMetadataHopLimit          = 64
+)
+
+const (
+	ImageImdsSupportV2 = "v2"
+)
+
+const (
+	MaxInstanceSecurityGroups = 5
+	DescribePageSize          = 50
+)
+
 const (
 	defaultRetryInterval = 5 * time.Second
 	defaultRetryTimes    = 12
-	shortRetryTimes      = 36
-	mediumRetryTimes     = 360
 )
 
 type WaitForExpectEvalResult struct {
@@ -148,9 +311,18 @@ type WaitForExpectArgs struct {
 	RetryInterval time.Duration
 	RetryTimes    int
 	RetryTimeout  time.Duration
+	// WaitingFor describes what is waited for, e.g. `instance i-123 to be
+	// Running`, and StatusFunc returns the status of the resource in the
+	// response. Both are reported with the last observed status when the
+	// wait times out.
+	WaitingFor string
+	StatusFunc func(response responses.AcsResponse) string
 }
 
 func (c *ClientWrapper) WaitForExpected(args *WaitForExpectArgs) (responses.AcsResponse, error) {
+	if args.RetryInterval <= 0 {
+		args.RetryInterval = c.PollInterval
+	}
 	if args.RetryInterval <= 0 {
 		args.RetryInterval = defaultRetryInterval
 	}
@@ -165,6 +337,7 @@ func (c *ClientWrapper) WaitForExpected(args *WaitForExpectArgs) (responses.AcsR
 
 	var lastResponse responses.AcsResponse
 	var lastError error
+	lastStatus := "unknown"
 
 	for i := 0; ; i++ {
 		if args.RetryTimeout > 0 && time.Now().After(timeoutPoint) {
@@ -178,6 +351,11 @@ func (c *ClientWrapper) WaitForExpected(args *WaitForExpectArgs) (responses.AcsR
 		response, err := args.RequestFunc()
 		lastResponse = response
 		lastError = err
+		if err == nil && args.StatusFunc != nil {
+			if status := args.StatusFunc(response); status != "" {
+				lastStatus = status
+			}
+		}
 
 		evalResult := args.EvalFunc(response, err)
 		if evalResult.evalPass {
@@ -190,6 +368,17 @@ func (c *ClientWrapper) WaitForExpected(args *WaitForExpectArgs) (responses.AcsR
 		time.Sleep(args.RetryInterval)
 	}
 
+	if args.WaitingFor != "" {
+		elapsed := args.RetryTimeout
+		if elapsed <= 0 {
+			elapsed = time.Duration(args.RetryTimes) * args.RetryInterval
+		}
+		if lastError != nil {
+			return lastResponse, fmt.Errorf("Timeout after %s waiting for %s, last status: %s: %s", elapsed, args.WaitingFor, lastStatus, lastError)
+		}
+		return lastResponse, fmt.Errorf("Timeout after %s waiting for %s, last status: %s", elapsed, args.WaitingFor, lastStatus)
+	}
+
 	if lastError == nil {
 		lastError = fmt.Errorf("<no error>")
 	}
@@ -201,7 +390,7 @@ func (c *ClientWrapper) WaitForExpected(args *WaitForExpectArgs) (responses.AcsR
 	return lastResponse, fmt.Errorf("evaluate failed after %d times retry with %d seconds retry interval: %s", args.RetryTimes, int(args.RetryInterval.Seconds()), lastError)
 }
 
-func (c *ClientWrapper) WaitForInstanceStatus(regionId string, instanceId string, expectedStatus string) (responses.AcsResponse, error) {
+func (c *ClientWrapper) WaitForInstanceStatus(regionId string, instanceId string, expectedStatus string, timeout time.Duration) (responses.AcsResponse, error) {
 	return c.WaitForExpected(&WaitForExpectArgs{
 		RequestFunc: func() (responses.AcsResponse, error) {
 			request := ecs.CreateDescribeInstancesRequest()
@@ -223,7 +412,14 @@ func (c *ClientWrapper) WaitForInstanceStatus(regionId string, instanceId string
 			}
 			return WaitForExpectToRetry
 		},
-		RetryTimes: mediumRetryTimes,
+		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("instance %s to be %s", instanceId, expectedStatus),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, instance := range response.(*ecs.DescribeInstancesResponse).Instances.Instance {
+				return instance.Status
+			}
+			return "not found"
+		},
 	})
 }
 
@@ -252,6 +448,16 @@ func (c *ClientWrapper) WaitForImageStatus(regionId string, imageId string, expe
 			return WaitForExpectToRetry
 		},
 		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("image %s in %s to be %s", imageId, regionId, expectedStatus),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, image := range response.(*ecs.DescribeImagesResponse).Images.Image {
+				if image.Progress != "" {
+					return fmt.Sprintf("%s (%s)", image.Status, image.Progress)
+				}
+				return image.Status
+			}
+			return "not found"
+		},
 	})
 }
 
@@ -278,6 +484,55 @@ func (c *ClientWrapper) WaitForSnapshotStatus(regionId string, snapshotId string
 			return WaitForExpectToRetry
 		},
 		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("snapshot %s to be %s", snapshotId, expectedStatus),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, snapshot := range response.(*ecs.DescribeSnapshotsResponse).Snapshots.Snapshot {
+				if snapshot.Progress != "" {
+					return fmt.Sprintf("%s (%s)", snapshot.Status, snapshot.Progress)
+				}
+				return snapshot.Status
+			}
+			return "not found"
+		},
+	})
+}
+
+func (c *ClientWrapper) WaitForEipStatus(regionId string, allocationId string, expectedStatus string, timeout time.Duration) (responses.AcsResponse, error) {
+	return c.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			request := ecs.CreateDescribeEipAddressesRequest()
+			request.RegionId = regionId
+			request.AllocationId = allocationId
+			response, err := c.DescribeEipAddresses(request)
+			if err == nil && len(response.EipAddresses.EipAddress) == 0 {
+				err = fmt.Errorf("EIP allocated is not found")
+			}
+
+			return response, err
+		},
+		EvalFunc: func(response responses.AcsResponse, err error) WaitForExpectEvalResult {
+			if err != nil {
+				return WaitForExpectToRetry
+			}
+
+			eipAddressesResponse := response.(*ecs.DescribeEipAddressesResponse)
+			eipAddresses := eipAddressesResponse.EipAddresses.EipAddress
+			for _, eipAddress := range eipAddresses {
+				if eipAddress.Status == expectedStatus {
+					return WaitForExpectSuccess
+				}
+			}
+
+			return WaitForExpectToRetry
+		},
+		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("EIP %s to be %s", allocationId, expectedStatus),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, eipAddress := range response.(*ecs.DescribeEipAddressesResponse).EipAddresses.EipAddress {
+				return eipAddress.Status
+			}
+			return ""
+		},
 	})
 }
 
@@ -310,3 +565,8 @@ func (c *ClientWrapper) EvalCouldRetryResponse(evalErrors []string, evalErrorTyp
 		return WaitForExpectToRetry
 	}
 }
+
+func isErrorCodeIn(err error, errorCodes []string) bool {
+	e, ok := err.(errors.Error)
+	return ok && ContainsInArray(errorCodes, e.ErrorCode())
+}
@@ -4,13 +4,83 @@
 package ecs
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 )
 
+// testAPIError is returned by the handler of testClientWrapper to fail the
+// request with the error code.
+type testAPIError string
+
+// testClientWrapper returns a client which sends the ECS, VPC, RAM and KMS requests
+// to the handler, with the name of the API and the parameters of the request.
+// The value returned by the handler is the JSON response of the API.
+func testClientWrapper(t *testing.T, handler func(action string, params url.Values) interface{}) *ClientWrapper {
+	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Error parsing request: %s", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := handler(r.Form.Get("Action"), r.Form)
+		if code, ok := response.(testAPIError); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			response = map[string]string{"Code": string(code), "Message": "test", "RequestId": "test"}
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Error encoding response: %s", err)
+		}
+	})
+	server := httptest.NewServer(handlerFunc)
+	t.Cleanup(server.Close)
+	// The RAM API is called over HTTPS
+	tlsServer := httptest.NewTLSServer(handlerFunc)
+	t.Cleanup(tlsServer.Close)
+
+	domain := strings.TrimPrefix(server.URL, "http://")
+	ecsClient, err := ecs.NewClientWithAccessKey("cn-test", "ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecsClient.Domain = domain
+	vpcClient, err := vpc.NewClientWithAccessKey("cn-test", "ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vpcClient.Domain = domain
+	ramClient, err := ram.NewClientWithAccessKey("cn-test", "ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ramClient.Domain = strings.TrimPrefix(tlsServer.URL, "https://")
+	ramClient.SetHTTPSInsecure(true)
+	kmsClient, err := kms.NewClientWithAccessKey("cn-test", "ak", "sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kmsClient.Domain = domain
+
+	return &ClientWrapper{
+		Client:       ecsClient,
+		VpcClient:    vpcClient,
+		RamClient:    ramClient,
+		KmsClient:    kmsClient,
+		PollInterval: time.Millisecond,
+	}
+}
+
 func TestWaitForExpectedExceedRetryTimes(t *testing.T) {
 	c := ClientWrapper{}
 
@@ -82,3 +152,47 @@ func TestWaitForExpectedExceedRetryTimeout(t *testing.T) {
 		t.Fatalf("WaitForExpected should terminate within %f seconds", (expectTimeout + timeTolerance).Seconds())
 	}
 }
+
+func TestWaitForExpectedTimeoutError(t *testing.T) {
+	c := ClientWrapper{PollInterval: 10 * time.Millisecond}
+
+	iter := 0
+	_, err := c.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			iter++
+			return nil, nil
+		},
+		EvalFunc: func(response responses.AcsResponse, err error) WaitForExpectEvalResult {
+			return WaitForExpectToRetry
+		},
+		StatusFunc: func(response responses.AcsResponse) string {
+			return fmt.Sprintf("Creating(%d%%)", iter*10)
+		},
+		RetryTimeout: 100 * time.Millisecond,
+		WaitingFor:   "image m-123 to be Available",
+	})
+	if err == nil {
+		t.Fatal("WaitForExpected should time out")
+	}
+
+	expected := fmt.Sprintf("Timeout after 100ms waiting for image m-123 to be Available, last status: Creating(%d%%)", iter*10)
+	if err.Error() != expected {
+		t.Fatalf("bad error, expected: %q, actual: %q", expected, err)
+	}
+}
+
+func TestIsErrorCodeIn(t *testing.T) {
+	errorCodes := []string{"DependencyViolation.WindowsInstance"}
+
+	if !isErrorCodeIn(testServerError("DependencyViolation.WindowsInstance"), errorCodes) {
+		t.Fatal("should match error code")
+	}
+
+	if isErrorCodeIn(testServerError("InvalidKeyPairName.NotFound"), errorCodes) {
+		t.Fatal("shouldn't match error code")
+	}
+
+	if isErrorCodeIn(fmt.Errorf("DependencyViolation.WindowsInstance"), errorCodes) {
+		t.Fatal("shouldn't match non server error")
+	}
+}
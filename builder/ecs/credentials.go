@@ -0,0 +1,602 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/sts"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/ini.v1"
+)
+
+// The modes of the profiles of the Alibaba Cloud CLI
+const (
+	ProfileModeAK                  = "AK"
+	ProfileModeStsToken            = "StsToken"
+	ProfileModeEcsRamRole          = "EcsRamRole"
+	ProfileModeRamRoleArn          = "RamRoleArn"
+	ProfileModeChainableRamRoleArn = "ChainableRamRoleArn"
+	ProfileModeCredentialsURI      = "CredentialsURI"
+	ProfileModeOIDC                = "OIDC"
+)
+
+const (
+	EnvAccessKeyId      = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	EnvAccessKeySecret  = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+	EnvSecurityToken    = "ALIBABA_CLOUD_SECURITY_TOKEN"
+	EnvRoleArn          = "ALIBABA_CLOUD_ROLE_ARN"
+	EnvRoleSessionName  = "ALIBABA_CLOUD_ROLE_SESSION_NAME"
+	EnvOIDCProviderArn  = "ALIBABA_CLOUD_OIDC_PROVIDER_ARN"
+	EnvOIDCTokenFile    = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+	EnvCredentialsURI   = "ALIBABA_CLOUD_CREDENTIALS_URI"
+	EnvCredentialsFile  = "ALIBABA_CLOUD_CREDENTIALS_FILE"
+	EnvEcsMetadata      = "ALIBABA_CLOUD_ECS_METADATA"
+	EnvProfile          = "ALIBABA_CLOUD_PROFILE"
+	EnvRegionId         = "ALIBABA_CLOUD_REGION_ID"
+	defaultProfileName  = "default"
+	maxProfileChainSize = 5
+)
+
+const (
+	DefaultRoleSessionExpiration = 3600
+	MinRoleSessionExpiration     = 900
+	MaxRoleSessionExpiration     = 43200
+	ecsMetadataProbeTimeout      = time.Second
+)
+
+var (
+	stsEndpoint        = "https://sts.aliyuncs.com"
+	ecsMetadataRoleURL = "http://100.100.100.200/latest/meta-data/ram/security-credentials/"
+)
+
+// credentialProfile is a profile of the Alibaba Cloud CLI configuration file,
+// `~/.aliyun/config.json`. The sections of the `~/.alibabacloud/credentials`
+// file are converted to it as well.
+type credentialProfile struct {
+	Name            string `json:"name"`
+	Mode            string `json:"mode"`
+	AccessKeyId     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	StsToken        string `json:"sts_token"`
+	StsRegion       string `json:"sts_region"`
+	RamRoleName     string `json:"ram_role_name"`
+	RamRoleArn      string `json:"ram_role_arn"`
+	RamSessionName  string `json:"ram_session_name"`
+	ExpiredSeconds  int    `json:"expired_seconds"`
+	SourceProfile   string `json:"source_profile"`
+	CredentialsURI  string `json:"credentials_uri"`
+	OIDCProviderArn string `json:"oidc_provider_arn"`
+	OIDCTokenFile   string `json:"oidc_token_file"`
+	RegionId        string `json:"region_id"`
+	Endpoint        string `json:"endpoint"`
+}
+
+type cliConfiguration struct {
+	Current  string              `json:"current"`
+	Profiles []credentialProfile `json:"profiles"`
+}
+
+// resolveCredential returns the credential which is used by the API clients.
+// The credentials set in the template are used first, then the profile, and
+// finally the default credential chain: the environment variables, the
+// credentials file and the RAM role of the ECS instance which runs Packer.
+func (c *AlicloudAccessConfig) resolveCredential() (auth.Credential, error) {
+	if c.AlicloudRamRole != "" {
+		return credentials.NewEcsRamRoleCredential(c.AlicloudRamRole), nil
+	}
+
+	if c.AlicloudAccessKey != "" && c.AlicloudSecretKey != "" {
+		if c.AlicloudRamRoleArn != "" {
+			return &credentials.RamRoleArnCredential{
+				AccessKeyId:           c.AlicloudAccessKey,
+				AccessKeySecret:       c.AlicloudSecretKey,
+				RoleArn:               c.AlicloudRamRoleArn,
+				RoleSessionName:       roleSessionName(c.AlicloudRamSessionName),
+				RoleSessionExpiration: DefaultRoleSessionExpiration,
+			}, nil
+		}
+
+		return staticCredential(c.AlicloudAccessKey, c.AlicloudSecretKey, c.SecurityToken), nil
+	}
+
+	if c.AlicloudProfile != "" {
+		profile, err := c.loadProfile(c.AlicloudProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.AlicloudRegion == "" {
+			c.AlicloudRegion = profile.RegionId
+		}
+		if c.Endpoints.Ecs == "" {
+			c.Endpoints.Ecs = profile.Endpoint
+		}
+
+		return c.profileCredential(profile, 1)
+	}
+
+	return c.defaultCredential()
+}
+
+// defaultCredential looks for the credentials in the environment variables,
+// the credentials file and the metadata of the ECS instance.
+func (c *AlicloudAccessConfig) defaultCredential() (auth.Credential, error) {
+	accessKeyId, accessKeySecret := os.Getenv(EnvAccessKeyId), os.Getenv(EnvAccessKeySecret)
+	if accessKeyId != "" || accessKeySecret != "" {
+		if accessKeyId == "" || accessKeySecret == "" {
+			return nil, fmt.Errorf("Both %s and %s must be set", EnvAccessKeyId, EnvAccessKeySecret)
+		}
+		return staticCredential(accessKeyId, accessKeySecret, os.Getenv(EnvSecurityToken)), nil
+	}
+
+	if providerArn := os.Getenv(EnvOIDCProviderArn); providerArn != "" {
+		return c.assumeRoleWithOIDC(&credentialProfile{
+			Name:            "environment",
+			RamRoleArn:      os.Getenv(EnvRoleArn),
+			RamSessionName:  os.Getenv(EnvRoleSessionName),
+			OIDCProviderArn: providerArn,
+			OIDCTokenFile:   os.Getenv(EnvOIDCTokenFile),
+		})
+	}
+
+	if uri := os.Getenv(EnvCredentialsURI); uri != "" {
+		return c.credentialFromURI(uri)
+	}
+
+	name := os.Getenv(EnvProfile)
+	if name == "" {
+		name = defaultProfileName
+	}
+	profile, err := loadCredentialsFileProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		return c.profileCredential(profile, 1)
+	}
+
+	roleName := os.Getenv(EnvEcsMetadata)
+	if roleName == "" {
+		roleName = detectEcsRamRole()
+	}
+	if roleName != "" {
+		return credentials.NewEcsRamRoleCredential(roleName), nil
+	}
+
+	return nil, fmt.Errorf("No credentials found. Please set access_key and secret_key, ram_role_name or profile, " +
+		"the ALIBABA_CLOUD_* environment variables or the ~/.alibabacloud/credentials file, or run Packer on an " +
+		"ECS instance with a RAM role")
+}
+
+// profileCredential returns the credential of the profile. The depth is the
+// number of profiles in the chain of `ChainableRamRoleArn` profiles.
+func (c *AlicloudAccessConfig) profileCredential(profile *credentialProfile, depth int) (auth.Credential, error) {
+	if depth > maxProfileChainSize {
+		return nil, fmt.Errorf("Profile %q: too many chained profiles, the source_profile chain may be a loop", profile.Name)
+	}
+
+	switch profile.Mode {
+	case "", ProfileModeAK:
+		if err := profile.require("access_key_id", profile.AccessKeyId, "access_key_secret", profile.AccessKeySecret); err != nil {
+			return nil, err
+		}
+		return credentials.NewAccessKeyCredential(profile.AccessKeyId, profile.AccessKeySecret), nil
+	case ProfileModeStsToken:
+		if err := profile.require("access_key_id", profile.AccessKeyId, "access_key_secret", profile.AccessKeySecret,
+			"sts_token", profile.StsToken); err != nil {
+			return nil, err
+		}
+		return credentials.NewStsTokenCredential(profile.AccessKeyId, profile.AccessKeySecret, profile.StsToken), nil
+	case ProfileModeEcsRamRole:
+		roleName := profile.RamRoleName
+		if roleName == "" {
+			roleName = detectEcsRamRole()
+		}
+		if roleName == "" {
+			return nil, fmt.Errorf("Profile %q: ram_role_name is required when Packer doesn't run on an ECS instance with a RAM role", profile.Name)
+		}
+		return credentials.NewEcsRamRoleCredential(roleName), nil
+	case ProfileModeRamRoleArn:
+		if err := profile.require("access_key_id", profile.AccessKeyId, "access_key_secret", profile.AccessKeySecret,
+			"ram_role_arn", profile.RamRoleArn); err != nil {
+			return nil, err
+		}
+		return &credentials.RamRoleArnCredential{
+			AccessKeyId:           profile.AccessKeyId,
+			AccessKeySecret:       profile.AccessKeySecret,
+			RoleArn:               profile.RamRoleArn,
+			RoleSessionName:       roleSessionName(profile.RamSessionName),
+			RoleSessionExpiration: profile.sessionExpiration(),
+			StsRegion:             profile.StsRegion,
+		}, nil
+	case ProfileModeChainableRamRoleArn:
+		if err := profile.require("source_profile", profile.SourceProfile, "ram_role_arn", profile.RamRoleArn); err != nil {
+			return nil, err
+		}
+
+		source, err := c.loadProfile(profile.SourceProfile)
+		if err != nil {
+			return nil, fmt.Errorf("Profile %q: %s", profile.Name, err)
+		}
+		sourceCredential, err := c.profileCredential(source, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return c.assumeRole(sourceCredential, profile)
+	case ProfileModeCredentialsURI:
+		if err := profile.require("credentials_uri", profile.CredentialsURI); err != nil {
+			return nil, err
+		}
+		return c.credentialFromURI(profile.CredentialsURI)
+	case ProfileModeOIDC:
+		return c.assumeRoleWithOIDC(profile)
+	default:
+		return nil, fmt.Errorf("Profile %q: unsupported mode %q", profile.Name, profile.Mode)
+	}
+}
+
+// assumeRole assumes the RAM role of the profile with the source credential.
+// The SDK refreshes the credential itself when the source credential is an
+// AccessKey, otherwise the role is assumed again by the session before its
+// credentials expire.
+func (c *AlicloudAccessConfig) assumeRole(source auth.Credential, profile *credentialProfile) (auth.Credential, error) {
+	if accessKey, ok := source.(*credentials.AccessKeyCredential); ok {
+		return &credentials.RamRoleArnCredential{
+			AccessKeyId:           accessKey.AccessKeyId,
+			AccessKeySecret:       accessKey.AccessKeySecret,
+			RoleArn:               profile.RamRoleArn,
+			RoleSessionName:       roleSessionName(profile.RamSessionName),
+			RoleSessionExpiration: profile.sessionExpiration(),
+			StsRegion:             profile.StsRegion,
+		}, nil
+	}
+
+	stsClient, err := c.newStsClient(source)
+	if err != nil {
+		return nil, fmt.Errorf("Profile %q: %s", profile.Name, err)
+	}
+
+	session, err := startAssumeRoleSession(stsClient, AssumeRoleConfig{
+		RoleArn:           profile.RamRoleArn,
+		SessionName:       roleSessionName(profile.RamSessionName),
+		SessionExpiration: profile.sessionExpiration(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Profile %q: %s", profile.Name, err)
+	}
+
+	return &sessionCredential{provider: session}, nil
+}
+
+// assumeRoleWithOIDC exchanges the OIDC token, e.g. the token of RAM Roles for
+// Service Accounts (RRSA) in ACK pods, for the credential of the RAM role. The
+// token file is read again whenever the credentials are refreshed, since the
+// token is rotated as well.
+func (c *AlicloudAccessConfig) assumeRoleWithOIDC(profile *credentialProfile) (auth.Credential, error) {
+	if err := profile.require("ram_role_arn", profile.RamRoleArn, "oidc_provider_arn", profile.OIDCProviderArn,
+		"oidc_token_file", profile.OIDCTokenFile); err != nil {
+		return nil, err
+	}
+
+	sessionName := roleSessionName(profile.RamSessionName)
+	return newExpiringSessionCredential(func() (SessionCredentials, time.Time, error) {
+		return c.oidcCredentials(profile, sessionName)
+	})
+}
+
+func (c *AlicloudAccessConfig) oidcCredentials(profile *credentialProfile, sessionName string) (SessionCredentials, time.Time, error) {
+	token, err := os.ReadFile(profile.OIDCTokenFile)
+	if err != nil {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Profile %q: error reading OIDC token file: %s", profile.Name, err)
+	}
+
+	form := url.Values{}
+	form.Set("Action", "AssumeRoleWithOIDC")
+	form.Set("Format", "JSON")
+	form.Set("Version", "2015-04-01")
+	form.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	form.Set("RoleArn", profile.RamRoleArn)
+	form.Set("OIDCProviderArn", profile.OIDCProviderArn)
+	form.Set("OIDCToken", strings.TrimSpace(string(token)))
+	form.Set("RoleSessionName", sessionName)
+	form.Set("DurationSeconds", fmt.Sprintf("%d", profile.sessionExpiration()))
+
+	client, err := c.httpClient()
+	if err != nil {
+		return SessionCredentials{}, time.Time{}, err
+	}
+
+	endpoint := stsEndpoint
+	if c.Endpoints.Sts != "" {
+		endpoint = "https://" + strings.TrimPrefix(c.Endpoints.Sts, "https://")
+	}
+	response, err := client.PostForm(endpoint, form)
+	if err != nil {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Profile %q: error assuming role %s with OIDC: %s", profile.Name, profile.RamRoleArn, err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Code        string          `json:"Code"`
+		Message     string          `json:"Message"`
+		Credentials sts.Credentials `json:"Credentials"`
+	}
+	if err := decodeCredentialResponse(response, &result); err != nil {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Profile %q: error assuming role %s with OIDC: %s",
+			profile.Name, profile.RamRoleArn, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Profile %q: error assuming role %s with OIDC: %s: %s",
+			profile.Name, profile.RamRoleArn, result.Code, result.Message)
+	}
+
+	stsCredentials := result.Credentials
+	if stsCredentials.AccessKeyId == "" || stsCredentials.AccessKeySecret == "" || stsCredentials.SecurityToken == "" {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Profile %q: error assuming role %s with OIDC: the response has no credentials",
+			profile.Name, profile.RamRoleArn)
+	}
+
+	expiration, err := time.Parse(time.RFC3339, stsCredentials.Expiration)
+	if err != nil {
+		expiration = time.Now().Add(time.Duration(profile.sessionExpiration()) * time.Second)
+	}
+	return SessionCredentials{
+		AccessKeyId:     stsCredentials.AccessKeyId,
+		AccessKeySecret: stsCredentials.AccessKeySecret,
+		SecurityToken:   stsCredentials.SecurityToken,
+	}, expiration, nil
+}
+
+// credentialFromURI gets the credential from the credentials URI, which
+// returns it in JSON with a `Success` code. The credential is got again
+// before its `Expiration`, or periodically if the response has none.
+func (c *AlicloudAccessConfig) credentialFromURI(uri string) (auth.Credential, error) {
+	return newExpiringSessionCredential(func() (SessionCredentials, time.Time, error) {
+		return c.uriCredentials(uri)
+	})
+}
+
+func (c *AlicloudAccessConfig) uriCredentials(uri string) (SessionCredentials, time.Time, error) {
+	client, err := c.httpClient()
+	if err != nil {
+		return SessionCredentials{}, time.Time{}, err
+	}
+
+	response, err := client.Get(uri)
+	if err != nil {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Error getting credentials from %s: %s", uri, err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Code            string `json:"Code"`
+		AccessKeyId     string `json:"AccessKeyId"`
+		AccessKeySecret string `json:"AccessKeySecret"`
+		SecurityToken   string `json:"SecurityToken"`
+		Expiration      string `json:"Expiration"`
+	}
+	if err := decodeCredentialResponse(response, &result); err != nil {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Error getting credentials from %s: %s", uri, err)
+	}
+	if response.StatusCode != http.StatusOK || result.Code != "Success" {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Error getting credentials from %s: status %d, code %q",
+			uri, response.StatusCode, result.Code)
+	}
+	if result.AccessKeyId == "" || result.AccessKeySecret == "" {
+		return SessionCredentials{}, time.Time{}, fmt.Errorf("Error getting credentials from %s: the response has no AccessKeyId or AccessKeySecret", uri)
+	}
+
+	expiration, err := time.Parse(time.RFC3339, result.Expiration)
+	if err != nil {
+		expiration = time.Now().Add(DefaultRoleSessionExpiration * time.Second)
+	}
+	return SessionCredentials{
+		AccessKeyId:     result.AccessKeyId,
+		AccessKeySecret: result.AccessKeySecret,
+		SecurityToken:   result.SecurityToken,
+	}, expiration, nil
+}
+
+func decodeCredentialResponse(response *http.Response, result interface{}) error {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("invalid response with status %d: %s", response.StatusCode, err)
+	}
+	return nil
+}
+
+// detectEcsRamRole returns the RAM role of the ECS instance which runs Packer,
+// or an empty string if it doesn't run on an ECS instance with a RAM role.
+func detectEcsRamRole() string {
+	// The metadata service is only reachable from the instance itself, so the
+	// proxy is never used
+	client := &http.Client{
+		Timeout:   ecsMetadataProbeTimeout,
+		Transport: &http.Transport{Proxy: nil},
+	}
+	response, err := client.Get(ecsMetadataRoleURL)
+	if err != nil {
+		return ""
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.Split(string(body), "\n")[0])
+}
+
+// loadProfile loads the profile from the Alibaba Cloud CLI configuration
+// file, or from the credentials file if the CLI configuration doesn't have it.
+func (c *AlicloudAccessConfig) loadProfile(name string) (*credentialProfile, error) {
+	profile, err := loadCliProfile(c.cliConfigurationPath(), name)
+	if err != nil || profile != nil {
+		return profile, err
+	}
+
+	profile, err = loadCredentialsFileProfile(name)
+	if err != nil || profile != nil {
+		return profile, err
+	}
+
+	return nil, fmt.Errorf("Profile %q not found in %s or %s", name, c.cliConfigurationPath(), defaultCredentialsFilePath())
+}
+
+func (c *AlicloudAccessConfig) cliConfigurationPath() string {
+	if c.AlicloudSharedCredentialsFile != "" {
+		if path, err := homedir.Expand(c.AlicloudSharedCredentialsFile); err == nil {
+			return path
+		}
+		return c.AlicloudSharedCredentialsFile
+	}
+
+	return fmt.Sprintf("%s/.aliyun/config.json", homePath())
+}
+
+// loadCliProfile returns nil if the file or the profile doesn't exist.
+func loadCliProfile(path string, name string) (*credentialProfile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	var config cliConfiguration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+
+	for i := range config.Profiles {
+		if config.Profiles[i].Name == name {
+			return &config.Profiles[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// iniCredentialTypes maps the credential types of the credentials file to the
+// profile modes.
+var iniCredentialTypes = map[string]string{
+	"access_key":      ProfileModeAK,
+	"sts":             ProfileModeStsToken,
+	"ecs_ram_role":    ProfileModeEcsRamRole,
+	"ram_role_arn":    ProfileModeRamRoleArn,
+	"oidc_role_arn":   ProfileModeOIDC,
+	"credentials_uri": ProfileModeCredentialsURI,
+}
+
+// loadCredentialsFileProfile loads the section of the credentials file,
+// `~/.alibabacloud/credentials` by default. It returns nil if the default
+// file or the section doesn't exist.
+func loadCredentialsFileProfile(name string) (*credentialProfile, error) {
+	path := os.Getenv(EnvCredentialsFile)
+	if path == "" {
+		path = defaultCredentialsFilePath()
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+
+	file, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading credentials file %s: %s", path, err)
+	}
+
+	section, err := file.GetSection(name)
+	if err != nil {
+		return nil, nil
+	}
+
+	credentialType := section.Key("type").String()
+	mode, ok := iniCredentialTypes[credentialType]
+	if !ok {
+		return nil, fmt.Errorf("Profile %q of %s: unsupported type %q", name, path, credentialType)
+	}
+
+	return &credentialProfile{
+		Name:            name,
+		Mode:            mode,
+		AccessKeyId:     section.Key("access_key_id").String(),
+		AccessKeySecret: section.Key("access_key_secret").String(),
+		StsToken:        section.Key("security_token").String(),
+		RamRoleName:     section.Key("role_name").String(),
+		RamRoleArn:      section.Key("role_arn").String(),
+		RamSessionName:  section.Key("role_session_name").String(),
+		CredentialsURI:  section.Key("credentials_uri").String(),
+		OIDCProviderArn: section.Key("oidc_provider_arn").String(),
+		OIDCTokenFile:   section.Key("oidc_token_file_path").String(),
+	}, nil
+}
+
+func defaultCredentialsFilePath() string {
+	return fmt.Sprintf("%s/.alibabacloud/credentials", homePath())
+}
+
+func homePath() string {
+	if runtime.GOOS == "windows" {
+		return os.Getenv("USERPROFILE")
+	}
+	return os.Getenv("HOME")
+}
+
+// require returns an error naming the first of the key/value pairs which has
+// an empty value.
+func (p *credentialProfile) require(keyValues ...string) error {
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] == "" {
+			mode := p.Mode
+			if mode == "" {
+				mode = ProfileModeAK
+			}
+			return fmt.Errorf("Profile %q: %s is required for mode %s", p.Name, keyValues[i], mode)
+		}
+	}
+	return nil
+}
+
+func (p *credentialProfile) sessionExpiration() int {
+	if p.ExpiredSeconds > 0 {
+		return p.ExpiredSeconds
+	}
+	return DefaultRoleSessionExpiration
+}
+
+func staticCredential(accessKeyId string, accessKeySecret string, securityToken string) auth.Credential {
+	if securityToken != "" {
+		return credentials.NewStsTokenCredential(accessKeyId, accessKeySecret, securityToken)
+	}
+	return credentials.NewAccessKeyCredential(accessKeyId, accessKeySecret)
+}
+
+func roleSessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID()[:8])
+}
@@ -0,0 +1,390 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+)
+
+// testCredentialEnvironment clears the environment variables of the
+// credentials and points the home directory and the metadata service to
+// the test server, if any.
+func testCredentialEnvironment(t *testing.T, metadataURL string) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	for _, env := range []string{EnvAccessKeyId, EnvAccessKeySecret, EnvSecurityToken, EnvRoleArn, EnvRoleSessionName,
+		EnvOIDCProviderArn, EnvOIDCTokenFile, EnvCredentialsURI, EnvCredentialsFile, EnvEcsMetadata, EnvProfile,
+		"ALICLOUD_ACCESS_KEY", "ALICLOUD_SECRET_KEY", "ALICLOUD_PROFILE", "ALICLOUD_SHARED_CREDENTIALS_FILE"} {
+		t.Setenv(env, "")
+	}
+
+	originalMetadataURL := ecsMetadataRoleURL
+	ecsMetadataRoleURL = metadataURL
+	if metadataURL == "" {
+		ecsMetadataRoleURL = "http://127.0.0.1:0/"
+	}
+	t.Cleanup(func() { ecsMetadataRoleURL = originalMetadataURL })
+
+	return home
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("error creating directory: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("error writing %s: %s", path, err)
+	}
+}
+
+func TestResolveCredential_template(t *testing.T) {
+	testCredentialEnvironment(t, "")
+
+	c := &AlicloudAccessConfig{AlicloudAccessKey: "ak", AlicloudSecretKey: "sk"}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if _, ok := credential.(*credentials.AccessKeyCredential); !ok {
+		t.Fatalf("expected an AccessKey credential, got %T", credential)
+	}
+
+	c.SecurityToken = "token"
+	credential, _ = c.resolveCredential()
+	if _, ok := credential.(*credentials.StsTokenCredential); !ok {
+		t.Fatalf("expected an STS token credential, got %T", credential)
+	}
+
+	c.AlicloudRamRoleArn = "acs:ram::123456:role/packer"
+	credential, _ = c.resolveCredential()
+	roleArnCredential, ok := credential.(*credentials.RamRoleArnCredential)
+	if !ok {
+		t.Fatalf("expected a RAM role ARN credential, got %T", credential)
+	}
+	if roleArnCredential.RoleSessionName == "" {
+		t.Fatalf("the role session name should have a default value")
+	}
+
+	c.AlicloudRamRole = "packer"
+	credential, _ = c.resolveCredential()
+	if _, ok := credential.(*credentials.EcsRamRoleCredential); !ok {
+		t.Fatalf("expected an ECS RAM role credential, got %T", credential)
+	}
+}
+
+func TestResolveCredential_environment(t *testing.T) {
+	testCredentialEnvironment(t, "")
+
+	c := &AlicloudAccessConfig{}
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "No credentials found") {
+		t.Fatalf("expected no credentials error, got: %v", err)
+	}
+
+	t.Setenv(EnvAccessKeyId, "ak")
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), EnvAccessKeySecret) {
+		t.Fatalf("expected missing secret error, got: %v", err)
+	}
+
+	t.Setenv(EnvAccessKeySecret, "sk")
+	t.Setenv(EnvSecurityToken, "token")
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	stsCredential, ok := credential.(*credentials.StsTokenCredential)
+	if !ok || stsCredential.AccessKeyStsToken != "token" {
+		t.Fatalf("expected an STS token credential, got %#v", credential)
+	}
+}
+
+func TestResolveCredential_credentialsFile(t *testing.T) {
+	home := testCredentialEnvironment(t, "")
+	writeTestFile(t, filepath.Join(home, ".alibabacloud", "credentials"), `
+[default]
+type = access_key
+access_key_id = ak
+access_key_secret = sk
+
+[role]
+type = ram_role_arn
+access_key_id = ak
+access_key_secret = sk
+role_arn = acs:ram::123456:role/packer
+role_session_name = session
+
+[unknown]
+type = bearer
+`)
+
+	c := &AlicloudAccessConfig{}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if _, ok := credential.(*credentials.AccessKeyCredential); !ok {
+		t.Fatalf("expected an AccessKey credential, got %T", credential)
+	}
+
+	c.AlicloudProfile = "role"
+	credential, err = c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	roleArnCredential, ok := credential.(*credentials.RamRoleArnCredential)
+	if !ok || roleArnCredential.RoleSessionName != "session" {
+		t.Fatalf("expected a RAM role ARN credential, got %#v", credential)
+	}
+
+	c.AlicloudProfile = "unknown"
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), `unsupported type "bearer"`) {
+		t.Fatalf("expected unsupported type error, got: %v", err)
+	}
+
+	c.AlicloudProfile = "missing"
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected profile not found error, got: %v", err)
+	}
+}
+
+func TestResolveCredential_cliProfile(t *testing.T) {
+	home := testCredentialEnvironment(t, "")
+	path := filepath.Join(home, ".aliyun", "config.json")
+	writeTestFile(t, path, `{
+  "current": "default",
+  "profiles": [
+    {"name": "default", "mode": "AK", "access_key_id": "ak", "access_key_secret": "sk", "region_id": "cn-beijing"},
+    {"name": "chained", "mode": "ChainableRamRoleArn", "source_profile": "default", "ram_role_arn": "acs:ram::123456:role/packer"},
+    {"name": "loop", "mode": "ChainableRamRoleArn", "source_profile": "loop", "ram_role_arn": "acs:ram::123456:role/packer"},
+    {"name": "incomplete", "mode": "StsToken", "access_key_id": "ak", "access_key_secret": "sk"},
+    {"name": "unknown", "mode": "External"}
+  ]
+}`)
+
+	c := &AlicloudAccessConfig{AlicloudProfile: "default"}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if _, ok := credential.(*credentials.AccessKeyCredential); !ok {
+		t.Fatalf("expected an AccessKey credential, got %T", credential)
+	}
+	if c.AlicloudRegion != "cn-beijing" {
+		t.Fatalf("the region should be read from the profile, got %q", c.AlicloudRegion)
+	}
+
+	c = &AlicloudAccessConfig{AlicloudProfile: "chained"}
+	credential, err = c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if _, ok := credential.(*credentials.RamRoleArnCredential); !ok {
+		t.Fatalf("expected a RAM role ARN credential, got %T", credential)
+	}
+
+	errorCases := map[string]string{
+		"loop":       "too many chained profiles",
+		"incomplete": "sts_token is required for mode StsToken",
+		"unknown":    `unsupported mode "External"`,
+	}
+	for profile, message := range errorCases {
+		c = &AlicloudAccessConfig{AlicloudProfile: profile}
+		if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("profile %s: expected error containing %q, got: %v", profile, message, err)
+		}
+	}
+
+	writeTestFile(t, path, `{"profiles": {"name": "default"}}`)
+	c = &AlicloudAccessConfig{AlicloudProfile: "default"}
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "Error parsing") {
+		t.Fatalf("expected parse error, got: %v", err)
+	}
+}
+
+func TestResolveCredential_credentialsURI(t *testing.T) {
+	testCredentialEnvironment(t, "")
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"Code": "Failure"}`)
+			return
+		}
+		calls++
+		fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "ak-%d", "AccessKeySecret": "sk", "SecurityToken": "token", "Expiration": %q}`,
+			calls, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	t.Setenv(EnvCredentialsURI, server.URL)
+	c := &AlicloudAccessConfig{}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	session, ok := credential.(*sessionCredential)
+	if !ok {
+		t.Fatalf("expected a session credential, got %T", credential)
+	}
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-1" || calls != 1 {
+		t.Fatalf("the credentials shouldn't be refreshed before they expire: %#v", sessionCredentials)
+	}
+
+	session.provider.(*expiringSession).refreshAt = time.Now().Add(-time.Second)
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-2" {
+		t.Fatalf("the credentials should be refreshed before they expire: %#v", sessionCredentials)
+	}
+
+	t.Setenv(EnvCredentialsURI, server.URL+"/fail")
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Fatalf("expected status error, got: %v", err)
+	}
+}
+
+func TestResolveCredential_oidc(t *testing.T) {
+	home := testCredentialEnvironment(t, "")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRoleWithOIDC" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code": "MissingAction", "Message": "bad request"}`)
+			return
+		}
+		if r.Form.Get("OIDCToken") == "expired-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code": "InvalidParameter.OIDCToken", "Message": "invalid token"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"Credentials": {"AccessKeyId": "ak-%s", "AccessKeySecret": "sk", "SecurityToken": "token"}}`,
+			r.Form.Get("OIDCToken"))
+	}))
+	defer server.Close()
+
+	originalEndpoint := stsEndpoint
+	stsEndpoint = server.URL
+	defer func() { stsEndpoint = originalEndpoint }()
+
+	tokenFile := filepath.Join(home, "token")
+	writeTestFile(t, tokenFile, "oidc-token\n")
+	t.Setenv(EnvRoleArn, "acs:ram::123456:role/packer")
+	t.Setenv(EnvOIDCProviderArn, "acs:ram::123456:oidc-provider/ack")
+	t.Setenv(EnvOIDCTokenFile, tokenFile)
+
+	c := &AlicloudAccessConfig{}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	session, ok := credential.(*sessionCredential)
+	if !ok {
+		t.Fatalf("expected a session credential, got %T", credential)
+	}
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-oidc-token" {
+		t.Fatalf("unexpected credentials: %#v", sessionCredentials)
+	}
+
+	// The rotated token is read when the credentials are refreshed
+	writeTestFile(t, tokenFile, "rotated-token")
+	session.provider.(*expiringSession).refreshAt = time.Now().Add(-time.Second)
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-rotated-token" {
+		t.Fatalf("the credentials should be refreshed with the rotated token: %#v", sessionCredentials)
+	}
+
+	writeTestFile(t, tokenFile, "expired-token")
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "InvalidParameter.OIDCToken") {
+		t.Fatalf("expected invalid token error, got: %v", err)
+	}
+
+	t.Setenv(EnvOIDCTokenFile, "")
+	if _, err := c.resolveCredential(); err == nil || !strings.Contains(err.Error(), "oidc_token_file is required") {
+		t.Fatalf("expected missing token file error, got: %v", err)
+	}
+}
+
+func TestResolveCredential_ecsMetadata(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "packer-role")
+	}))
+	defer server.Close()
+	testCredentialEnvironment(t, server.URL)
+
+	c := &AlicloudAccessConfig{}
+	if err := c.Config(); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if calls != 0 {
+		t.Fatal("the metadata service should only be requested when the client is created")
+	}
+
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	ramRoleCredential, ok := credential.(*credentials.EcsRamRoleCredential)
+	if !ok || ramRoleCredential.RoleName != "packer-role" {
+		t.Fatalf("expected an ECS RAM role credential, got %#v", credential)
+	}
+}
+
+func TestResolveCredential_chainedSession(t *testing.T) {
+	home := testCredentialEnvironment(t, "")
+
+	var calls int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRole" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code": "MissingAction", "Message": "bad request", "RequestId": "test"}`)
+			return
+		}
+		calls++
+		fmt.Fprintf(w, `{"Credentials": {"AccessKeyId": "ak-%d", "AccessKeySecret": "sk", "SecurityToken": "token", "Expiration": %q}}`,
+			calls, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	caBundle := filepath.Join(home, "ca.pem")
+	writeTestFile(t, caBundle, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	writeTestFile(t, filepath.Join(home, ".aliyun", "config.json"), `{
+  "profiles": [
+    {"name": "sts", "mode": "StsToken", "access_key_id": "ak", "access_key_secret": "sk", "sts_token": "token"},
+    {"name": "chained", "mode": "ChainableRamRoleArn", "source_profile": "sts", "ram_role_arn": "acs:ram::123456:role/packer"}
+  ]
+}`)
+
+	c := &AlicloudAccessConfig{
+		AlicloudProfile: "chained",
+		AlicloudRegion:  "cn-chained-test",
+		CABundle:        caBundle,
+		Endpoints:       EndpointsConfig{Sts: strings.TrimPrefix(server.URL, "https://")},
+	}
+	credential, err := c.resolveCredential()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	session, ok := credential.(*sessionCredential)
+	if !ok {
+		t.Fatalf("expected a session credential, got %T", credential)
+	}
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-1" || calls != 1 {
+		t.Fatalf("the role should be assumed once before the credentials expire: %#v", sessionCredentials)
+	}
+
+	session.provider.(*assumeRoleSession).refreshAt = time.Now().Add(-time.Second)
+	if sessionCredentials, _ := session.provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-2" {
+		t.Fatalf("the role should be assumed again before the credentials expire: %#v", sessionCredentials)
+	}
+}
@@ -28,6 +28,7 @@ type AlicloudDiskDevice struct {
 	//     -   cloud_efficiency - efficiency cloud disk
 	//     -   cloud_ssd - cloud SSD
 	//     -   cloud_essd - cloud ESSD
+	//     -   cloud_auto - ESSD AutoPL
 	DiskCategory string `mapstructure:"disk_category" required:"false"`
 	// Size of the system disk, measured in GiB. Value
 	// range: [20, 500]. The specified value must be equal to or greater
@@ -57,6 +58,68 @@ type AlicloudDiskDevice struct {
 	// it was in the source image. Please refer to Introduction of ECS disk
 	// encryption for more details.
 	Encrypted config.Trilean `mapstructure:"disk_encrypted" required:"false"`
+	// The ID of the KMS key which is used to encrypt the disk. It requires
+	// `disk_encrypted` to be `true`. The default service key is used if it
+	// is not set.
+	KMSKeyId string `mapstructure:"disk_kms_key_id" required:"false"`
+	// The performance level of an ESSD (`cloud_essd`) disk, `PL0`, `PL1`,
+	// `PL2` or `PL3`. Defaults to `PL1`.
+	PerformanceLevel string `mapstructure:"disk_performance_level" required:"false"`
+	// The provisioned read/write IOPS of an ESSD AutoPL (`cloud_auto`) disk,
+	// in addition to the baseline performance of its size.
+	ProvisionedIops int `mapstructure:"disk_provisioned_iops" required:"false"`
+	// Whether performance bursting is enabled for an ESSD AutoPL
+	// (`cloud_auto`) disk.
+	BurstingEnabled bool `mapstructure:"disk_bursting_enabled" required:"false"`
+	// The ID of the automatic snapshot policy which is applied to the disk.
+	AutoSnapshotPolicyId string `mapstructure:"disk_auto_snapshot_policy_id" required:"false"`
+}
+
+// encryptNatively encrypts the disk with the KMS key when the instance is
+// created, unless the disk has its own key.
+func (d *AlicloudDiskDevice) encryptNatively(name string, kmsKeyId string) []error {
+	if d.Encrypted.False() {
+		return []error{fmt.Errorf("%s: disk_encrypted can't be false when image_encryption_mode is '%s'", name, ImageEncryptionModeNative)}
+	}
+
+	d.Encrypted = config.TriTrue
+	if d.KMSKeyId == "" {
+		d.KMSKeyId = kmsKeyId
+	}
+	return nil
+}
+
+// Prepare validates the combinations of the disk options. The name is the
+// option which holds the disk, e.g. `system_disk_mapping`.
+func (d *AlicloudDiskDevice) Prepare(name string) []error {
+	var errs []error
+	if d.KMSKeyId != "" && !d.Encrypted.True() {
+		errs = append(errs, fmt.Errorf("%s: disk_kms_key_id requires disk_encrypted to be true", name))
+	}
+
+	if d.PerformanceLevel != "" {
+		if d.DiskCategory != DiskCategoryCloudEssd {
+			errs = append(errs, fmt.Errorf("%s: disk_performance_level can only be set for %s disks", name, DiskCategoryCloudEssd))
+		} else if !ContainsInArray(DiskPerformanceLevels, d.PerformanceLevel) {
+			errs = append(errs, fmt.Errorf("%s: disk_performance_level should be one of %s", name, strings.Join(DiskPerformanceLevels, ", ")))
+		}
+	}
+
+	if d.ProvisionedIops < 0 {
+		errs = append(errs, fmt.Errorf("%s: disk_provisioned_iops can't be negative", name))
+	} else if d.ProvisionedIops > 0 && d.DiskCategory != DiskCategoryCloudAuto {
+		errs = append(errs, fmt.Errorf("%s: disk_provisioned_iops can only be set for %s disks", name, DiskCategoryCloudAuto))
+	}
+
+	if d.BurstingEnabled && d.DiskCategory != DiskCategoryCloudAuto {
+		errs = append(errs, fmt.Errorf("%s: disk_bursting_enabled can only be set for %s disks", name, DiskCategoryCloudAuto))
+	}
+
+	if d.AutoSnapshotPolicyId != "" && !strings.HasPrefix(d.AutoSnapshotPolicyId, "sp-") {
+		errs = append(errs, fmt.Errorf("%s: disk_auto_snapshot_policy_id should begin with 'sp-'", name))
+	}
+
+	return errs
 }
 
 // The "AlicloudDiskDevices" object is used to define disk mappings for your
@@ -130,9 +193,23 @@ type AlicloudImageConfig struct {
 	// copied if image_copy_regions is specified. If this option is set to
 	// true, a temporary image will be created from the provisioned instance in
 	// the main region and an encrypted copy will be generated in the same
-	// region. By default, Packer will keep the encryption setting to what it
-	// was in the source image.
+	// region, unless `image_encryption_mode` is `native`. By default, Packer
+	// will keep the encryption setting to what it was in the source image.
 	ImageEncrypted config.Trilean `mapstructure:"image_encrypted" required:"false"`
+	// How the image is encrypted when `image_encrypted` is set. Valid values
+	// are:
+	//     -   copy - the image is created from the instance and copied in the
+	//         same region with the encryption setting and `kms_key_id`. This
+	//         is needed to re-key an image whose disks are already encrypted
+	//         with another key, or to decrypt it.
+	//     -   native - the system disk and the disks of `image_disk_mappings`
+	//         are encrypted with `kms_key_id` when the instance is created,
+	//         so the image is encrypted as soon as it is created, without a
+	//         temporary unencrypted image. It requires `image_encrypted` to
+	//         be `true`.
+	//
+	// Defaults to `copy`.
+	ImageEncryptionMode string `mapstructure:"image_encryption_mode" required:"false"`
 	// If this value is true, when the target image names including those
 	// copied are duplicated with existing images, it will delete the existing
 	// images and then create the target images, otherwise, the creation will
@@ -168,12 +245,24 @@ type AlicloudImageConfig struct {
 	AlicloudTargetImageFamily string `mapstructure:"target_image_family" required:"false"`
 	// The boot mode of the user-defined image, it should to be one of 'BIOS', 'UEFI' or 'UEFI-Preferred'.
 	AlicloudBootMode string `mapstructure:"boot_mode" required:"false"`
+	// The metadata access mode supported by the image. If it is set to `v2`,
+	// instances created from the image only allow token-based (IMDSv2) access
+	// to the metadata service. By default, both modes are allowed.
+	AlicloudImageImdsSupport string `mapstructure:"imds_support" required:"false"`
 	// Copy to the destination KMS key ID array
 	AlicloudKMSKeyCopyIds []string `mapstructure:"kms_key_copy_ids" required:"false"`
-	// The source image KMS key ID used to encrypt the disk.
+	// The source image KMS key ID used to encrypt the disk. When
+	// `image_encryption_mode` is `native`, it is the default key of the disks
+	// which don't set `disk_kms_key_id`.
 	AlicloudKMSKeyId string `mapstructure:"kms_key_id" required:"false"`
 }
 
+// EncryptImageByCopy returns true if the encryption of the image is changed by
+// copying it in the same region after it is created.
+func (c *AlicloudImageConfig) EncryptImageByCopy() bool {
+	return c.ImageEncrypted != config.TriUnset && c.ImageEncryptionMode != ImageEncryptionModeNative
+}
+
 func (c *AlicloudImageConfig) Prepare(ctx *interpolate.Context) []error {
 	var errs []error
 	errs = append(errs, c.AlicloudImageTag.CopyOn(&c.AlicloudImageTags)...)
@@ -207,6 +296,29 @@ func (c *AlicloudImageConfig) Prepare(ctx *interpolate.Context) []error {
 			errs = append(errs, fmt.Errorf("boot_mode should to be one of 'BIOS', 'UEFI' or 'UEFI-Preferred'"))
 		}
 	}
+	if c.AlicloudImageImdsSupport != "" && c.AlicloudImageImdsSupport != ImageImdsSupportV2 {
+		errs = append(errs, fmt.Errorf("imds_support should be '%s'", ImageImdsSupportV2))
+	}
+	switch c.ImageEncryptionMode {
+	case "", ImageEncryptionModeCopy:
+	case ImageEncryptionModeNative:
+		if !c.ImageEncrypted.True() {
+			errs = append(errs, fmt.Errorf("image_encryption_mode '%s' requires image_encrypted to be true", ImageEncryptionModeNative))
+			break
+		}
+
+		errs = append(errs, c.ECSSystemDiskMapping.encryptNatively("system_disk_mapping", c.AlicloudKMSKeyId)...)
+		for i := range c.ECSImagesDiskMappings {
+			errs = append(errs, c.ECSImagesDiskMappings[i].encryptNatively(fmt.Sprintf("image_disk_mappings[%d]", i), c.AlicloudKMSKeyId)...)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("image_encryption_mode should be '%s' or '%s'", ImageEncryptionModeCopy, ImageEncryptionModeNative))
+	}
+
+	errs = append(errs, c.ECSSystemDiskMapping.Prepare("system_disk_mapping")...)
+	for i := range c.ECSImagesDiskMappings {
+		errs = append(errs, c.ECSImagesDiskMappings[i].Prepare(fmt.Sprintf("image_disk_mappings[%d]", i))...)
+	}
 	if len(c.AlicloudImageDestinationRegions) > 0 {
 		regionSet := make(map[string]struct{})
 		regions := make([]string, 0, len(c.AlicloudImageDestinationRegions))
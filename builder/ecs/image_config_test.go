@@ -5,6 +5,8 @@ package ecs
 
 import (
 	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 )
 
 func testAlicloudImageConfig() *AlicloudImageConfig {
@@ -152,3 +154,106 @@ func TestECSImageConfigPrepare_bootMode(t *testing.T) {
 		t.Fatalf("shouldn't have err: %s", err)
 	}
 }
+
+func TestECSImageConfigPrepare_imdsSupport(t *testing.T) {
+	c := testAlicloudImageConfig()
+
+	c.AlicloudImageImdsSupport = "v1"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	c.AlicloudImageImdsSupport = "v2"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+}
+
+func TestECSImageConfigPrepare_diskOptions(t *testing.T) {
+	c := testAlicloudImageConfig()
+	c.ECSSystemDiskMapping = AlicloudDiskDevice{
+		DiskCategory:     DiskCategoryCloudEssd,
+		PerformanceLevel: "PL2",
+		Encrypted:        config.TriTrue,
+		KMSKeyId:         "key-test",
+	}
+	c.ECSImagesDiskMappings = []AlicloudDiskDevice{
+		{
+			DiskCategory:         DiskCategoryCloudAuto,
+			ProvisionedIops:      5000,
+			BurstingEnabled:      true,
+			AutoSnapshotPolicyId: "sp-test",
+		},
+	}
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	c.ECSSystemDiskMapping.PerformanceLevel = "PL4"
+	c.ECSSystemDiskMapping.Encrypted = config.TriUnset
+	if err := c.Prepare(nil); len(err) != 2 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testAlicloudImageConfig()
+	c.ECSImagesDiskMappings = []AlicloudDiskDevice{
+		{
+			DiskCategory:         DiskCategoryCloudEssd,
+			PerformanceLevel:     "PL1",
+			ProvisionedIops:      5000,
+			BurstingEnabled:      true,
+			AutoSnapshotPolicyId: "test",
+		},
+	}
+	if err := c.Prepare(nil); len(err) != 3 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestECSImageConfigPrepare_encryptionMode(t *testing.T) {
+	c := testAlicloudImageConfig()
+	c.ImageEncryptionMode = ImageEncryptionModeNative
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("native mode without image_encrypted should have err: %s", err)
+	}
+
+	c = testAlicloudImageConfig()
+	c.ImageEncrypted = config.TriTrue
+	c.ImageEncryptionMode = ImageEncryptionModeNative
+	c.AlicloudKMSKeyId = "key-image"
+	c.ECSImagesDiskMappings = []AlicloudDiskDevice{
+		{DiskSize: 20},
+		{DiskSize: 20, KMSKeyId: "key-disk"},
+	}
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if c.EncryptImageByCopy() {
+		t.Fatal("image shouldn't be encrypted by copy in native mode")
+	}
+	if !c.ECSSystemDiskMapping.Encrypted.True() || c.ECSSystemDiskMapping.KMSKeyId != "key-image" {
+		t.Fatalf("system disk should be encrypted with the image key: %#v", c.ECSSystemDiskMapping)
+	}
+	if c.ECSImagesDiskMappings[0].KMSKeyId != "key-image" || c.ECSImagesDiskMappings[1].KMSKeyId != "key-disk" {
+		t.Fatalf("bad data disk keys: %#v", c.ECSImagesDiskMappings)
+	}
+
+	c.ECSImagesDiskMappings[0] = AlicloudDiskDevice{DiskSize: 20, Encrypted: config.TriFalse}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("unencrypted disk in native mode should have err: %s", err)
+	}
+
+	c = testAlicloudImageConfig()
+	c.ImageEncrypted = config.TriFalse
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if !c.EncryptImageByCopy() {
+		t.Fatal("image should be encrypted by copy by default")
+	}
+
+	c.ImageEncryptionMode = "rekey"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
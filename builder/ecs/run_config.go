@@ -6,10 +6,14 @@
 package ecs
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
@@ -17,6 +21,196 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 )
 
+var hostNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.\-]*[a-zA-Z0-9])?$`)
+
+// The "AlicloudResourceFilter" object is used by `vpc_filter`,
+// `vswitch_filter` and `security_group_filter` to select existing network
+// resources. A resource matches the filter when it matches all the criteria
+// which are set.
+type AlicloudResourceFilter struct {
+	// The name of the resource.
+	Name string `mapstructure:"name" required:"false"`
+	// Key/value pair tags that the resource must have.
+	Tags map[string]string `mapstructure:"tags" required:"false"`
+	// The CIDR block of the VPC or vswitch. It is not supported by
+	// `security_group_filter`.
+	CidrBlock string `mapstructure:"cidr_block" required:"false"`
+}
+
+func (f *AlicloudResourceFilter) Empty() bool {
+	return f.Name == "" && len(f.Tags) == 0 && f.CidrBlock == ""
+}
+
+// The "MetadataOptions" object configures the instance metadata service of
+// the build instance.
+type MetadataOptions struct {
+	// Whether the metadata service is enabled, `enabled` or `disabled`.
+	// Defaults to `enabled`.
+	HttpEndpoint string `mapstructure:"http_endpoint" required:"false"`
+	// Whether token-based (IMDSv2) metadata access is `required` or
+	// `optional`. Defaults to `optional`, which also allows IMDSv1 access.
+	HttpTokens string `mapstructure:"http_tokens" required:"false"`
+	// The maximum number of hops of the PUT requests which get a metadata
+	// token, from 1 to 64. Defaults to 1.
+	HttpPutResponseHopLimit int `mapstructure:"http_put_response_hop_limit" required:"false"`
+}
+
+func (o *MetadataOptions) Prepare() []error {
+	var errs []error
+	if o.HttpEndpoint != "" && o.HttpEndpoint != MetadataHttpEndpointEnabled && o.HttpEndpoint != MetadataHttpEndpointDisabled {
+		errs = append(errs, fmt.Errorf("metadata_options.http_endpoint should be '%s' or '%s'",
+			MetadataHttpEndpointEnabled, MetadataHttpEndpointDisabled))
+	}
+
+	if o.HttpTokens != "" && o.HttpTokens != MetadataHttpTokensRequired && o.HttpTokens != MetadataHttpTokensOptional {
+		errs = append(errs, fmt.Errorf("metadata_options.http_tokens should be '%s' or '%s'",
+			MetadataHttpTokensRequired, MetadataHttpTokensOptional))
+	}
+
+	if o.HttpPutResponseHopLimit != 0 &&
+		(o.HttpPutResponseHopLimit < MinMetadataHopLimit || o.HttpPutResponseHopLimit > MaxMetadataHopLimit) {
+		errs = append(errs, fmt.Errorf("metadata_options.http_put_response_hop_limit must be between %d and %d",
+			MinMetadataHopLimit, MaxMetadataHopLimit))
+	}
+
+	if o.HttpEndpoint == MetadataHttpEndpointDisabled && (o.HttpTokens != "" || o.HttpPutResponseHopLimit != 0) {
+		errs = append(errs, errors.New("metadata_options.http_tokens and http_put_response_hop_limit can't be set when http_endpoint is disabled"))
+	}
+
+	return errs
+}
+
+// The "InstanceTypeSelector" object chooses the instance type of the build
+// instance by its requirements, among the instance types which are available
+// in the region, or in `zone_id` if it is set.
+type InstanceTypeSelector struct {
+	// The minimum number of vCPUs of the instance type.
+	MinVCpus int `mapstructure:"min_vcpus" required:"false"`
+	// The minimum memory size of the instance type, in GiB.
+	MinMemory float64 `mapstructure:"min_memory" required:"false"`
+	// The architecture of the instance type, `x86_64` or `arm64`. Defaults
+	// to the architecture of the source image, which it must match.
+	Architecture string `mapstructure:"architecture" required:"false"`
+	// The instance type families which are allowed, such as `ecs.g7` or
+	// `ecs.c7`. All families are allowed if it is not set.
+	InstanceTypeFamilies []string `mapstructure:"instance_type_families" required:"false"`
+	// Whether to only choose burstable instance types (`true`), or to
+	// exclude them (`false`). Both are allowed if it is not set.
+	Burstable config.Trilean `mapstructure:"burstable" required:"false"`
+	// How to choose among the matching instance types: `cheapest` chooses
+	// the one with the lowest hourly price among the 10 smallest ones, by
+	// number of vCPUs and memory size, and `newest` chooses the smallest one
+	// of the latest generation. Defaults to `cheapest`.
+	OrderBy string `mapstructure:"order_by" required:"false"`
+}
+
+func (s *InstanceTypeSelector) Empty() bool {
+	return s.MinVCpus == 0 && s.MinMemory == 0 && s.Architecture == "" && len(s.InstanceTypeFamilies) == 0 &&
+		s.Burstable == config.TriUnset && s.OrderBy == ""
+}
+
+func (s *InstanceTypeSelector) Prepare() []error {
+	var errs []error
+	if s.MinVCpus < 0 {
+		errs = append(errs, errors.New("instance_type_selector.min_vcpus can't be negative"))
+	}
+
+	if s.MinMemory < 0 {
+		errs = append(errs, errors.New("instance_type_selector.min_memory can't be negative"))
+	}
+
+	if s.Architecture != "" && s.Architecture != ImageArchitectureX86_64 && s.Architecture != ImageArchitectureArm64 {
+		errs = append(errs, fmt.Errorf("instance_type_selector.architecture should be '%s' or '%s'",
+			ImageArchitectureX86_64, ImageArchitectureArm64))
+	}
+
+	if s.OrderBy == "" {
+		s.OrderBy = InstanceTypeOrderCheapest
+	}
+	if s.OrderBy != InstanceTypeOrderCheapest && s.OrderBy != InstanceTypeOrderNewest {
+		errs = append(errs, fmt.Errorf("instance_type_selector.order_by should be '%s' or '%s'",
+			InstanceTypeOrderCheapest, InstanceTypeOrderNewest))
+	}
+
+	return errs
+}
+
+// The "TimeoutsConfig" object sets how long the builder and the import
+// post-processor wait for the resources. The timeouts are durations such as
+// `30s`, `10m` or `1h30m`.
+type TimeoutsConfig struct {
+	// The timeout of creating and starting the instance. Defaults to `30m`.
+	InstanceStart time.Duration `mapstructure:"instance_start" required:"false"`
+	// The timeout of stopping and deleting the instance. Defaults to `30m`.
+	InstanceStop time.Duration `mapstructure:"instance_stop" required:"false"`
+	// The timeout of creating the image. Defaults to
+	// `wait_snapshot_ready_timeout` if it is set, or `1h`.
+	ImageCreate time.Duration `mapstructure:"image_create" required:"false"`
+	// The timeout of copying the image to each destination region. Defaults
+	// to `wait_copying_image_ready_timeout` if it is set, or `1h`.
+	ImageCopy time.Duration `mapstructure:"image_copy" required:"false"`
+	// The timeout of creating the snapshots. Defaults to
+	// `wait_snapshot_ready_timeout` if it is set, or `1h`.
+	Snapshot time.Duration `mapstructure:"snapshot" required:"false"`
+	// The timeout of allocating, associating and unassociating the EIPs.
+	// Defaults to `3m`.
+	Eip time.Duration `mapstructure:"eip" required:"false"`
+	// The timeout of creating and deleting the network resources: the VPC,
+	// the vswitch, the NAT gateway and the security group, and of waiting for
+	// the temporary RAM role. Defaults to `3m`.
+	Network time.Duration `mapstructure:"network" required:"false"`
+	// The timeout of importing the image by the import post-processor.
+	// Defaults to `1h`.
+	Import time.Duration `mapstructure:"import" required:"false"`
+	// The timeout of connecting the communicator to the instance, which is
+	// used as `ssh_timeout` or `winrm_timeout` unless they are set.
+	Communicator time.Duration `mapstructure:"communicator" required:"false"`
+	// The interval between the queries of the status of the resources.
+	// Defaults to `5s`.
+	PollInterval time.Duration `mapstructure:"poll_interval" required:"false"`
+}
+
+type timeoutValue struct {
+	name     string
+	value    *time.Duration
+	fallback time.Duration
+}
+
+func (t *TimeoutsConfig) values() []timeoutValue {
+	shortTimeout := time.Duration(ALICLOUD_DEFAULT_SHORT_TIMEOUT) * time.Second
+	timeout := time.Duration(ALICLOUD_DEFAULT_TIMEOUT) * time.Second
+	longTimeout := time.Duration(ALICLOUD_DEFAULT_LONG_TIMEOUT) * time.Second
+
+	return []timeoutValue{
+		{"instance_start", &t.InstanceStart, timeout},
+		{"instance_stop", &t.InstanceStop, timeout},
+		{"image_create", &t.ImageCreate, longTimeout},
+		{"image_copy", &t.ImageCopy, longTimeout},
+		{"snapshot", &t.Snapshot, longTimeout},
+		{"eip", &t.Eip, shortTimeout},
+		{"network", &t.Network, shortTimeout},
+		{"import", &t.Import, longTimeout},
+		{"communicator", &t.Communicator, 0},
+		{"poll_interval", &t.PollInterval, defaultRetryInterval},
+	}
+}
+
+// Prepare validates the timeouts and sets the unset ones to their defaults.
+// The legacy wait_* options must be resolved before.
+func (t *TimeoutsConfig) Prepare() []error {
+	var errs []error
+	for _, timeout := range t.values() {
+		if *timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s can't be negative", timeout.name))
+		}
+		if *timeout.value == 0 {
+			*timeout.value = timeout.fallback
+		}
+	}
+
+	return errs
+}
+
 type RunConfig struct {
 	AssociatePublicIpAddress bool `mapstructure:"associate_public_ip_address"`
 	// ID of the zone to which the disk belongs.
@@ -31,8 +225,41 @@ type RunConfig struct {
 	// [Querying Instance Type
 	// Table](https://intl.aliyun.com/help/doc-detail/25620.htm?spm=a3c0i.o25499en.a3.6.Dr1bik)
 	// interface.
+	//
+	// It can be omitted when `launch_template_id` or `launch_template_name` is
+	// set and the launch template provides the instance type, or when
+	// `instance_type_selector` is set.
 	InstanceType string `mapstructure:"instance_type" required:"true"`
 	Description  string `mapstructure:"description"`
+	// Chooses the instance type by its requirements instead of
+	// `instance_type`. See the [Instance Type
+	// Selector](#instance-type-selector-configuration) section below. For
+	// example, to use the cheapest instance type with at least 2 vCPUs and
+	// 4 GiB of memory which isn't burstable:
+	//
+	// ```hcl
+	// instance_type_selector {
+	//   min_vcpus  = 2
+	//   min_memory = 4
+	//   burstable  = false
+	// }
+	// ```
+	InstanceTypeSelector InstanceTypeSelector `mapstructure:"instance_type_selector" required:"false"`
+	// The ID of the launch template to create the instance from. The options
+	// which are set explicitly, such as `instance_type`, the vswitch, the
+	// security groups and the system disk, override the values of the
+	// template. Without `vpc_id`, `vpc_filter`, `vswitch_id` or
+	// `vswitch_filter`, the vswitch of the template is used and no temporary
+	// VPC and vswitch are created. Likewise, without `security_group_id`,
+	// `security_group_ids` or `security_group_filter`, the security groups of
+	// the template are used, and they must allow the communicator to connect.
+	LaunchTemplateId string `mapstructure:"launch_template_id" required:"false"`
+	// The name of the launch template to create the instance from, instead of
+	// `launch_template_id`.
+	LaunchTemplateName string `mapstructure:"launch_template_name" required:"false"`
+	// The version of the launch template. The default version of the template
+	// is used if this option is not set.
+	LaunchTemplateVersion int `mapstructure:"launch_template_version" required:"false"`
 	// This is the base image id which you want to
 	// create your customized images.
 	AlicloudSourceImage string `mapstructure:"source_image" required:"true"`
@@ -55,6 +282,57 @@ type RunConfig struct {
 	DisableStopInstance bool `mapstructure:"disable_stop_instance" required:"false"`
 	// Ram Role to apply when launching the instance.
 	RamRoleName string `mapstructure:"ecs_ram_role_name" required:"false"`
+	// A RAM policy document in JSON. If it is set, Packer creates a temporary
+	// RAM role which is trusted by ECS, with this policy attached, and
+	// launches the instance with it, e.g. so that provisioners can fetch
+	// artifacts from OSS. The role and the policy are deleted at the end of
+	// the build. It can't be used together with `ecs_ram_role_name`.
+	//
+	// ```hcl
+	// temporary_ram_role_policy_document = jsonencode({
+	//   Version = "1"
+	//   Statement = [{
+	//     Effect   = "Allow"
+	//     Action   = ["oss:GetObject"]
+	//     Resource = ["acs:oss:*:*:my-bucket/*"]
+	//   }]
+	// })
+	// ```
+	TemporaryRamRolePolicyDocument string `mapstructure:"temporary_ram_role_policy_document" required:"false"`
+	// The ID of the deployment set to which the instance belongs.
+	DeploymentSetId string `mapstructure:"deployment_set_id" required:"false"`
+	// The ID of the dedicated host on which the instance is created. It
+	// can't be used with spot instances.
+	DedicatedHostId string `mapstructure:"dedicated_host_id" required:"false"`
+	// Whether the instance is created on a dedicated host. Valid values are
+	// `default`, which creates the instance on shared hosts, and `host`,
+	// which creates it on a dedicated host. If `dedicated_host_id` is set,
+	// it can't be `default`.
+	Tenancy string `mapstructure:"tenancy" required:"false"`
+	// The private IP address of the instance. It must be an available address
+	// in the CIDR block of the vswitch, so it requires `vswitch_id` or
+	// `vswitch_filter`.
+	PrivateIpAddress string `mapstructure:"private_ip_address" required:"false"`
+	// The hostname of the instance, which is a string of 2 to 64 characters
+	// for Linux, or 2 to 15 characters for Windows when the `winrm`
+	// communicator is used. It can contain letters, digits, `.` and `-`, but
+	// it can't begin or end with `.` or `-`, and Windows hostnames can't
+	// contain `.`.
+	HostName string `mapstructure:"host_name" required:"false"`
+	// The ID of the HPC cluster to which the instance belongs.
+	HpcClusterId string `mapstructure:"hpc_cluster_id" required:"false"`
+	// The running mode of burstable instances, `Standard` or `Unlimited`.
+	CreditSpecification string `mapstructure:"credit_specification" required:"false"`
+	// The metadata service options of the build instance. See the
+	// [Metadata Options](#metadata-options-configuration) section below. For
+	// example, to only allow token-based (IMDSv2) metadata access:
+	//
+	// ```hcl
+	// metadata_options {
+	//   http_tokens = "required"
+	// }
+	// ```
+	MetadataOptions MetadataOptions `mapstructure:"metadata_options" required:"false"`
 	// Key/value pair tags to apply to the instance that is *launched*
 	// to create the image.
 	RunTags map[string]string `mapstructure:"run_tags" required:"false"`
@@ -70,6 +348,23 @@ type RunConfig struct {
 	// uppercase/lowercase letter or Chinese character. Can contain numbers, .,
 	// _ or -. It cannot begin with `http://` or `https://`.
 	SecurityGroupName string `mapstructure:"security_group_name" required:"false"`
+	// IDs of existing security groups which the instance is added to. Up to 5
+	// security groups can be specified. It can't be used together with
+	// `security_group_id`.
+	SecurityGroupIds []string `mapstructure:"security_group_ids" required:"false"`
+	// Filters used to select existing security groups, instead of
+	// specifying their IDs. The instance is added to all the security groups
+	// which match the filter. In VPC network, only the security groups of the
+	// selected VPC are matched. For example:
+	//
+	// ```hcl
+	// security_group_filter {
+	//   tags = {
+	//     Usage = "packer"
+	//   }
+	// }
+	// ```
+	SecurityGroupFilter AlicloudResourceFilter `mapstructure:"security_group_filter" required:"false"`
 	// Specifies whether to enable security hardening. Valid values:
 	// Active: enables security hardening. This value is applicable only to public images.
 	// Deactive: does not enable security hardening. This value is applicable to all image types.
@@ -83,6 +378,15 @@ type RunConfig struct {
 	// Path to a file that will be used for the user
 	// data when launching the instance.
 	UserDataFile string `mapstructure:"user_data_file" required:"false"`
+	// If this value is true and the `winrm` communicator is used, Packer
+	// injects user data which enables WinRM over HTTPS with a self-signed
+	// certificate on `winrm_port`, `5986` by default, and the temporary
+	// security group only allows that port. It requires `winrm_use_ssl` and
+	// `winrm_insecure` to be true, since WinRM is never enabled over HTTP. A
+	// random `winrm_password` is generated if it is not set. It can't be
+	// used together with `user_data` or `user_data_file`. The default value
+	// is false.
+	WinRMBootstrap bool `mapstructure:"winrm_bootstrap" required:"false"`
 	// VPC ID allocated by the system.
 	VpcId string `mapstructure:"vpc_id" required:"false"`
 	// The VPC name. The default value is blank. [2, 128]
@@ -98,6 +402,35 @@ type RunConfig struct {
 	VSwitchId string `mapstructure:"vswitch_id" required:"false"`
 	// The ID of the VSwitch to be used.
 	VSwitchName string `mapstructure:"vswitch_name" required:"false"`
+	// The prefix length of the CIDR block of the vswitch created by Packer
+	// when `vpc_cidr_block` is not specified. The first free CIDR block of
+	// this size in the VPC, which doesn't overlap any existing vswitch, is
+	// used. Valid values are from 16 to 29. The default value is 24.
+	VSwitchCidrPrefixLength int `mapstructure:"vswitch_cidr_prefix_length" required:"false"`
+	// Filters used to select an existing VPC, instead of specifying
+	// `vpc_id`. Exactly one VPC must match the filter. For example:
+	//
+	// ```hcl
+	// vpc_filter {
+	//   name       = "build-network"
+	//   cidr_block = "172.16.0.0/16"
+	// }
+	// ```
+	VpcFilter AlicloudResourceFilter `mapstructure:"vpc_filter" required:"false"`
+	// Filters used to select an existing vswitch of the VPC, instead of
+	// specifying `vswitch_id`. It requires `vpc_id` or `vpc_filter`. Among the
+	// matching vswitches in zones where the instance type and disks are
+	// available, the one with the most free IP addresses is used. For
+	// example:
+	//
+	// ```hcl
+	// vswitch_filter {
+	//   tags = {
+	//     Usage = "packer"
+	//   }
+	// }
+	// ```
+	VSwitchFilter AlicloudResourceFilter `mapstructure:"vswitch_filter" required:"false"`
 	//The ID of the EIP to be used as public ip for the instance
 	EIPId string `mapstructure:"eip_id" required:"false"`
 	// Display name of the instance, which is a string of 2 to 128 Chinese or
@@ -128,23 +461,104 @@ type RunConfig struct {
 	// Timeout of creating snapshot(s).
 	// The default timeout is 3600 seconds if this option is not set or is set
 	// to 0. For those disks containing lots of data, it may require a higher
-	// timeout value.
+	// timeout value. It is the same as `snapshot` and `image_create` of
+	// `timeouts`, which take precedence.
 	WaitSnapshotReadyTimeout int `mapstructure:"wait_snapshot_ready_timeout" required:"false"`
 	// Timeout of copying image.
 	// The default timeout is 3600 seconds if this option is not set or is set
-	// to 0.
+	// to 0. It is the same as `image_copy` of `timeouts`, which takes
+	// precedence.
 	WaitCopyingImageReadyTimeout int `mapstructure:"wait_copying_image_ready_timeout" required:"false"`
+	// The timeouts of waiting for the resources. See the
+	// [Timeouts](#timeouts-configuration) section below. For example:
+	//
+	// ```hcl
+	// timeouts {
+	//   instance_start = "10m"
+	//   image_copy     = "3h"
+	//   poll_interval  = "10s"
+	// }
+	// ```
+	Timeouts TimeoutsConfig `mapstructure:"timeouts" required:"false"`
+	// The spot strategy of the instance. Optional values are:
+	// -   `NoSpot`: a normal pay-as-you-go instance.
+	// -   `SpotWithPriceLimit`: a spot instance with a maximum hourly price
+	//     set by `spot_price_limit`.
+	// -   `SpotAsPriceGo`: a spot instance priced at the market price.
+	//
+	// The default value is `NoSpot`.
+	SpotStrategy string `mapstructure:"spot_strategy" required:"false"`
+	// The maximum hourly price of the spot instance. It is only used when
+	// `spot_strategy` is `SpotWithPriceLimit`.
+	SpotPriceLimit float64 `mapstructure:"spot_price_limit" required:"false"`
+	// The maximum hourly price accepted for the build instance. Before the
+	// build starts, Packer estimates the hourly price of the instance type,
+	// disks and public bandwidth of the instance with the spot strategy in
+	// use, and aborts the build if the estimate exceeds this value. The EIP
+	// allocated for `associate_public_ip_address` and the NAT gateway of
+	// `temporary_nat_gateway` can't be priced, so the build is aborted as
+	// well when they are used. The default value is 0, which disables the
+	// check.
+	MaxHourlyPrice float64 `mapstructure:"max_hourly_price" required:"false"`
+	// Whether to run the preflight validation, the default value is false.
+	// Before any resource is created, the preflight dry-runs the instance
+	// creation, the image creation and the image copy to every destination
+	// region, checks the vCPU and disk capacity quotas of the account, that
+	// the destination regions are valid, that the KMS keys exist and are
+	// enabled, and that the image names are free in the destination regions,
+	// so that missing RAM permissions, exhausted quotas or out of stock
+	// instance types are all reported at once.
+	//
+	// `CreateImage` and `CopyImage` don't support `DryRun`, so they are sent
+	// without a source instance or image and only report the RAM denials.
+	// The quotas are checked with the `ecs:DescribeInstanceTypes` and
+	// `ecs:DescribeAccountAttributes` permissions and the KMS keys with the
+	// `kms:DescribeKey` permission; without them, a warning is reported
+	// instead.
+	PreflightValidation bool `mapstructure:"preflight_validation" required:"false"`
+	// The path of a JSON file to write a report of the build to. The report
+	// contains the start and end time of every step, the temporary resources
+	// created and deleted, the resolved source image, the image and snapshot
+	// IDs in every region with their final status, and the resources whose
+	// cleanup failed. The report is written even if the build fails or is
+	// cancelled.
+	BuildReportPath string `mapstructure:"build_report_path" required:"false"`
 	// Communicator settings
 	Comm communicator.Config `mapstructure:",squash"`
 	// If this value is true, packer will connect to
 	// the ECS created through private ip instead of allocating a public ip or an
 	// EIP. The default value is false.
 	SSHPrivateIp bool `mapstructure:"ssh_private_ip" required:"false"`
+	// If this value is true, packer will create a temporary enhanced NAT
+	// gateway in the VPC of the instance, with an EIP and a SNAT entry for
+	// the vswitch, so that an instance without a public IP address can reach
+	// the internet during the build, for example to download packages. The
+	// EIP uses `internet_charge_type` and `internet_max_bandwidth_out`. The
+	// NAT gateway, the EIP and the SNAT entry are deleted when the build
+	// finishes. It can't be used together with `associate_public_ip_address`,
+	// and is typically used with `ssh_private_ip`. The default value is
+	// false.
+	TemporaryNatGateway bool `mapstructure:"temporary_nat_gateway" required:"false"`
 	//If true, Packer will not create a final image. Defaults to `false`.
 	SkipCreateImage bool `mapstructure:"skip_create_image" required:"false"`
 }
 
 func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
+	var errs []error
+	if c.Comm.Type == "winrm" {
+		if c.Comm.WinRMUser == "" {
+			c.Comm.WinRMUser = DefaultWinRMUsername
+		}
+
+		if c.WinRMBootstrap && c.Comm.WinRMPassword == "" {
+			password, err := generateWindowsPassword()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Error generating winrm_password: %s", err))
+			}
+			c.Comm.WinRMPassword = password
+		}
+	}
+
 	if c.Comm.SSHKeyPairName == "" && c.Comm.SSHTemporaryKeyPairName == "" &&
 		c.Comm.SSHPrivateKeyFile == "" && c.Comm.SSHPassword == "" && c.Comm.WinRMPassword == "" {
 
@@ -155,8 +569,30 @@ func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
 		c.RunTags = make(map[string]string)
 	}
 
+	if c.Timeouts.Communicator > 0 {
+		if c.Comm.SSHTimeout == 0 {
+			c.Comm.SSHTimeout = c.Timeouts.Communicator
+		}
+		if c.Comm.WinRMTimeout == 0 {
+			c.Comm.WinRMTimeout = c.Timeouts.Communicator
+		}
+	}
+
+	if c.WaitSnapshotReadyTimeout > 0 {
+		if c.Timeouts.Snapshot == 0 {
+			c.Timeouts.Snapshot = time.Duration(c.WaitSnapshotReadyTimeout) * time.Second
+		}
+		if c.Timeouts.ImageCreate == 0 {
+			c.Timeouts.ImageCreate = time.Duration(c.WaitSnapshotReadyTimeout) * time.Second
+		}
+	}
+	if c.WaitCopyingImageReadyTimeout > 0 && c.Timeouts.ImageCopy == 0 {
+		c.Timeouts.ImageCopy = time.Duration(c.WaitCopyingImageReadyTimeout) * time.Second
+	}
+
 	// Validation
-	errs := c.Comm.Prepare(ctx)
+	errs = append(errs, c.Timeouts.Prepare()...)
+	errs = append(errs, c.Comm.Prepare(ctx)...)
 	if c.AlicloudSourceImage == "" && c.AlicloudImageFamily == "" {
 		errs = append(errs, errors.New("A source_image must be specified"))
 	}
@@ -173,10 +609,124 @@ func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
 		errs = append(errs, errors.New("The image_family can't include spaces"))
 	}
 
-	if c.InstanceType == "" {
+	if c.InstanceType == "" && !c.LaunchTemplateSpecified() && c.InstanceTypeSelector.Empty() {
 		errs = append(errs, errors.New("An alicloud_instance_type must be specified"))
 	}
 
+	if !c.InstanceTypeSelector.Empty() {
+		if c.InstanceType != "" {
+			errs = append(errs, errors.New("Only one of instance_type or instance_type_selector can be specified."))
+		}
+		errs = append(errs, c.InstanceTypeSelector.Prepare()...)
+	}
+
+	if c.LaunchTemplateId != "" && c.LaunchTemplateName != "" {
+		errs = append(errs, errors.New("Only one of launch_template_id or launch_template_name can be specified."))
+	}
+
+	if c.LaunchTemplateVersion < 0 {
+		errs = append(errs, errors.New("launch_template_version can't be negative"))
+	} else if c.LaunchTemplateVersion > 0 && !c.LaunchTemplateSpecified() {
+		errs = append(errs, errors.New("launch_template_version requires launch_template_id or launch_template_name to be specified."))
+	}
+
+	switch c.SpotStrategy {
+	case "", SpotStrategyNoSpot, SpotStrategyAsPriceGo:
+		if c.SpotPriceLimit != 0 {
+			errs = append(errs, fmt.Errorf("spot_price_limit can only be set when spot_strategy is %s", SpotStrategyWithPriceLimit))
+		}
+	case SpotStrategyWithPriceLimit:
+		if c.SpotPriceLimit <= 0 {
+			errs = append(errs, fmt.Errorf("spot_price_limit must be greater than 0 when spot_strategy is %s", SpotStrategyWithPriceLimit))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("spot_strategy should be one of '%s', '%s' or '%s'",
+			SpotStrategyNoSpot, SpotStrategyWithPriceLimit, SpotStrategyAsPriceGo))
+	}
+
+	if c.MaxHourlyPrice < 0 {
+		errs = append(errs, fmt.Errorf("max_hourly_price can't be negative"))
+	}
+
+	if c.VSwitchCidrPrefixLength == 0 {
+		c.VSwitchCidrPrefixLength = DefaultVSwitchCidrPrefixLength
+	}
+	if c.VSwitchCidrPrefixLength < MinVSwitchCidrPrefixLength || c.VSwitchCidrPrefixLength > MaxVSwitchCidrPrefixLength {
+		errs = append(errs, fmt.Errorf("vswitch_cidr_prefix_length must be between %d and %d",
+			MinVSwitchCidrPrefixLength, MaxVSwitchCidrPrefixLength))
+	}
+
+	if c.VpcId != "" && !c.VpcFilter.Empty() {
+		errs = append(errs, errors.New("Only one of vpc_id or vpc_filter can be specified."))
+	}
+
+	if c.VSwitchId != "" && !c.VSwitchFilter.Empty() {
+		errs = append(errs, errors.New("Only one of vswitch_id or vswitch_filter can be specified."))
+	}
+
+	if !c.VSwitchFilter.Empty() && c.VpcId == "" && c.VpcFilter.Empty() {
+		errs = append(errs, errors.New("vswitch_filter requires vpc_id or vpc_filter to be specified."))
+	}
+
+	securityGroupOptions := 0
+	if c.SecurityGroupId != "" {
+		securityGroupOptions++
+	}
+	if len(c.SecurityGroupIds) > 0 {
+		securityGroupOptions++
+	}
+	if !c.SecurityGroupFilter.Empty() {
+		securityGroupOptions++
+	}
+	if securityGroupOptions > 1 {
+		errs = append(errs, errors.New("Only one of security_group_id, security_group_ids or security_group_filter can be specified."))
+	}
+
+	if len(c.SecurityGroupIds) > MaxInstanceSecurityGroups {
+		errs = append(errs, fmt.Errorf("At most %d security_group_ids can be specified.", MaxInstanceSecurityGroups))
+	}
+
+	if c.SecurityGroupFilter.CidrBlock != "" {
+		errs = append(errs, errors.New("cidr_block is not supported by security_group_filter."))
+	}
+
+	if keyType := c.Comm.SSHTemporaryKeyPairType; keyType != "" && !ContainsInArray(TemporaryKeyPairTypes, strings.ToLower(keyType)) {
+		errs = append(errs, fmt.Errorf("temporary_key_pair_type should be one of %s", strings.Join(TemporaryKeyPairTypes, ", ")))
+	}
+
+	errs = append(errs, c.MetadataOptions.Prepare()...)
+	errs = append(errs, c.preparePlacement()...)
+
+	if c.TemporaryRamRolePolicyDocument != "" {
+		if c.RamRoleName != "" {
+			errs = append(errs, errors.New("Only one of ecs_ram_role_name or temporary_ram_role_policy_document can be specified."))
+		}
+		if !json.Valid([]byte(c.TemporaryRamRolePolicyDocument)) {
+			errs = append(errs, errors.New("temporary_ram_role_policy_document should be a valid JSON document"))
+		}
+	}
+
+	if c.TemporaryNatGateway && c.AssociatePublicIpAddress {
+		errs = append(errs, errors.New("temporary_nat_gateway can't be used together with associate_public_ip_address."))
+	}
+	if c.TemporaryNatGateway && c.UsesLaunchTemplateVSwitch() {
+		errs = append(errs, errors.New("temporary_nat_gateway requires the vswitch to be specified when a launch template is used."))
+	}
+
+	if c.WinRMBootstrap {
+		if c.Comm.Type != "winrm" {
+			errs = append(errs, errors.New("winrm_bootstrap can only be used with the winrm communicator."))
+		}
+		if c.UserData != "" || c.UserDataFile != "" {
+			errs = append(errs, errors.New("winrm_bootstrap can't be used together with user_data or user_data_file."))
+		}
+		// The password mustn't be sent in cleartext, and the certificate of
+		// the bootstrapped HTTPS listener is self-signed
+		if !c.Comm.WinRMUseSSL || !c.Comm.WinRMInsecure {
+			errs = append(errs, errors.New("winrm_use_ssl and winrm_insecure must be true to use winrm_bootstrap."))
+		}
+	}
+
 	if c.UserData != "" && c.UserDataFile != "" {
 		errs = append(errs, fmt.Errorf("Only one of user_data or user_data_file can be specified."))
 	} else if c.UserDataFile != "" {
@@ -187,3 +737,80 @@ func (c *RunConfig) Prepare(ctx *interpolate.Context) []error {
 
 	return errs
 }
+
+func (c *RunConfig) preparePlacement() []error {
+	var errs []error
+	if c.DeploymentSetId != "" && !strings.HasPrefix(c.DeploymentSetId, "ds-") {
+		errs = append(errs, fmt.Errorf("deployment_set_id should begin with 'ds-'"))
+	}
+
+	if c.DedicatedHostId != "" && !strings.HasPrefix(c.DedicatedHostId, "dh-") {
+		errs = append(errs, fmt.Errorf("dedicated_host_id should begin with 'dh-'"))
+	}
+
+	if c.HpcClusterId != "" && !strings.HasPrefix(c.HpcClusterId, "hpc-") {
+		errs = append(errs, fmt.Errorf("hpc_cluster_id should begin with 'hpc-'"))
+	}
+
+	switch c.Tenancy {
+	case "", TenancyHost:
+	case TenancyDefault:
+		if c.DedicatedHostId != "" {
+			errs = append(errs, fmt.Errorf("tenancy can't be '%s' when dedicated_host_id is set", TenancyDefault))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tenancy should be '%s' or '%s'", TenancyDefault, TenancyHost))
+	}
+
+	if (c.DedicatedHostId != "" || c.Tenancy == TenancyHost) && c.SpotStrategy != "" && c.SpotStrategy != SpotStrategyNoSpot {
+		errs = append(errs, errors.New("Spot instances can't be created on a dedicated host"))
+	}
+
+	if c.PrivateIpAddress != "" {
+		if ip := net.ParseIP(c.PrivateIpAddress); ip == nil || ip.To4() == nil {
+			errs = append(errs, fmt.Errorf("private_ip_address should be a valid IPv4 address: %s", c.PrivateIpAddress))
+		}
+		if c.VSwitchId == "" && c.VSwitchFilter.Empty() {
+			errs = append(errs, errors.New("private_ip_address requires vswitch_id or vswitch_filter to be specified"))
+		}
+	}
+
+	if c.HostName != "" {
+		maxLength := 64
+		if c.Comm.Type == "winrm" {
+			maxLength = 15
+		}
+		if len(c.HostName) < 2 || len(c.HostName) > maxLength || !hostNameRegexp.MatchString(c.HostName) ||
+			strings.Contains(c.HostName, "..") || (c.Comm.Type == "winrm" && strings.Contains(c.HostName, ".")) {
+			errs = append(errs, fmt.Errorf("host_name is invalid: %s", c.HostName))
+		}
+	}
+
+	switch c.CreditSpecification {
+	case "", CreditSpecificationStandard, CreditSpecificationUnlimited:
+	default:
+		errs = append(errs, fmt.Errorf("credit_specification should be '%s' or '%s'",
+			CreditSpecificationStandard, CreditSpecificationUnlimited))
+	}
+
+	return errs
+}
+
+func (c *RunConfig) LaunchTemplateSpecified() bool {
+	return c.LaunchTemplateId != "" || c.LaunchTemplateName != ""
+}
+
+// UsesLaunchTemplateVSwitch returns whether the instance is created in the
+// vswitch of the launch template, because no network is set explicitly.
+func (c *RunConfig) UsesLaunchTemplateVSwitch() bool {
+	return c.LaunchTemplateSpecified() && c.VpcId == "" && c.VpcFilter.Empty() &&
+		c.VSwitchId == "" && c.VSwitchFilter.Empty()
+}
+
+// UsesLaunchTemplateSecurityGroups returns whether the instance is created in
+// the security groups of the launch template, because none is set
+// explicitly.
+func (c *RunConfig) UsesLaunchTemplateSecurityGroups() bool {
+	return c.LaunchTemplateSpecified() && c.SecurityGroupId == "" && len(c.SecurityGroupIds) == 0 &&
+		c.SecurityGroupFilter.Empty()
+}
@@ -6,7 +6,9 @@ package ecs
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 )
@@ -179,3 +181,424 @@ func TestRunConfigPrepare_DisableStopInstance(t *testing.T) {
 		t.Fatalf("invalid value, expected: %t, actul: %t", false, c.DisableStopInstance)
 	}
 }
+
+func TestRunConfigPrepare_SpotStrategy(t *testing.T) {
+	c := testConfig()
+	c.SpotStrategy = SpotStrategyAsPriceGo
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPriceLimit = 0.5
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c.SpotStrategy = SpotStrategyWithPriceLimit
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPriceLimit = 0
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c.SpotStrategy = "Spot"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_MaxHourlyPrice(t *testing.T) {
+	c := testConfig()
+	c.MaxHourlyPrice = 1.5
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.MaxHourlyPrice = -1
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_NetworkFilters(t *testing.T) {
+	c := testConfig()
+	c.VpcFilter = AlicloudResourceFilter{Tags: map[string]string{"Usage": "packer"}}
+	c.VSwitchFilter = AlicloudResourceFilter{Name: "build"}
+	c.SecurityGroupFilter = AlicloudResourceFilter{Name: "build"}
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.VpcId = "vpc-test"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.VSwitchFilter = AlicloudResourceFilter{Name: "build"}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("vswitch_filter without vpc should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.SecurityGroupFilter = AlicloudResourceFilter{CidrBlock: "172.16.0.0/24"}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("cidr_block in security_group_filter should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_SecurityGroupIds(t *testing.T) {
+	c := testConfig()
+	c.SecurityGroupIds = []string{"sg-1", "sg-2"}
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SecurityGroupId = "sg-3"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.SecurityGroupIds = []string{"sg-1", "sg-2", "sg-3", "sg-4", "sg-5", "sg-6"}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_VSwitchCidrPrefixLength(t *testing.T) {
+	c := testConfig()
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if c.VSwitchCidrPrefixLength != DefaultVSwitchCidrPrefixLength {
+		t.Fatalf("invalid value: %d", c.VSwitchCidrPrefixLength)
+	}
+
+	c.VSwitchCidrPrefixLength = 30
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_TemporaryNatGateway(t *testing.T) {
+	c := testConfig()
+	c.TemporaryNatGateway = true
+	c.SSHPrivateIp = true
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.AssociatePublicIpAddress = true
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_TemporaryKeyPairType(t *testing.T) {
+	c := testConfig()
+	c.Comm.SSHTemporaryKeyPairType = "ed25519"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.Comm.SSHTemporaryKeyPairType = "ecdsa"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_WinRM(t *testing.T) {
+	c := testConfig()
+	c.Comm.Type = "winrm"
+	c.Comm.WinRMPassword = "Packer@123"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.Comm.WinRMUser != DefaultWinRMUsername {
+		t.Fatalf("bad winrm_username: %s", c.Comm.WinRMUser)
+	}
+	if c.Comm.WinRMUseSSL || c.Comm.WinRMPort != 5985 {
+		t.Fatalf("WinRM shouldn't be changed without winrm_bootstrap: %#v", c.Comm.WinRM)
+	}
+	if c.Comm.SSHTemporaryKeyPairName != "" {
+		t.Fatalf("no temporary keypair should be created: %s", c.Comm.SSHTemporaryKeyPairName)
+	}
+}
+
+func TestRunConfigPrepare_WinRMBootstrap(t *testing.T) {
+	c := testConfig()
+	c.Comm.Type = "winrm"
+	c.Comm.WinRMUseSSL = true
+	c.Comm.WinRMInsecure = true
+	c.WinRMBootstrap = true
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if len(c.Comm.WinRMPassword) != windowsPasswordLength {
+		t.Fatalf("bad winrm_password: %s", c.Comm.WinRMPassword)
+	}
+
+	// WinRM is never bootstrapped over HTTP
+	c = testConfig()
+	c.Comm.Type = "winrm"
+	c.WinRMBootstrap = true
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.Comm.Type = "winrm"
+	c.Comm.WinRMPassword = "Packer@123"
+	c.Comm.WinRMUseSSL = true
+	c.Comm.WinRMInsecure = true
+	c.WinRMBootstrap = true
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if c.Comm.WinRMPassword != "Packer@123" {
+		t.Fatalf("winrm_password shouldn't be changed: %s", c.Comm.WinRMPassword)
+	}
+	if c.Comm.WinRMPort != 5986 {
+		t.Fatalf("bad winrm_port: %d", c.Comm.WinRMPort)
+	}
+
+	// The certificate of the bootstrapped listener can't be verified
+	c.Comm.WinRMInsecure = false
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.Comm.Type = "winrm"
+	c.Comm.WinRMUseSSL = true
+	c.Comm.WinRMInsecure = true
+	c.WinRMBootstrap = true
+	c.UserData = "[powershell]"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.Comm.WinRMUseSSL = true
+	c.Comm.WinRMInsecure = true
+	c.WinRMBootstrap = true
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestGenerateWindowsPassword(t *testing.T) {
+	password, err := generateWindowsPassword()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	for _, class := range []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnopqrstuvwxyz", "23456789", windowsPasswordSpecial} {
+		if !strings.ContainsAny(password, class) {
+			t.Fatalf("password %q should contain one of %q", password, class)
+		}
+	}
+}
+
+func TestWinRMBootstrapUserData(t *testing.T) {
+	userData := winRMBootstrapUserData(5986)
+	if !strings.Contains(userData, "-Transport HTTPS -Address * -Port 5986") || !strings.Contains(userData, "-LocalPort 5986") {
+		t.Fatalf("WinRM over HTTPS should be enabled on port 5986: %s", userData)
+	}
+	if strings.Contains(userData, "AllowUnencrypted") || strings.Contains(userData, "Transport HTTP ") {
+		t.Fatalf("WinRM shouldn't be enabled over HTTP: %s", userData)
+	}
+}
+
+func TestRunConfigPrepare_MetadataOptions(t *testing.T) {
+	c := testConfig()
+	c.MetadataOptions = MetadataOptions{
+		HttpEndpoint:            "enabled",
+		HttpTokens:              "required",
+		HttpPutResponseHopLimit: 2,
+	}
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.MetadataOptions.HttpTokens = "always"
+	c.MetadataOptions.HttpPutResponseHopLimit = 65
+	if err := c.Prepare(nil); len(err) != 2 {
+		t.Fatalf("should have 2 errs: %s", err)
+	}
+
+	c.MetadataOptions = MetadataOptions{
+		HttpEndpoint: "disabled",
+		HttpTokens:   "required",
+	}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_Placement(t *testing.T) {
+	c := testConfig()
+	c.DeploymentSetId = "ds-test"
+	c.DedicatedHostId = "dh-test"
+	c.Tenancy = "host"
+	c.VSwitchId = "vsw-test"
+	c.PrivateIpAddress = "172.16.0.10"
+	c.HostName = "packer-build.local"
+	c.HpcClusterId = "hpc-test"
+	c.CreditSpecification = "Unlimited"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotStrategy = SpotStrategyAsPriceGo
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("spot instance on dedicated host should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.DeploymentSetId = "test"
+	c.DedicatedHostId = "dh-test"
+	c.Tenancy = "default"
+	c.PrivateIpAddress = "172.16.0.300"
+	c.HostName = "-packer"
+	c.CreditSpecification = "Burst"
+	if err := c.Prepare(nil); len(err) != 6 {
+		t.Fatalf("should have 6 errs: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_WindowsHostName(t *testing.T) {
+	c := testConfig()
+	c.Comm.Type = "winrm"
+	c.HostName = "packer-windows"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.HostName = "packer-windows-build"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_LaunchTemplate(t *testing.T) {
+	c := testConfig()
+	c.InstanceType = ""
+	c.LaunchTemplateName = "packer"
+	c.LaunchTemplateVersion = 2
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("instance_type should be optional with launch template: %s", err)
+	}
+
+	c.LaunchTemplateId = "lt-test"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.LaunchTemplateVersion = 1
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("launch_template_version without template should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.LaunchTemplateId = "lt-test"
+	c.TemporaryNatGateway = true
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("temporary_nat_gateway with the vswitch of the template should have err: %s", err)
+	}
+
+	c.VSwitchId = "vsw-test"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_InstanceTypeSelector(t *testing.T) {
+	c := testConfig()
+	c.InstanceType = ""
+	c.InstanceTypeSelector.MinVCpus = 2
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("instance_type should be optional with instance_type_selector: %s", err)
+	}
+	if c.InstanceTypeSelector.Architecture != "" {
+		t.Fatalf("the architecture should be the one of the source image: %s", c.InstanceTypeSelector.Architecture)
+	}
+	if c.InstanceTypeSelector.OrderBy != InstanceTypeOrderCheapest {
+		t.Fatalf("bad order_by: %s", c.InstanceTypeSelector.OrderBy)
+	}
+
+	c.InstanceType = "ecs.n1.tiny"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("instance_type with instance_type_selector should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.InstanceType = ""
+	c.InstanceTypeSelector.Architecture = "i386"
+	c.InstanceTypeSelector.OrderBy = "fastest"
+	c.InstanceTypeSelector.MinMemory = -1
+	if err := c.Prepare(nil); len(err) != 3 {
+		t.Fatalf("should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_TemporaryRamRolePolicyDocument(t *testing.T) {
+	c := testConfig()
+	c.TemporaryRamRolePolicyDocument = `{"Version": "1", "Statement": []}`
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.RamRoleName = "packer"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("ecs_ram_role_name with temporary_ram_role_policy_document should have err: %s", err)
+	}
+
+	c = testConfig()
+	c.TemporaryRamRolePolicyDocument = `{"Version": "1",`
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("invalid policy document should have err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_Timeouts(t *testing.T) {
+	c := testConfig()
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if c.Timeouts.InstanceStart != ALICLOUD_DEFAULT_TIMEOUT*time.Second || c.Timeouts.Snapshot != ALICLOUD_DEFAULT_LONG_TIMEOUT*time.Second ||
+		c.Timeouts.Network != ALICLOUD_DEFAULT_SHORT_TIMEOUT*time.Second || c.Timeouts.PollInterval != defaultRetryInterval {
+		t.Fatalf("bad default timeouts: %#v", c.Timeouts)
+	}
+
+	c = testConfig()
+	c.WaitSnapshotReadyTimeout = 600
+	c.WaitCopyingImageReadyTimeout = 900
+	c.Timeouts.ImageCreate = 20 * time.Minute
+	c.Timeouts.Communicator = 10 * time.Minute
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+	if c.Timeouts.Snapshot != 10*time.Minute || c.Timeouts.ImageCopy != 15*time.Minute {
+		t.Fatalf("legacy timeouts should be used: %#v", c.Timeouts)
+	}
+	if c.Timeouts.ImageCreate != 20*time.Minute {
+		t.Fatalf("timeouts.image_create should take precedence: %s", c.Timeouts.ImageCreate)
+	}
+	if c.Comm.SSHTimeout != 10*time.Minute {
+		t.Fatalf("timeouts.communicator should be used as ssh_timeout: %s", c.Comm.SSHTimeout)
+	}
+
+	c = testConfig()
+	c.Timeouts.Eip = -time.Minute
+	c.Timeouts.PollInterval = -time.Second
+	if err := c.Prepare(nil); len(err) != 2 {
+		t.Fatalf("negative timeouts should have err: %s", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/signers"
+)
+
+// SessionCredentials are the raw credentials for the clients which don't
+// accept the credentials of the Alibaba Cloud SDK, such as the OSS client.
+type SessionCredentials struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string
+}
+
+// sessionCredentialsProvider returns the current credentials, which are
+// refreshed before they expire.
+type sessionCredentialsProvider interface {
+	sessionCredentials() (SessionCredentials, error)
+}
+
+type staticSessionCredentials SessionCredentials
+
+func (s staticSessionCredentials) sessionCredentials() (SessionCredentials, error) {
+	return SessionCredentials(s), nil
+}
+
+// SessionCredentials returns the credentials which the clients created by
+// Client are signed with. The credentials of RAM roles are refreshed before
+// they expire, so they must not be cached by the caller.
+func (c *AlicloudAccessConfig) SessionCredentials() (SessionCredentials, error) {
+	if _, err := c.Client(); err != nil {
+		return SessionCredentials{}, err
+	}
+
+	return c.session.sessionCredentials()
+}
+
+// newSessionCredentialsProvider returns the provider of the raw credentials
+// for the credential, without any request until they are needed.
+func (c *AlicloudAccessConfig) newSessionCredentialsProvider(credential auth.Credential) (sessionCredentialsProvider, error) {
+	switch credential := credential.(type) {
+	case *credentials.AccessKeyCredential:
+		return staticSessionCredentials{
+			AccessKeyId:     credential.AccessKeyId,
+			AccessKeySecret: credential.AccessKeySecret,
+		}, nil
+	case *credentials.StsTokenCredential:
+		return staticSessionCredentials{
+			AccessKeyId:     credential.AccessKeyId,
+			AccessKeySecret: credential.AccessKeySecret,
+			SecurityToken:   credential.AccessKeyStsToken,
+		}, nil
+	case *credentials.RamRoleArnCredential:
+		stsClient, err := c.newStsClient(credentials.NewAccessKeyCredential(credential.AccessKeyId, credential.AccessKeySecret))
+		if err != nil {
+			return nil, err
+		}
+		return newAssumeRoleSession(stsClient, AssumeRoleConfig{
+			RoleArn:           credential.RoleArn,
+			SessionName:       credential.RoleSessionName,
+			ExternalId:        credential.ExternalId,
+			Policy:            credential.Policy,
+			SessionExpiration: credential.RoleSessionExpiration,
+		}), nil
+	case *credentials.EcsRamRoleCredential:
+		return &ecsRamRoleSession{roleName: credential.RoleName}, nil
+	case *sessionCredential:
+		return credential.provider, nil
+	default:
+		return nil, fmt.Errorf("Unsupported credential type %T", credential)
+	}
+}
+
+// sessionCredential is the credential of the sessions which the Alibaba Cloud
+// SDK can't refresh, such as the RAM roles assumed with OIDC or with the
+// credentials of another role. The requests are signed by a sessionSigner
+// instead of a signer of the SDK.
+type sessionCredential struct {
+	provider sessionCredentialsProvider
+}
+
+// sessionSigner signs the requests with the current credentials of the
+// provider. The SDK gets the access key, the extra parameters and the
+// signature of a request with three calls, from GetAccessKeyId to Sign, so the
+// signer is locked in between, and each request is signed with a single set of
+// credentials even when a concurrent request refreshes them.
+type sessionSigner struct {
+	provider sessionCredentialsProvider
+
+	// signing is locked by GetAccessKeyId and unlocked by Sign
+	signing     sync.Mutex
+	credentials SessionCredentials
+}
+
+func newSessionSigner(provider sessionCredentialsProvider) *sessionSigner {
+	return &sessionSigner{provider: provider}
+}
+
+func (*sessionSigner) GetName() string {
+	return "HMAC-SHA1"
+}
+
+func (*sessionSigner) GetType() string {
+	return ""
+}
+
+func (*sessionSigner) GetVersion() string {
+	return "1.0"
+}
+
+// GetAccessKeyId is called first when a request is signed, so the credentials
+// are got from the provider here.
+func (s *sessionSigner) GetAccessKeyId() (string, error) {
+	s.signing.Lock()
+
+	credentials, err := s.provider.sessionCredentials()
+	if err != nil {
+		// The SDK doesn't sign the request, so Sign isn't called
+		s.signing.Unlock()
+		return "", err
+	}
+
+	s.credentials = credentials
+	return credentials.AccessKeyId, nil
+}
+
+func (s *sessionSigner) GetExtraParam() map[string]string {
+	if s.credentials.SecurityToken == "" {
+		return map[string]string{}
+	}
+	return map[string]string{"SecurityToken": s.credentials.SecurityToken}
+}
+
+func (s *sessionSigner) Sign(stringToSign, secretSuffix string) string {
+	defer s.signing.Unlock()
+
+	return signers.ShaHmac1(stringToSign, s.credentials.AccessKeySecret+secretSuffix)
+}
+
+// expiringSession gets the credentials with fetch, and gets them again when
+// less than assumeRoleRefreshRatio of their lifetime is left.
+type expiringSession struct {
+	fetch func() (SessionCredentials, time.Time, error)
+
+	lock        sync.Mutex
+	credentials SessionCredentials
+	refreshAt   time.Time
+}
+
+// newExpiringSessionCredential gets the credentials at once, so that the
+// errors are reported before any request is sent.
+func newExpiringSessionCredential(fetch func() (SessionCredentials, time.Time, error)) (auth.Credential, error) {
+	session := &expiringSession{fetch: fetch}
+	if _, err := session.sessionCredentials(); err != nil {
+		return nil, err
+	}
+
+	return &sessionCredential{provider: session}, nil
+}
+
+func (s *expiringSession) sessionCredentials() (SessionCredentials, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.credentials.AccessKeyId != "" && time.Now().Before(s.refreshAt) {
+		return s.credentials, nil
+	}
+
+	credentials, expiration, err := s.fetch()
+	if err != nil {
+		return SessionCredentials{}, err
+	}
+
+	s.credentials = credentials
+	s.refreshAt = time.Now().Add(time.Duration(float64(time.Until(expiration)) * (1 - assumeRoleRefreshRatio)))
+	return s.credentials, nil
+}
+
+// ecsRamRoleSession gets the credentials of the RAM role from the metadata of
+// the ECS instance which runs Packer.
+type ecsRamRoleSession struct {
+	roleName string
+
+	lock        sync.Mutex
+	credentials SessionCredentials
+	refreshAt   time.Time
+}
+
+func (s *ecsRamRoleSession) sessionCredentials() (SessionCredentials, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.credentials.AccessKeyId != "" && time.Now().Before(s.refreshAt) {
+		return s.credentials, nil
+	}
+
+	client := &http.Client{
+		Timeout:   DefaultRequestReadTimeout,
+		Transport: &http.Transport{Proxy: nil},
+	}
+	response, err := client.Get(ecsMetadataRoleURL + s.roleName)
+	if err != nil {
+		return SessionCredentials{}, fmt.Errorf("Error getting credentials of RAM role %s: %s", s.roleName, err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		Code            string `json:"Code"`
+		AccessKeyId     string `json:"AccessKeyId"`
+		AccessKeySecret string `json:"AccessKeySecret"`
+		SecurityToken   string `json:"SecurityToken"`
+		Expiration      string `json:"Expiration"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return SessionCredentials{}, fmt.Errorf("Error getting credentials of RAM role %s: status %d: %s",
+			s.roleName, response.StatusCode, err)
+	}
+	if result.Code != "Success" || result.AccessKeyId == "" {
+		return SessionCredentials{}, fmt.Errorf("Error getting credentials of RAM role %s: code %q", s.roleName, result.Code)
+	}
+
+	// The metadata service rotates the credentials well before they expire,
+	// so they are refreshed again in a few minutes at most
+	s.refreshAt = time.Now().Add(5 * time.Minute)
+	if expiration, err := time.Parse(time.RFC3339, result.Expiration); err == nil && time.Until(expiration) < 10*time.Minute {
+		s.refreshAt = time.Now().Add(time.Until(expiration) / 2)
+	}
+
+	s.credentials = SessionCredentials{
+		AccessKeyId:     result.AccessKeyId,
+		AccessKeySecret: result.AccessKeySecret,
+		SecurityToken:   result.SecurityToken,
+	}
+	return s.credentials, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/signers"
+)
+
+func TestSessionCredentials_static(t *testing.T) {
+	testCredentialEnvironment(t, "")
+
+	c := &AlicloudAccessConfig{
+		AlicloudAccessKey: "ak",
+		AlicloudSecretKey: "sk",
+		SecurityToken:     "token",
+		AlicloudRegion:    "cn-beijing",
+	}
+	sessionCredentials, err := c.SessionCredentials()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	expected := SessionCredentials{AccessKeyId: "ak", AccessKeySecret: "sk", SecurityToken: "token"}
+	if sessionCredentials != expected {
+		t.Fatalf("expected %#v, got %#v", expected, sessionCredentials)
+	}
+}
+
+func TestSessionCredentials_ecsRamRole(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/packer-role") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"Code": "NotFound"}`)
+			return
+		}
+		calls++
+		fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "ak-%d", "AccessKeySecret": "sk", "SecurityToken": "token", "Expiration": %q}`,
+			calls, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	testCredentialEnvironment(t, server.URL+"/")
+
+	c := &AlicloudAccessConfig{AlicloudRegion: "cn-beijing"}
+	provider, err := c.newSessionCredentialsProvider(credentials.NewEcsRamRoleCredential("packer-role"))
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	sessionCredentials, err := provider.sessionCredentials()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if sessionCredentials.AccessKeyId != "ak-1" || sessionCredentials.SecurityToken != "token" {
+		t.Fatalf("unexpected credentials: %#v", sessionCredentials)
+	}
+
+	if sessionCredentials, _ := provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-1" {
+		t.Fatalf("the credentials shouldn't be refreshed yet")
+	}
+
+	provider.(*ecsRamRoleSession).refreshAt = time.Now().Add(-time.Second)
+	if sessionCredentials, _ := provider.sessionCredentials(); sessionCredentials.AccessKeyId != "ak-2" {
+		t.Fatalf("the credentials should be refreshed")
+	}
+
+	provider, _ = c.newSessionCredentialsProvider(credentials.NewEcsRamRoleCredential("unknown"))
+	if _, err := provider.sessionCredentials(); err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+}
+
+func TestSessionCredentials_credentialsURI(t *testing.T) {
+	testCredentialEnvironment(t, "")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Code": "Success", "AccessKeyId": "ak", "AccessKeySecret": "sk", "SecurityToken": "token", "Expiration": %q}`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	t.Setenv(EnvCredentialsURI, server.URL)
+
+	c := &AlicloudAccessConfig{AlicloudRegion: "cn-beijing"}
+	sessionCredentials, err := c.SessionCredentials()
+	if err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	expected := SessionCredentials{AccessKeyId: "ak", AccessKeySecret: "sk", SecurityToken: "token"}
+	if sessionCredentials != expected {
+		t.Fatalf("expected %#v, got %#v", expected, sessionCredentials)
+	}
+
+	// The SDK can't refresh the credentials, so the clients are signed by the
+	// plugin
+	signer, ok := c.signer.(*sessionSigner)
+	if !ok {
+		t.Fatalf("expected a session signer, got %T", c.signer)
+	}
+	if accessKeyId, err := signer.GetAccessKeyId(); err != nil || accessKeyId != "ak" {
+		t.Fatalf("unexpected access key: %s, %v", accessKeyId, err)
+	}
+	if signer.GetExtraParam()["SecurityToken"] != "token" {
+		t.Fatalf("unexpected extra params: %v", signer.GetExtraParam())
+	}
+	if signer.Sign("string", "&") != signers.ShaHmac1("string", "sk&") {
+		t.Fatal("the request should be signed with the secret of the session")
+	}
+}
@@ -33,6 +33,11 @@ func (s *stepAttachKeyPair) Run(ctx context.Context, state multistep.StateBag) m
 		return multistep.ActionContinue
 	}
 
+	// The keypair is usually bound when the instance is created
+	if instance.KeyPairName == keyPairName {
+		return multistep.ActionContinue
+	}
+
 	_, err := client.WaitForExpected(&WaitForExpectArgs{
 		RequestFunc: func() (responses.AcsResponse, error) {
 			request := ecs.CreateAttachKeyPairRequest()
@@ -41,7 +46,8 @@ func (s *stepAttachKeyPair) Run(ctx context.Context, state multistep.StateBag) m
 			request.InstanceIds = "[\"" + instance.InstanceId + "\"]"
 			return client.AttachKeyPair(request)
 		},
-		EvalFunc: client.EvalCouldRetryResponse(attachKeyPairNotRetryErrors, EvalNotRetryErrorType),
+		EvalFunc:     client.EvalCouldRetryResponse(attachKeyPairNotRetryErrors, EvalNotRetryErrorType),
+		RetryTimeout: config.Timeouts.InstanceStart,
 	})
 
 	if err != nil {
@@ -0,0 +1,131 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const consoleOutputTailLines = 20
+
+// stepCaptureAlicloudInstanceOutput saves the console output and a screenshot
+// of the instance when the communicator fails to connect, so that boot,
+// cloud-init and network failures can be told apart. It must run right
+// before the connect step. In debug mode, the capture can also be requested
+// when the instance is cleaned up.
+type stepCaptureAlicloudInstanceOutput struct {
+	ConsoleOutputPath string
+	ScreenshotPath    string
+	Debug             bool
+
+	started bool
+}
+
+func (s *stepCaptureAlicloudInstanceOutput) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	s.started = true
+	return multistep.ActionContinue
+}
+
+func (s *stepCaptureAlicloudInstanceOutput) Cleanup(state multistep.StateBag) {
+	if !s.started {
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	_, connected := state.GetOk("communicator")
+	if !halted || cancelled || connected {
+		if s.Debug {
+			s.askCapture(state)
+		}
+		return
+	}
+
+	instance := state.Get("instance").(*ecs.Instance)
+	consoleOutput := s.capture(state)
+	if consoleOutput == "" {
+		return
+	}
+
+	if rawErr, ok := state.GetOk("error"); ok {
+		state.Put("error", fmt.Errorf("%s\nLast lines of the console output of instance %s:\n%s",
+			rawErr.(error), instance.InstanceId, lastLines(consoleOutput, consoleOutputTailLines)))
+	}
+}
+
+// askCapture asks the user whether to capture the instance before it is
+// cleaned up.
+func (s *stepCaptureAlicloudInstanceOutput) askCapture(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	instance := state.Get("instance").(*ecs.Instance)
+
+	answer, err := ui.Ask(fmt.Sprintf("Capture the console output and screenshot of instance %s? [y/N]", instance.InstanceId))
+	if err != nil || !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		return
+	}
+
+	s.capture(state)
+}
+
+// capture saves the console output and the screenshot of the instance, and
+// returns the console output.
+func (s *stepCaptureAlicloudInstanceOutput) capture(state multistep.StateBag) string {
+	client := state.Get("client").(*ClientWrapper)
+	ui := state.Get("ui").(packersdk.Ui)
+	instance := state.Get("instance").(*ecs.Instance)
+
+	ui.Say(fmt.Sprintf("Capturing console output and screenshot of instance: %s", instance.InstanceId))
+
+	var consoleOutput string
+	consoleOutputRequest := ecs.CreateGetInstanceConsoleOutputRequest()
+	consoleOutputRequest.RegionId = instance.RegionId
+	consoleOutputRequest.InstanceId = instance.InstanceId
+	consoleOutputRequest.RemoveSymbols = requests.NewBoolean(true)
+	consoleOutputResponse, err := client.GetInstanceConsoleOutput(consoleOutputRequest)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error getting console output of instance: %s", err))
+	} else if output, err := base64.StdEncoding.DecodeString(consoleOutputResponse.ConsoleOutput); err != nil {
+		ui.Error(fmt.Sprintf("Error decoding console output of instance: %s", err))
+	} else if err := os.WriteFile(s.ConsoleOutputPath, output, 0644); err != nil {
+		ui.Error(fmt.Sprintf("Error saving console output of instance: %s", err))
+	} else {
+		consoleOutput = string(output)
+		ui.Message(fmt.Sprintf("Saved console output: %s", s.ConsoleOutputPath))
+	}
+
+	screenshotRequest := ecs.CreateGetInstanceScreenshotRequest()
+	screenshotRequest.RegionId = instance.RegionId
+	screenshotRequest.InstanceId = instance.InstanceId
+	screenshotRequest.WakeUp = requests.NewBoolean(true)
+	screenshotResponse, err := client.GetInstanceScreenshot(screenshotRequest)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Error getting screenshot of instance: %s", err))
+	} else if screenshot, err := base64.StdEncoding.DecodeString(screenshotResponse.Screenshot); err != nil {
+		ui.Error(fmt.Sprintf("Error decoding screenshot of instance: %s", err))
+	} else if err := os.WriteFile(s.ScreenshotPath, screenshot, 0644); err != nil {
+		ui.Error(fmt.Sprintf("Error saving screenshot of instance: %s", err))
+	} else {
+		ui.Message(fmt.Sprintf("Saved screenshot: %s", s.ScreenshotPath))
+	}
+
+	return consoleOutput
+}
+
+func lastLines(text string, count int) string {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestLastLines(t *testing.T) {
+	if got := lastLines("a\nb\nc\n", 2); got != "b\nc" {
+		t.Fatalf("bad: %q", got)
+	}
+
+	if got := lastLines("a\r\nb\r\n", 5); got != "a\r\nb" {
+		t.Fatalf("bad: %q", got)
+	}
+
+	if got := lastLines("", 5); got != "" {
+		t.Fatalf("bad: %q", got)
+	}
+}
+
+func testCaptureInstanceOutputState(t *testing.T, calls *[]string) multistep.StateBag {
+	client := testClientWrapper(t, func(action string, params url.Values) interface{} {
+		*calls = append(*calls, action)
+		switch action {
+		case "GetInstanceConsoleOutput":
+			return map[string]string{"ConsoleOutput": base64.StdEncoding.EncodeToString([]byte("booting\ncloud-init failed\n"))}
+		case "GetInstanceScreenshot":
+			return map[string]string{"Screenshot": base64.StdEncoding.EncodeToString([]byte("jpeg"))}
+		default:
+			return testAPIError("InvalidAction.NotFound")
+		}
+	})
+
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("instance", &ecs.Instance{InstanceId: "i-test", RegionId: "cn-test"})
+	return state
+}
+
+func TestStepCaptureAlicloudInstanceOutputHalted(t *testing.T) {
+	var calls []string
+	state := testCaptureInstanceOutputState(t, &calls)
+	dir := t.TempDir()
+	step := &stepCaptureAlicloudInstanceOutput{
+		ConsoleOutputPath: filepath.Join(dir, "console.log"),
+		ScreenshotPath:    filepath.Join(dir, "screenshot.jpg"),
+	}
+
+	step.Run(context.Background(), state)
+	state.Put(multistep.StateHalted, true)
+	state.Put("error", errors.New("Timeout waiting for SSH."))
+	step.Cleanup(state)
+
+	err := state.Get("error").(error)
+	expected := "Timeout waiting for SSH.\nLast lines of the console output of instance i-test:\nbooting\ncloud-init failed"
+	if err.Error() != expected {
+		t.Fatalf("bad error, expected: %q, actual: %q", expected, err)
+	}
+
+	consoleOutput, readErr := os.ReadFile(step.ConsoleOutputPath)
+	if readErr != nil || !strings.Contains(string(consoleOutput), "cloud-init failed") {
+		t.Fatalf("console output should be saved: %q %v", consoleOutput, readErr)
+	}
+	if screenshot, readErr := os.ReadFile(step.ScreenshotPath); readErr != nil || string(screenshot) != "jpeg" {
+		t.Fatalf("screenshot should be saved: %q %v", screenshot, readErr)
+	}
+}
+
+func TestStepCaptureAlicloudInstanceOutputNotHalted(t *testing.T) {
+	var calls []string
+	state := testCaptureInstanceOutputState(t, &calls)
+	dir := t.TempDir()
+	step := &stepCaptureAlicloudInstanceOutput{
+		ConsoleOutputPath: filepath.Join(dir, "console.log"),
+		ScreenshotPath:    filepath.Join(dir, "screenshot.jpg"),
+	}
+
+	step.Run(context.Background(), state)
+	step.Cleanup(state)
+
+	state.Put(multistep.StateHalted, true)
+	state.Put("communicator", struct{}{})
+	step.Cleanup(state)
+
+	if len(calls) != 0 {
+		t.Fatalf("nothing should be captured: %v", calls)
+	}
+	if _, err := os.Stat(step.ConsoleOutputPath); !os.IsNotExist(err) {
+		t.Fatalf("console output shouldn't be saved: %v", err)
+	}
+}
+
+// testAnswerUi answers every question with the same answer.
+type testAnswerUi struct {
+	packersdk.Ui
+	answer string
+}
+
+func (u *testAnswerUi) Ask(query string) (string, error) {
+	return u.answer, nil
+}
+
+func TestStepCaptureAlicloudInstanceOutputDebug(t *testing.T) {
+	for answer, captured := range map[string]bool{"y\n": true, "\n": false} {
+		var calls []string
+		state := testCaptureInstanceOutputState(t, &calls)
+		state.Put("ui", &testAnswerUi{Ui: packersdk.TestUi(t), answer: answer})
+		dir := t.TempDir()
+		step := &stepCaptureAlicloudInstanceOutput{
+			ConsoleOutputPath: filepath.Join(dir, "console.log"),
+			ScreenshotPath:    filepath.Join(dir, "screenshot.jpg"),
+			Debug:             true,
+		}
+
+		step.Run(context.Background(), state)
+		state.Put("communicator", struct{}{})
+		step.Cleanup(state)
+
+		_, err := os.Stat(step.ConsoleOutputPath)
+		if captured != (err == nil) {
+			t.Fatalf("answer %q: bad capture: %v %v", answer, calls, err)
+		}
+		if _, ok := state.GetOk("error"); ok {
+			t.Fatalf("answer %q: error shouldn't be set", answer)
+		}
+	}
+}
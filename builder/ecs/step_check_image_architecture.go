@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// stepCheckAlicloudImageArchitecture makes sure the instance type is able to
+// boot the source image before any resource is created.
+type stepCheckAlicloudImageArchitecture struct{}
+
+func (s *stepCheckAlicloudImageArchitecture) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	sourceImage := state.Get("source_image").(*ecs.Image)
+
+	if err := validateImageArchitecture(client, config, sourceImage); err != nil {
+		return halt(state, err, "")
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckAlicloudImageArchitecture) Cleanup(multistep.StateBag) {}
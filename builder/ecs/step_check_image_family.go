@@ -39,6 +39,7 @@ func (s *stepCheckAlicloudImageFamily) Run(ctx context.Context, state multistep.
 
 	ui.Message(fmt.Sprintf("Found lastest image: %s by image family: %s", imageId, config.AlicloudImageFamily))
 
+	state.Put("source_image", &imagesResponse.Image)
 	return multistep.ActionContinue
 }
 
@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepCheckAlicloudLaunchTemplate checks that the launch template version
+// exists, and takes the instance type from it when `instance_type` isn't set,
+// so that the following steps can check the instance type. An `instance_type`
+// which is set overrides the instance type of the template.
+type stepCheckAlicloudLaunchTemplate struct {
+	LaunchTemplateId      string
+	LaunchTemplateName    string
+	LaunchTemplateVersion int
+}
+
+func (s *stepCheckAlicloudLaunchTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	template := s.LaunchTemplateId
+	if template == "" {
+		template = s.LaunchTemplateName
+	}
+	ui.Say(fmt.Sprintf("Checking launch template: %s", template))
+
+	request := ecs.CreateDescribeLaunchTemplateVersionsRequest()
+	request.RegionId = config.AlicloudRegion
+	request.LaunchTemplateId = s.LaunchTemplateId
+	request.LaunchTemplateName = s.LaunchTemplateName
+	request.DetailFlag = requests.NewBoolean(true)
+	if s.LaunchTemplateVersion > 0 {
+		request.LaunchTemplateVersion = &[]string{strconv.Itoa(s.LaunchTemplateVersion)}
+	} else {
+		request.DefaultVersion = requests.NewBoolean(true)
+	}
+
+	response, err := client.DescribeLaunchTemplateVersions(request)
+	if err != nil {
+		return halt(state, err, "Error querying launch template")
+	}
+
+	versions := response.LaunchTemplateVersionSets.LaunchTemplateVersionSet
+	if len(versions) == 0 {
+		version := "default version"
+		if s.LaunchTemplateVersion > 0 {
+			version = fmt.Sprintf("version %d", s.LaunchTemplateVersion)
+		}
+		return halt(state, fmt.Errorf("The %s of launch template %s doesn't exist", version, template), "")
+	}
+
+	version := versions[0]
+	ui.Message(fmt.Sprintf("Using version %d of launch template %s", version.VersionNumber, template))
+
+	if config.InstanceType == "" {
+		if version.LaunchTemplateData.InstanceType == "" {
+			return halt(state, fmt.Errorf("Launch template %s doesn't provide an instance type, instance_type must be specified", template), "")
+		}
+
+		config.InstanceType = version.LaunchTemplateData.InstanceType
+		ui.Message(fmt.Sprintf("Using instance type of launch template: %s", config.InstanceType))
+	} else if templateType := version.LaunchTemplateData.InstanceType; templateType != "" && templateType != config.InstanceType {
+		// The parameters of RunInstances take precedence over the template
+		ui.Message(fmt.Sprintf("Using instance_type %s instead of instance type of launch template: %s", config.InstanceType, templateType))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCheckAlicloudLaunchTemplate) Cleanup(multistep.StateBag) {}
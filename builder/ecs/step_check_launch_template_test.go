@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// testLaunchTemplateState serves the versions of a launch template whose
+// version 1 is the default one, and records the parameters of the requests.
+func testLaunchTemplateState(t *testing.T, config *Config, params *url.Values) multistep.StateBag {
+	versions := map[string]map[string]interface{}{
+		"1": {"VersionNumber": 1, "DefaultVersion": true, "LaunchTemplateData": map[string]string{"InstanceType": "ecs.g6.large"}},
+		"2": {"VersionNumber": 2, "LaunchTemplateData": map[string]string{}},
+	}
+	client := testClientWrapper(t, func(action string, requestParams url.Values) interface{} {
+		if action != "DescribeLaunchTemplateVersions" {
+			return testAPIError("InvalidAction.NotFound")
+		}
+		*params = requestParams
+
+		var sets []map[string]interface{}
+		if requestParams.Get("DefaultVersion") == "true" {
+			sets = append(sets, versions["1"])
+		} else if version, ok := versions[requestParams.Get("LaunchTemplateVersion.1")]; ok {
+			sets = append(sets, version)
+		}
+		return map[string]interface{}{"LaunchTemplateVersionSets": map[string]interface{}{"LaunchTemplateVersionSet": sets}}
+	})
+
+	config.AlicloudRegion = "cn-test"
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+	return state
+}
+
+func TestStepCheckAlicloudLaunchTemplate_defaultVersion(t *testing.T) {
+	var params url.Values
+	config := &Config{}
+	state := testLaunchTemplateState(t, config, &params)
+	step := &stepCheckAlicloudLaunchTemplate{LaunchTemplateName: "packer"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if params.Get("LaunchTemplateName") != "packer" || params.Get("LaunchTemplateVersion.1") != "" {
+		t.Fatalf("the default version of the template should be queried: %v", params)
+	}
+	if config.InstanceType != "ecs.g6.large" {
+		t.Fatalf("the instance type of the template should be used: %s", config.InstanceType)
+	}
+}
+
+func TestStepCheckAlicloudLaunchTemplate_version(t *testing.T) {
+	var params url.Values
+	config := &Config{}
+	state := testLaunchTemplateState(t, config, &params)
+	step := &stepCheckAlicloudLaunchTemplate{LaunchTemplateId: "lt-test", LaunchTemplateVersion: 2}
+
+	// Version 2 doesn't provide an instance type
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if params.Get("LaunchTemplateId") != "lt-test" || params.Get("DefaultVersion") != "" {
+		t.Fatalf("version 2 of the template should be queried: %v", params)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "instance_type must be specified") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	step.LaunchTemplateVersion = 3
+	state.Remove("error")
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); err.Error() != "The version 3 of launch template lt-test doesn't exist" {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepCheckAlicloudLaunchTemplate_instanceType(t *testing.T) {
+	var params url.Values
+	config := &Config{}
+	config.InstanceType = "ecs.c6.large"
+	state := testLaunchTemplateState(t, config, &params)
+	step := &stepCheckAlicloudLaunchTemplate{LaunchTemplateId: "lt-test"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if config.InstanceType != "ecs.c6.large" {
+		t.Fatalf("instance_type should take precedence over the template: %s", config.InstanceType)
+	}
+
+	step.LaunchTemplateVersion = 2
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if config.InstanceType != "ecs.c6.large" {
+		t.Fatalf("bad instance type: %s", config.InstanceType)
+	}
+}
+
+func TestStepCreateAlicloudInstance_launchTemplateRequest(t *testing.T) {
+	var params url.Values
+	client := testClientWrapper(t, func(action string, requestParams url.Values) interface{} {
+		if action != "RunInstances" {
+			return testAPIError("InvalidAction.NotFound")
+		}
+		params = requestParams
+		return map[string]interface{}{"InstanceIdSets": map[string]interface{}{"InstanceIdSet": []string{"i-test"}}}
+	})
+
+	config := &Config{}
+	config.LaunchTemplateId = "lt-test"
+	config.InstanceType = "ecs.g6.large"
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("networktype", InstanceNetWork(InstanceNetworkVpc))
+	state.Put("source_image", &ecs.Image{ImageId: "m-test"})
+
+	step := &stepCreateAlicloudInstance{RegionId: "cn-test"}
+	request, err := step.buildCreateInstanceRequest(state)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if _, err := client.RunInstances(request); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if params.Get("LaunchTemplateId") != "lt-test" || params.Get("ImageId") != "m-test" {
+		t.Fatalf("the template and the source image should be used: %v", params)
+	}
+	// The template provides the values which aren't set explicitly
+	for name := range params {
+		if strings.HasPrefix(name, "SystemDisk") || strings.HasPrefix(name, "SecurityGroupId") || name == "VSwitchId" {
+			t.Fatalf("%s shouldn't override the template: %v", name, params)
+		}
+	}
+}
@@ -58,3 +58,46 @@ func (s *stepCheckAlicloudSourceImage) Run(ctx context.Context, state multistep.
 }
 
 func (s *stepCheckAlicloudSourceImage) Cleanup(multistep.StateBag) {}
+
+// validateImageArchitecture makes sure the instance type is able to boot the
+// image, i.e. an arm64 image is not launched on an x86 instance type and vice
+// versa.
+func validateImageArchitecture(client *ClientWrapper, config *Config, image *ecs.Image) error {
+	expectedCpuArchitecture := imageCpuArchitecture(image.Architecture)
+	if expectedCpuArchitecture == "" {
+		return nil
+	}
+
+	describeInstanceTypesRequest := ecs.CreateDescribeInstanceTypesRequest()
+	describeInstanceTypesRequest.RegionId = config.AlicloudRegion
+	describeInstanceTypesRequest.InstanceTypes = &[]string{config.InstanceType}
+	instanceTypesResponse, err := client.DescribeInstanceTypes(describeInstanceTypesRequest)
+	if err != nil {
+		return fmt.Errorf("Error querying instance type %s: %s", config.InstanceType, err)
+	}
+
+	for _, instanceType := range instanceTypesResponse.InstanceTypes.InstanceType {
+		if instanceType.InstanceTypeId != config.InstanceType || instanceType.CpuArchitecture == "" {
+			continue
+		}
+
+		if instanceType.CpuArchitecture != expectedCpuArchitecture {
+			return fmt.Errorf("The architecture of image %s (%s) doesn't match the architecture of instance type %s (%s). "+
+				"Please choose an image and an instance type with the same architecture.",
+				image.ImageId, image.Architecture, config.InstanceType, instanceType.CpuArchitecture)
+		}
+	}
+
+	return nil
+}
+
+func imageCpuArchitecture(architecture string) string {
+	switch architecture {
+	case ImageArchitectureX86_64, ImageArchitectureI386:
+		return CpuArchitectureX86
+	case ImageArchitectureArm64:
+		return CpuArchitectureARM
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"testing"
+)
+
+func TestImageCpuArchitecture(t *testing.T) {
+	cases := map[string]string{
+		ImageArchitectureX86_64: CpuArchitectureX86,
+		ImageArchitectureI386:   CpuArchitectureX86,
+		ImageArchitectureArm64:  CpuArchitectureARM,
+		"":                      "",
+	}
+	for architecture, expected := range cases {
+		if actual := imageCpuArchitecture(architecture); actual != expected {
+			t.Fatalf("invalid value for %q, expected: %s, actual: %s", architecture, expected, actual)
+		}
+	}
+}
@@ -33,6 +33,7 @@ var allocateEipAddressRetryErrors = []string{
 
 func (s *stepConfigAlicloudEIP) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 	instance := state.Get("instance").(*ecs.Instance)
 
@@ -71,7 +72,8 @@ func (s *stepConfigAlicloudEIP) Run(ctx context.Context, state multistep.StateBa
 				RequestFunc: func() (responses.AcsResponse, error) {
 					return client.AllocateEipAddress(allocateEipAddressRequest)
 				},
-				EvalFunc: client.EvalCouldRetryResponse(allocateEipAddressRetryErrors, EvalRetryErrorType),
+				EvalFunc:     client.EvalCouldRetryResponse(allocateEipAddressRetryErrors, EvalRetryErrorType),
+				RetryTimeout: config.Timeouts.Eip,
 			})
 
 			if err != nil {
@@ -83,9 +85,10 @@ func (s *stepConfigAlicloudEIP) Run(ctx context.Context, state multistep.StateBa
 
 			allocateId = allocateEipAddressResponse.(*ecs.AllocateEipAddressResponse).AllocationId
 			s.allocatedId = allocateId
+			reportResourceCreated(state, ResourceTypeEip, allocateId)
 		}
 
-		err = s.waitForEipStatus(client, instance.RegionId, s.allocatedId, EipStatusAvailable)
+		_, err = client.WaitForEipStatus(instance.RegionId, s.allocatedId, EipStatusAvailable, config.Timeouts.Eip)
 		if err != nil {
 			return halt(state, err, "Error wait EIP available timeout")
 		}
@@ -101,7 +104,7 @@ func (s *stepConfigAlicloudEIP) Run(ctx context.Context, state multistep.StateBa
 			ui.Error(fmt.Sprintf("Error associating EIP: %s", err))
 		}
 
-		err = s.waitForEipStatus(client, instance.RegionId, s.allocatedId, EipStatusInUse)
+		_, err = client.WaitForEipStatus(instance.RegionId, s.allocatedId, EipStatusInUse, config.Timeouts.Eip)
 		if err != nil {
 			return halt(state, err, "Error wait EIP associating timeout")
 		}
@@ -146,6 +149,7 @@ func (s *stepConfigAlicloudEIP) Cleanup(state multistep.StateBag) {
 	cleanUpMessage(state, "EIP association")
 
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	instance := state.Get("instance").(*ecs.Instance)
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -156,7 +160,7 @@ func (s *stepConfigAlicloudEIP) Cleanup(state multistep.StateBag) {
 		ui.Say(fmt.Sprintf("Failed to unassociate EIP: %s", err))
 	}
 
-	if err := s.waitForEipStatus(client, instance.RegionId, s.allocatedId, EipStatusAvailable); err != nil {
+	if _, err := client.WaitForEipStatus(instance.RegionId, s.allocatedId, EipStatusAvailable, config.Timeouts.Eip); err != nil {
 		ui.Say(fmt.Sprintf("Timeout while unassociating EIP: %s", err))
 	}
 
@@ -167,44 +171,12 @@ func (s *stepConfigAlicloudEIP) Cleanup(state multistep.StateBag) {
 	releaseEipAddressRequest := ecs.CreateReleaseEipAddressRequest()
 	releaseEipAddressRequest.AllocationId = s.allocatedId
 	if _, err := client.ReleaseEipAddress(releaseEipAddressRequest); err != nil {
+		reportCleanupFailed(state, ResourceTypeEip, s.allocatedId, err)
 		ui.Say(fmt.Sprintf("Failed to release EIP: %s", err))
+		return
 	}
-}
-
-func (s *stepConfigAlicloudEIP) waitForEipStatus(client *ClientWrapper, regionId string, allocationId string, expectedStatus string) error {
-	describeEipAddressesRequest := ecs.CreateDescribeEipAddressesRequest()
-	describeEipAddressesRequest.RegionId = regionId
-	describeEipAddressesRequest.AllocationId = s.allocatedId
-
-	_, err := client.WaitForExpected(&WaitForExpectArgs{
-		RequestFunc: func() (responses.AcsResponse, error) {
-			response, err := client.DescribeEipAddresses(describeEipAddressesRequest)
-			if err == nil && len(response.EipAddresses.EipAddress) == 0 {
-				err = fmt.Errorf("EIP allocated is not found")
-			}
-
-			return response, err
-		},
-		EvalFunc: func(response responses.AcsResponse, err error) WaitForExpectEvalResult {
-			if err != nil {
-				return WaitForExpectToRetry
-			}
-
-			eipAddressesResponse := response.(*ecs.DescribeEipAddressesResponse)
-			eipAddresses := eipAddressesResponse.EipAddresses.EipAddress
-
-			for _, eipAddress := range eipAddresses {
-				if eipAddress.Status == expectedStatus {
-					return WaitForExpectSuccess
-				}
-			}
-
-			return WaitForExpectToRetry
-		},
-		RetryTimes: shortRetryTimes,
-	})
 
-	return err
+	reportResourceDeleted(state, ResourceTypeEip, s.allocatedId)
 }
 
 func (s *stepConfigAlicloudEIP) buildAllocateEipAddressRequest(state multistep.StateBag) *ecs.AllocateEipAddressRequest {
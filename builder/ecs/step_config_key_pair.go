@@ -6,15 +6,24 @@ package ecs
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
+// stepConfigAlicloudKeyPair runs in the setup graph, so the keypair and the
+// SSH keys are published to the state, and only set in the communicator config
+// once the graph has finished.
 type stepConfigAlicloudKeyPair struct {
 	Debug        bool
 	Comm         *communicator.Config
@@ -35,12 +44,40 @@ func (s *stepConfigAlicloudKeyPair) Run(ctx context.Context, state multistep.Sta
 			return multistep.ActionHalt
 		}
 
-		s.Comm.SSHPrivateKey = privateKeyBytes
+		state.Put("ssh_private_key", privateKeyBytes)
+		if s.Comm.SSHKeyPairName != "" {
+			return multistep.ActionContinue
+		}
+
+		publicKey, err := sshkey.PublicKeyFromPrivate(privateKeyBytes)
+		if err != nil {
+			return halt(state, err, "Error reading public key of SSH private key")
+		}
+
+		// Import the public key so that the instance trusts the private key
+		if err := s.importKeyPair(state, publicKey); err != nil {
+			return halt(state, err, "Error importing temporary keypair")
+		}
+
 		return multistep.ActionContinue
 	}
 
 	if s.Comm.SSHAgentAuth && s.Comm.SSHKeyPairName == "" {
-		ui.Say("Using SSH Agent with key pair in source image")
+		publicKey, err := sshAgentPublicKey()
+		if err != nil {
+			return halt(state, err, "Error reading public key from SSH Agent")
+		}
+
+		if publicKey == nil {
+			ui.Say("Using SSH Agent with key pair in source image")
+			return multistep.ActionContinue
+		}
+
+		ui.Say("Using SSH Agent with imported key pair")
+		if err := s.importKeyPair(state, ssh.MarshalAuthorizedKey(publicKey)); err != nil {
+			return halt(state, err, "Error importing temporary keypair")
+		}
+
 		return multistep.ActionContinue
 	}
 
@@ -51,27 +88,25 @@ func (s *stepConfigAlicloudKeyPair) Run(ctx context.Context, state multistep.Sta
 
 	if s.Comm.SSHTemporaryKeyPairName == "" {
 		ui.Say("Not using temporary keypair")
-		s.Comm.SSHKeyPairName = ""
+		state.Put("keypair_name", "")
 		return multistep.ActionContinue
 	}
 
-	client := state.Get("client").(*ClientWrapper)
-	ui.Say(fmt.Sprintf("Creating temporary keypair: %s", s.Comm.SSHTemporaryKeyPairName))
-
-	createKeyPairRequest := ecs.CreateCreateKeyPairRequest()
-	createKeyPairRequest.RegionId = s.RegionId
-	createKeyPairRequest.KeyPairName = s.Comm.SSHTemporaryKeyPairName
-	keyResp, err := client.CreateKeyPair(createKeyPairRequest)
+	// The private key is generated locally and never leaves this host, only
+	// the public key is sent to ECS
+	ui.Say(fmt.Sprintf("Creating temporary %s keypair locally...", temporaryKeyPairType(s.Comm.SSHTemporaryKeyPairType)))
+	pair, err := newTemporaryKeyPair(s.Comm.SSHTemporaryKeyPairType, s.Comm.SSHTemporaryKeyPairBits)
 	if err != nil {
 		return halt(state, err, "Error creating temporary keypair")
 	}
 
-	// Set the keyname so we know to delete it later
-	s.keyName = s.Comm.SSHTemporaryKeyPairName
-
 	// Set some state data for use in future steps
-	s.Comm.SSHKeyPairName = s.keyName
-	s.Comm.SSHPrivateKey = []byte(keyResp.PrivateKeyBody)
+	state.Put("ssh_private_key", pair.Private)
+	state.Put("ssh_public_key", pair.Public)
+
+	if err := s.importKeyPair(state, pair.Public); err != nil {
+		return halt(state, err, "Error importing temporary keypair")
+	}
 
 	// If we're in debug mode, output the private key to the working
 	// directory.
@@ -85,7 +120,7 @@ func (s *stepConfigAlicloudKeyPair) Run(ctx context.Context, state multistep.Sta
 		defer f.Close()
 
 		// Write the key out
-		if _, err := f.Write([]byte(keyResp.PrivateKeyBody)); err != nil {
+		if _, err := f.Write(pair.Private); err != nil {
 			state.Put("error", fmt.Errorf("Error saving debug key: %s", err))
 			return multistep.ActionHalt
 		}
@@ -102,11 +137,22 @@ func (s *stepConfigAlicloudKeyPair) Run(ctx context.Context, state multistep.Sta
 	return multistep.ActionContinue
 }
 
+func (s *stepConfigAlicloudKeyPair) applyResult(state multistep.StateBag) {
+	if privateKey, ok := state.GetOk("ssh_private_key"); ok {
+		s.Comm.SSHPrivateKey = privateKey.([]byte)
+	}
+	if publicKey, ok := state.GetOk("ssh_public_key"); ok {
+		s.Comm.SSHPublicKey = publicKey.([]byte)
+	}
+	if keyPairName, ok := state.GetOk("keypair_name"); ok {
+		s.Comm.SSHKeyPairName = keyPairName.(string)
+	}
+}
+
 func (s *stepConfigAlicloudKeyPair) Cleanup(state multistep.StateBag) {
-	// If no key name is set, then we never created it, so just return
-	// If we used an SSH private key file, do not go about deleting
-	// keypairs
-	if s.Comm.SSHPrivateKeyFile != "" || (s.Comm.SSHKeyPairName == "" && s.keyName == "") {
+	// If no key name is set, then we never created or imported it, so just
+	// return
+	if s.keyName == "" {
 		return
 	}
 
@@ -121,15 +167,97 @@ func (s *stepConfigAlicloudKeyPair) Cleanup(state multistep.StateBag) {
 	deleteKeyPairsRequest.KeyPairNames = fmt.Sprintf("[\"%s\"]", s.keyName)
 	_, err := client.DeleteKeyPairs(deleteKeyPairsRequest)
 	if err != nil {
+		reportCleanupFailed(state, ResourceTypeKeyPair, s.keyName, err)
 		ui.Error(fmt.Sprintf(
 			"Error cleaning up keypair. Please delete the key manually: %s", s.keyName))
+	} else {
+		reportResourceDeleted(state, ResourceTypeKeyPair, s.keyName)
 	}
 
 	// Also remove the physical key if we're debugging.
-	if s.Debug {
+	if s.Debug && s.Comm.SSHPrivateKeyFile == "" && !s.Comm.SSHAgentAuth {
 		if err := os.Remove(s.DebugKeyPath); err != nil {
 			ui.Error(fmt.Sprintf(
 				"Error removing debug key '%s': %s", s.DebugKeyPath, err))
 		}
 	}
 }
+
+// importKeyPair imports the public key, in the authorized_keys format, as a
+// temporary keypair, which is deleted in cleanup.
+func (s *stepConfigAlicloudKeyPair) importKeyPair(state multistep.StateBag, publicKey []byte) error {
+	client := state.Get("client").(*ClientWrapper)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	keyName := s.Comm.SSHTemporaryKeyPairName
+	if keyName == "" {
+		keyName = fmt.Sprintf("packer_%s", uuid.TimeOrderedUUID())
+	}
+	ui.Say(fmt.Sprintf("Importing temporary keypair: %s", keyName))
+
+	importKeyPairRequest := ecs.CreateImportKeyPairRequest()
+	importKeyPairRequest.RegionId = s.RegionId
+	importKeyPairRequest.KeyPairName = keyName
+	importKeyPairRequest.PublicKeyBody = strings.TrimSpace(string(publicKey))
+	if _, err := client.ImportKeyPair(importKeyPairRequest); err != nil {
+		return err
+	}
+
+	// Set the keyname so we know to delete it later
+	s.keyName = keyName
+	reportResourceCreated(state, ResourceTypeKeyPair, s.keyName)
+	state.Put("keypair_name", s.keyName)
+
+	return nil
+}
+
+func temporaryKeyPairType(keyType string) string {
+	if keyType == "" {
+		return TemporaryKeyPairTypeRSA
+	}
+
+	return strings.ToLower(keyType)
+}
+
+// newTemporaryKeyPair generates a keypair of the given type, `rsa` by default.
+// The bit size is only used by RSA keys.
+func newTemporaryKeyPair(keyType string, bits int) (*sshkey.Pair, error) {
+	keyType = temporaryKeyPairType(keyType)
+	if !ContainsInArray(TemporaryKeyPairTypes, keyType) {
+		return nil, fmt.Errorf("temporary_key_pair_type should be one of %s", strings.Join(TemporaryKeyPairTypes, ", "))
+	}
+
+	algorithm, err := sshkey.AlgorithmString(keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	return sshkey.GeneratePair(algorithm, nil, bits)
+}
+
+// sshAgentPublicKey returns the first key listed by the SSH agent, which is
+// the first key added to it, as shown by `ssh-add -L`. It returns nil if no SSH
+// agent is running or if the agent has no keys, in which case the key pair of
+// the source image is used.
+func sshAgentPublicKey() (ssh.PublicKey, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	return keys[0], nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testKeyPairState records the public keys imported by ImportKeyPair, by
+// keypair name.
+func testKeyPairState(t *testing.T, imported map[string]string) multistep.StateBag {
+	client := testClientWrapper(t, func(action string, params url.Values) interface{} {
+		switch action {
+		case "ImportKeyPair":
+			imported[params.Get("KeyPairName")] = params.Get("PublicKeyBody")
+			return map[string]string{"KeyPairName": params.Get("KeyPairName")}
+		case "DeleteKeyPairs":
+			return map[string]string{}
+		default:
+			return testAPIError("InvalidAction.NotFound")
+		}
+	})
+
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("ui", packersdk.TestUi(t))
+	return state
+}
+
+// checkImportedPublicKey checks that the imported public key belongs to the
+// private key published to the state.
+func checkImportedPublicKey(t *testing.T, state multistep.StateBag, importedKey string) {
+	signer, err := ssh.ParsePrivateKey(state.Get("ssh_private_key").([]byte))
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(importedKey))
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if !bytes.Equal(signer.PublicKey().Marshal(), publicKey.Marshal()) {
+		t.Fatalf("the imported public key doesn't match the private key: %s", importedKey)
+	}
+}
+
+func TestStepConfigAlicloudKeyPair_temporary(t *testing.T) {
+	imported := make(map[string]string)
+	state := testKeyPairState(t, imported)
+	comm := &communicator.Config{SSH: communicator.SSH{SSHTemporaryKeyPairName: "packer_test"}}
+	comm.SSHTemporaryKeyPairType = "ed25519"
+	step := &stepConfigAlicloudKeyPair{Comm: comm, RegionId: "cn-test"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if state.Get("keypair_name") != "packer_test" {
+		t.Fatalf("bad keypair_name: %v", state.Get("keypair_name"))
+	}
+	checkImportedPublicKey(t, state, imported["packer_test"])
+
+	// The keys are only set in the communicator config by the setup graph
+	if len(comm.SSHPrivateKey) != 0 {
+		t.Fatal("the communicator config shouldn't be changed by Run")
+	}
+	step.applyResult(state)
+	if comm.SSHKeyPairName != "packer_test" || !bytes.Equal(comm.SSHPrivateKey, state.Get("ssh_private_key").([]byte)) {
+		t.Fatalf("bad communicator config: %s", comm.SSHKeyPairName)
+	}
+
+	step.Cleanup(state)
+}
+
+func TestStepConfigAlicloudKeyPair_privateKeyFile(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	privateKeyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(privateKeyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	imported := make(map[string]string)
+	state := testKeyPairState(t, imported)
+	comm := &communicator.Config{SSH: communicator.SSH{
+		SSHPrivateKeyFile:       privateKeyFile,
+		SSHTemporaryKeyPairName: "packer_test",
+	}}
+	step := &stepConfigAlicloudKeyPair{Comm: comm, RegionId: "cn-test"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if state.Get("keypair_name") != "packer_test" {
+		t.Fatalf("bad keypair_name: %v", state.Get("keypair_name"))
+	}
+	checkImportedPublicKey(t, state, imported["packer_test"])
+}
+
+func TestNewTemporaryKeyPair(t *testing.T) {
+	cases := []struct {
+		keyType       string
+		bits          int
+		publicKeyType string
+	}{
+		{"", 2048, ssh.KeyAlgoRSA},
+		{"rsa", 2048, ssh.KeyAlgoRSA},
+		{"ED25519", 0, ssh.KeyAlgoED25519},
+	}
+
+	for _, c := range cases {
+		pair, err := newTemporaryKeyPair(c.keyType, c.bits)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey(pair.Public)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if publicKey.Type() != c.publicKeyType {
+			t.Fatalf("bad public key type for %q: %s", c.keyType, publicKey.Type())
+		}
+
+		signer, err := ssh.ParsePrivateKey(pair.Private)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if string(signer.PublicKey().Marshal()) != string(publicKey.Marshal()) {
+			t.Fatalf("private key of %q doesn't match the public key", c.keyType)
+		}
+	}
+
+	if _, err := newTemporaryKeyPair("dsa", 0); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+// testSSHAgent serves the keyring on a socket set as SSH_AUTH_SOCK.
+func testSSHAgent(t *testing.T, keyring agent.Agent) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+}
+
+func TestSSHAgentPublicKey(t *testing.T) {
+	keyring := agent.NewKeyring()
+	testSSHAgent(t, keyring)
+
+	// The key pair in the source image is used when the agent has no keys
+	publicKey, err := sshAgentPublicKey()
+	if err != nil || publicKey != nil {
+		t.Fatalf("should have no key: %v %v", publicKey, err)
+	}
+
+	var publicKeys []ed25519.PublicKey
+	for i := 0; i < 2; i++ {
+		public, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: private}); err != nil {
+			t.Fatal(err)
+		}
+		publicKeys = append(publicKeys, public)
+	}
+
+	publicKey, err = sshAgentPublicKey()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected, err := ssh.NewPublicKey(publicKeys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(publicKey.Marshal()) != string(expected.Marshal()) {
+		t.Fatalf("the first key added to the agent should be used: %s", ssh.MarshalAuthorizedKey(publicKey))
+	}
+}
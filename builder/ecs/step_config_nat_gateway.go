@@ -0,0 +1,361 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// stepConfigAlicloudNatGateway creates a temporary enhanced NAT gateway with an
+// EIP and a SNAT entry for the vswitch of the instance, so that an instance
+// without a public IP address can reach the internet during the build.
+type stepConfigAlicloudNatGateway struct {
+	RegionId                string
+	InternetChargeType      string
+	InternetMaxBandwidthOut int
+
+	natGatewayId string
+	snatTableId  string
+	allocatedId  string
+	associated   bool
+	snatEntryId  string
+}
+
+var createNatGatewayRetryErrors = []string{
+	"OperationConflict",
+	"IncorrectStatus.VSWITCH",
+	"IncorrectVpcStatus",
+	"TaskConflict",
+}
+
+var createSnatEntryRetryErrors = []string{
+	"OperationConflict",
+	"IncorrectStatus.NATGW",
+	"OperationUnsupported.EipNatBWPCheck",
+	"EIP_NOT_IN_GATEWAY",
+	"TaskConflict",
+}
+
+var deleteNatGatewayRetryErrors = []string{
+	"OperationConflict",
+	"IncorrectStatus.NatGateway",
+	"DependencyViolation.EIPS",
+	"DependencyViolation.SnatEntry",
+	"TaskConflict",
+}
+
+var deleteSnatEntryRetryErrors = []string{
+	"OperationConflict",
+	"IncorrectStatus.SNATENTRY",
+	"IncorrectStatus.NATGW",
+	"TaskConflict",
+}
+
+func (s *stepConfigAlicloudNatGateway) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	vpcId := state.Get("vpcid").(string)
+	vswitchId := state.Get("vswitchid").(string)
+
+	ui.Say("Creating temporary NAT gateway...")
+	createNatGatewayRequest := s.buildCreateNatGatewayRequest(vpcId, vswitchId)
+	createNatGatewayResponse, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			return client.VpcClient.CreateNatGateway(createNatGatewayRequest)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse(createNatGatewayRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+	})
+	if err != nil {
+		return halt(state, err, "Error creating NAT gateway")
+	}
+
+	natGatewayResponse := createNatGatewayResponse.(*vpc.CreateNatGatewayResponse)
+	s.natGatewayId = natGatewayResponse.NatGatewayId
+	reportResourceCreated(state, ResourceTypeNatGateway, s.natGatewayId)
+	if len(natGatewayResponse.SnatTableIds.SnatTableId) == 0 {
+		return halt(state, fmt.Errorf("NAT gateway %s has no SNAT table", s.natGatewayId), "")
+	}
+	s.snatTableId = natGatewayResponse.SnatTableIds.SnatTableId[0]
+
+	if err := s.waitForNatGatewayStatus(client, config.Timeouts.Network, NatGatewayStatusAvailable); err != nil {
+		return halt(state, err, "Error waiting for NAT gateway to become available")
+	}
+	ui.Message(fmt.Sprintf("Created NAT gateway: %s", s.natGatewayId))
+
+	ui.Say("Allocating EIP for NAT gateway...")
+	allocateEipAddressRequest := ecs.CreateAllocateEipAddressRequest()
+	allocateEipAddressRequest.ClientToken = uuid.TimeOrderedUUID()
+	allocateEipAddressRequest.RegionId = s.RegionId
+	allocateEipAddressRequest.InternetChargeType = s.InternetChargeType
+	allocateEipAddressRequest.Bandwidth = convertNumber(s.InternetMaxBandwidthOut)
+	allocateEipAddressResponse, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			return client.AllocateEipAddress(allocateEipAddressRequest)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse(allocateEipAddressRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Eip,
+	})
+	if err != nil {
+		return halt(state, err, "Error allocating EIP for NAT gateway")
+	}
+
+	eipAddress := allocateEipAddressResponse.(*ecs.AllocateEipAddressResponse).EipAddress
+	s.allocatedId = allocateEipAddressResponse.(*ecs.AllocateEipAddressResponse).AllocationId
+	reportResourceCreated(state, ResourceTypeEip, s.allocatedId)
+	ui.Message(fmt.Sprintf("Allocated EIP: %s", eipAddress))
+
+	if _, err := client.WaitForEipStatus(s.RegionId, s.allocatedId, EipStatusAvailable, config.Timeouts.Eip); err != nil {
+		return halt(state, err, "Error waiting for EIP to become available")
+	}
+
+	associateEipAddressRequest := ecs.CreateAssociateEipAddressRequest()
+	associateEipAddressRequest.AllocationId = s.allocatedId
+	associateEipAddressRequest.InstanceId = s.natGatewayId
+	associateEipAddressRequest.InstanceType = EipInstanceTypeNat
+	if _, err := client.AssociateEipAddress(associateEipAddressRequest); err != nil {
+		return halt(state, err, "Error associating EIP with NAT gateway")
+	}
+	s.associated = true
+
+	if _, err := client.WaitForEipStatus(s.RegionId, s.allocatedId, EipStatusInUse, config.Timeouts.Eip); err != nil {
+		return halt(state, err, "Error waiting for EIP to be associated with NAT gateway")
+	}
+
+	ui.Say("Creating SNAT entry for vswitch...")
+	createSnatEntryRequest := vpc.CreateCreateSnatEntryRequest()
+	createSnatEntryRequest.RegionId = s.RegionId
+	createSnatEntryRequest.ClientToken = uuid.TimeOrderedUUID()
+	createSnatEntryRequest.SnatTableId = s.snatTableId
+	createSnatEntryRequest.SourceVSwitchId = vswitchId
+	createSnatEntryRequest.SnatIp = eipAddress
+	createSnatEntryResponse, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			return client.VpcClient.CreateSnatEntry(createSnatEntryRequest)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse(createSnatEntryRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("SNAT entry of %s to be created", s.snatTableId),
+	})
+	if err != nil {
+		return halt(state, err, "Error creating SNAT entry")
+	}
+
+	s.snatEntryId = createSnatEntryResponse.(*vpc.CreateSnatEntryResponse).SnatEntryId
+	reportResourceCreated(state, ResourceTypeSnatEntry, s.snatEntryId)
+	if err := s.waitForSnatEntryStatus(client, config.Timeouts.Network, SnatEntryStatusAvailable); err != nil {
+		return halt(state, err, "Error waiting for SNAT entry to become available")
+	}
+	ui.Message(fmt.Sprintf("Created SNAT entry: %s", s.snatEntryId))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigAlicloudNatGateway) Cleanup(state multistep.StateBag) {
+	if s.natGatewayId == "" && s.allocatedId == "" {
+		return
+	}
+
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.snatEntryId != "" {
+		cleanUpMessage(state, "SNAT entry")
+		_, err := client.WaitForExpected(&WaitForExpectArgs{
+			RequestFunc: func() (responses.AcsResponse, error) {
+				request := vpc.CreateDeleteSnatEntryRequest()
+				request.RegionId = s.RegionId
+				request.SnatTableId = s.snatTableId
+				request.SnatEntryId = s.snatEntryId
+				return client.VpcClient.DeleteSnatEntry(request)
+			},
+			EvalFunc:     client.EvalCouldRetryResponse(deleteSnatEntryRetryErrors, EvalRetryErrorType),
+			RetryTimeout: config.Timeouts.Network,
+			WaitingFor:   fmt.Sprintf("SNAT entry %s to be deleted", s.snatEntryId),
+		})
+		if err == nil {
+			err = s.waitForSnatEntryStatus(client, config.Timeouts.Network, "")
+		}
+		if err != nil {
+			reportCleanupFailed(state, ResourceTypeSnatEntry, s.snatEntryId, err)
+			ui.Error(fmt.Sprintf("Error deleting SNAT entry, it may still be around: %s", err))
+		} else {
+			reportResourceDeleted(state, ResourceTypeSnatEntry, s.snatEntryId)
+		}
+	}
+
+	if s.allocatedId != "" {
+		if s.associated {
+			cleanUpMessage(state, "NAT gateway EIP association")
+			unassociateEipAddressRequest := ecs.CreateUnassociateEipAddressRequest()
+			unassociateEipAddressRequest.AllocationId = s.allocatedId
+			unassociateEipAddressRequest.InstanceId = s.natGatewayId
+			unassociateEipAddressRequest.InstanceType = EipInstanceTypeNat
+			if _, err := client.UnassociateEipAddress(unassociateEipAddressRequest); err != nil {
+				ui.Say(fmt.Sprintf("Failed to unassociate EIP: %s", err))
+			}
+
+			if _, err := client.WaitForEipStatus(s.RegionId, s.allocatedId, EipStatusAvailable, config.Timeouts.Eip); err != nil {
+				ui.Say(fmt.Sprintf("Timeout while unassociating EIP: %s", err))
+			}
+		}
+
+		cleanUpMessage(state, "NAT gateway EIP")
+		releaseEipAddressRequest := ecs.CreateReleaseEipAddressRequest()
+		releaseEipAddressRequest.AllocationId = s.allocatedId
+		if _, err := client.ReleaseEipAddress(releaseEipAddressRequest); err != nil {
+			reportCleanupFailed(state, ResourceTypeEip, s.allocatedId, err)
+			ui.Say(fmt.Sprintf("Failed to release EIP: %s", err))
+		} else {
+			reportResourceDeleted(state, ResourceTypeEip, s.allocatedId)
+		}
+	}
+
+	if s.natGatewayId == "" {
+		return
+	}
+
+	cleanUpMessage(state, "NAT gateway")
+	_, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			request := vpc.CreateDeleteNatGatewayRequest()
+			request.RegionId = s.RegionId
+			request.NatGatewayId = s.natGatewayId
+			request.Force = requests.NewBoolean(true)
+			return client.VpcClient.DeleteNatGateway(request)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse(deleteNatGatewayRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("NAT gateway %s to be deleted", s.natGatewayId),
+	})
+	// The vswitch can only be deleted once the NAT gateway is gone
+	if err == nil {
+		err = s.waitForNatGatewayStatus(client, config.Timeouts.Network, "")
+	}
+	if err != nil {
+		reportCleanupFailed(state, ResourceTypeNatGateway, s.natGatewayId, err)
+		ui.Error(fmt.Sprintf("Error deleting NAT gateway, it may still be around: %s", err))
+		return
+	}
+
+	reportResourceDeleted(state, ResourceTypeNatGateway, s.natGatewayId)
+}
+
+func (s *stepConfigAlicloudNatGateway) buildCreateNatGatewayRequest(vpcId string, vswitchId string) *vpc.CreateNatGatewayRequest {
+	request := vpc.CreateCreateNatGatewayRequest()
+	request.RegionId = s.RegionId
+	request.ClientToken = uuid.TimeOrderedUUID()
+	request.VpcId = vpcId
+	request.VSwitchId = vswitchId
+	request.NatType = NatGatewayTypeEnhanced
+	request.NetworkType = NatGatewayNetworkTypeInternet
+	request.InstanceChargeType = InstanceChargeTypePostPaid
+	request.InternetChargeType = NatGatewayInternetChargeTypeByLcu
+	request.Name = fmt.Sprintf("packer_%s", uuid.TimeOrderedUUID())
+	request.Description = "Temporary NAT gateway created by Packer"
+
+	return request
+}
+
+// waitForNatGatewayStatus waits for the NAT gateway to reach the expected
+// status, or to be deleted if the expected status is empty.
+func (s *stepConfigAlicloudNatGateway) waitForNatGatewayStatus(client *ClientWrapper, timeout time.Duration, expectedStatus string) error {
+	_, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			request := vpc.CreateDescribeNatGatewaysRequest()
+			request.RegionId = s.RegionId
+			request.NatGatewayId = s.natGatewayId
+			return client.VpcClient.DescribeNatGateways(request)
+		},
+		EvalFunc: func(response responses.AcsResponse, err error) WaitForExpectEvalResult {
+			if err != nil {
+				return WaitForExpectToRetry
+			}
+
+			natGateways := response.(*vpc.DescribeNatGatewaysResponse).NatGateways.NatGateway
+			if expectedStatus == "" && len(natGateways) == 0 {
+				return WaitForExpectSuccess
+			}
+			for _, natGateway := range natGateways {
+				if natGateway.Status == expectedStatus {
+					return WaitForExpectSuccess
+				}
+			}
+
+			return WaitForExpectToRetry
+		},
+		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("NAT gateway %s to be %s", s.natGatewayId, statusOrDeleted(expectedStatus)),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, natGateway := range response.(*vpc.DescribeNatGatewaysResponse).NatGateways.NatGateway {
+				return natGateway.Status
+			}
+			return "not found"
+		},
+	})
+
+	return err
+}
+
+// waitForSnatEntryStatus waits for the SNAT entry to reach the expected
+// status, or to be deleted if the expected status is empty.
+func (s *stepConfigAlicloudNatGateway) waitForSnatEntryStatus(client *ClientWrapper, timeout time.Duration, expectedStatus string) error {
+	_, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			request := vpc.CreateDescribeSnatTableEntriesRequest()
+			request.RegionId = s.RegionId
+			request.SnatTableId = s.snatTableId
+			request.SnatEntryId = s.snatEntryId
+			return client.VpcClient.DescribeSnatTableEntries(request)
+		},
+		EvalFunc: func(response responses.AcsResponse, err error) WaitForExpectEvalResult {
+			if err != nil {
+				return WaitForExpectToRetry
+			}
+
+			snatEntries := response.(*vpc.DescribeSnatTableEntriesResponse).SnatTableEntries.SnatTableEntry
+			if expectedStatus == "" && len(snatEntries) == 0 {
+				return WaitForExpectSuccess
+			}
+			for _, snatEntry := range snatEntries {
+				if snatEntry.Status == expectedStatus {
+					return WaitForExpectSuccess
+				}
+			}
+
+			return WaitForExpectToRetry
+		},
+		RetryTimeout: timeout,
+		WaitingFor:   fmt.Sprintf("SNAT entry %s to be %s", s.snatEntryId, statusOrDeleted(expectedStatus)),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, snatEntry := range response.(*vpc.DescribeSnatTableEntriesResponse).SnatTableEntries.SnatTableEntry {
+				return snatEntry.Status
+			}
+			return "not found"
+		},
+	})
+
+	return err
+}
+
+// statusOrDeleted returns the expected status in the timeout errors, which is
+// empty when the resource is expected to be deleted.
+func statusOrDeleted(expectedStatus string) string {
+	if expectedStatus == "" {
+		return "deleted"
+	}
+	return expectedStatus
+}
@@ -0,0 +1,210 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// testNatGatewayAPI fakes the NAT gateway, EIP and SNAT entry APIs. It records
+// the calls which change a resource, and fails the actions of failures with
+// the given error code.
+type testNatGatewayAPI struct {
+	failures map[string]testAPIError
+
+	lock       sync.Mutex
+	calls      []string
+	natGateway string
+	eip        string
+	snatEntry  string
+}
+
+func (a *testNatGatewayAPI) handle(action string, params url.Values) interface{} {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !strings.HasPrefix(action, "Describe") {
+		a.calls = append(a.calls, action)
+	}
+	if code, ok := a.failures[action]; ok {
+		return code
+	}
+
+	switch action {
+	case "CreateNatGateway":
+		a.natGateway = NatGatewayStatusAvailable
+		return map[string]interface{}{
+			"NatGatewayId": "ngw-test",
+			"SnatTableIds": map[string][]string{"SnatTableId": {"stb-test"}},
+		}
+	case "DescribeNatGateways":
+		return map[string]interface{}{"NatGateways": map[string]interface{}{"NatGateway": testStatusList(a.natGateway)}}
+	case "DeleteNatGateway":
+		a.natGateway = ""
+	case "AllocateEipAddress":
+		a.eip = EipStatusAvailable
+		return map[string]string{"AllocationId": "eip-test", "EipAddress": "192.0.2.1"}
+	case "DescribeEipAddresses":
+		return map[string]interface{}{"EipAddresses": map[string]interface{}{"EipAddress": testStatusList(a.eip)}}
+	case "AssociateEipAddress":
+		a.eip = EipStatusInUse
+	case "UnassociateEipAddress":
+		a.eip = EipStatusAvailable
+	case "ReleaseEipAddress":
+		a.eip = ""
+	case "CreateSnatEntry":
+		a.snatEntry = SnatEntryStatusAvailable
+		return map[string]string{"SnatEntryId": "snat-test"}
+	case "DescribeSnatTableEntries":
+		return map[string]interface{}{"SnatTableEntries": map[string]interface{}{"SnatTableEntry": testStatusList(a.snatEntry)}}
+	case "DeleteSnatEntry":
+		a.snatEntry = ""
+	default:
+		return testAPIError("InvalidAction.NotFound")
+	}
+
+	return map[string]string{}
+}
+
+// testStatusList lists a resource with the given status, or none if the status
+// is empty.
+func testStatusList(status string) []map[string]string {
+	if status == "" {
+		return []map[string]string{}
+	}
+	return []map[string]string{{"Status": status}}
+}
+
+func testNatGatewayState(t *testing.T, api *testNatGatewayAPI) multistep.StateBag {
+	config := &Config{}
+	config.Timeouts.Network = time.Second
+	config.Timeouts.Eip = time.Second
+
+	state := new(multistep.BasicStateBag)
+	state.Put("client", testClientWrapper(t, api.handle))
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("vpcid", "vpc-test")
+	state.Put("vswitchid", "vsw-test")
+	return state
+}
+
+func TestStepConfigAlicloudNatGateway_cleanupOrder(t *testing.T) {
+	api := &testNatGatewayAPI{}
+	state := testNatGatewayState(t, api)
+	step := &stepConfigAlicloudNatGateway{RegionId: "cn-test"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	step.Cleanup(state)
+
+	expected := []string{
+		"CreateNatGateway",
+		"AllocateEipAddress",
+		"AssociateEipAddress",
+		"CreateSnatEntry",
+		"DeleteSnatEntry",
+		"UnassociateEipAddress",
+		"ReleaseEipAddress",
+		"DeleteNatGateway",
+	}
+	if !reflect.DeepEqual(api.calls, expected) {
+		t.Fatalf("bad calls: %v", api.calls)
+	}
+}
+
+func TestStepConfigAlicloudNatGateway_partialFailure(t *testing.T) {
+	cases := []struct {
+		failure  string
+		expected []string
+	}{
+		{
+			failure:  "CreateNatGateway",
+			expected: []string{"CreateNatGateway"},
+		},
+		{
+			failure:  "AllocateEipAddress",
+			expected: []string{"CreateNatGateway", "AllocateEipAddress", "DeleteNatGateway"},
+		},
+		{
+			failure: "AssociateEipAddress",
+			expected: []string{
+				"CreateNatGateway",
+				"AllocateEipAddress",
+				"AssociateEipAddress",
+				"ReleaseEipAddress",
+				"DeleteNatGateway",
+			},
+		},
+		{
+			failure: "CreateSnatEntry",
+			expected: []string{
+				"CreateNatGateway",
+				"AllocateEipAddress",
+				"AssociateEipAddress",
+				"CreateSnatEntry",
+				"UnassociateEipAddress",
+				"ReleaseEipAddress",
+				"DeleteNatGateway",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.failure, func(t *testing.T) {
+			api := &testNatGatewayAPI{failures: map[string]testAPIError{tc.failure: "Forbidden"}}
+			state := testNatGatewayState(t, api)
+			step := &stepConfigAlicloudNatGateway{RegionId: "cn-test"}
+
+			if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+				t.Fatalf("bad action: %#v", action)
+			}
+			if _, ok := state.GetOk("error"); !ok {
+				t.Fatal("should have error")
+			}
+			state.Put(multistep.StateHalted, true)
+			step.Cleanup(state)
+
+			if !reflect.DeepEqual(api.calls, tc.expected) {
+				t.Fatalf("bad calls: %v", api.calls)
+			}
+		})
+	}
+}
+
+func TestStepConfigAlicloudNatGateway_cleanupFailure(t *testing.T) {
+	api := &testNatGatewayAPI{}
+	state := testNatGatewayState(t, api)
+	report := NewBuildReport("test", "cn-test")
+	state.Put("build_report", report)
+	step := &stepConfigAlicloudNatGateway{RegionId: "cn-test"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+
+	// The other resources are still deleted when the SNAT entry can't be deleted
+	api.lock.Lock()
+	api.failures = map[string]testAPIError{"DeleteSnatEntry": "Forbidden"}
+	api.lock.Unlock()
+	step.Cleanup(state)
+
+	expected := []string{"DeleteSnatEntry", "UnassociateEipAddress", "ReleaseEipAddress", "DeleteNatGateway"}
+	if calls := api.calls[len(api.calls)-len(expected):]; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad calls: %v", api.calls)
+	}
+	if len(report.FailedCleanups) != 1 || report.FailedCleanups[0].Id != "snat-test" {
+		t.Fatalf("the SNAT entry should be reported as not cleaned up: %#v", report.FailedCleanups)
+	}
+}
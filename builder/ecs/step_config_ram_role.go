@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ram"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+)
+
+// stepConfigAlicloudRamRole creates a temporary RAM role which is trusted by
+// ECS, with the policy document attached, for the instance to be launched with.
+// Since ECS may not know the role yet, the instance creation is retried until
+// it does.
+type stepConfigAlicloudRamRole struct {
+	PolicyDocument string
+
+	roleName       string
+	policyName     string
+	policyAttached bool
+}
+
+func (s *stepConfigAlicloudRamRole) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	name := fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
+
+	ui.Say(fmt.Sprintf("Creating temporary RAM role: %s", name))
+	createRoleRequest := ram.CreateCreateRoleRequest()
+	createRoleRequest.SetScheme(requests.HTTPS)
+	createRoleRequest.RoleName = name
+	createRoleRequest.AssumeRolePolicyDocument = RamRoleEcsTrustPolicy
+	createRoleRequest.Description = "Temporary RAM role created by Packer"
+	if _, err := client.RamClient.CreateRole(createRoleRequest); err != nil {
+		return halt(state, err, "Error creating temporary RAM role")
+	}
+	s.roleName = name
+	reportResourceCreated(state, ResourceTypeRamRole, s.roleName)
+
+	createPolicyRequest := ram.CreateCreatePolicyRequest()
+	createPolicyRequest.SetScheme(requests.HTTPS)
+	createPolicyRequest.PolicyName = name
+	createPolicyRequest.PolicyDocument = s.PolicyDocument
+	createPolicyRequest.Description = "Temporary RAM policy created by Packer"
+	if _, err := client.RamClient.CreatePolicy(createPolicyRequest); err != nil {
+		return halt(state, err, "Error creating temporary RAM policy")
+	}
+	s.policyName = name
+	reportResourceCreated(state, ResourceTypeRamPolicy, s.policyName)
+
+	attachPolicyToRoleRequest := ram.CreateAttachPolicyToRoleRequest()
+	attachPolicyToRoleRequest.SetScheme(requests.HTTPS)
+	attachPolicyToRoleRequest.PolicyName = s.policyName
+	attachPolicyToRoleRequest.PolicyType = RamPolicyTypeCustom
+	attachPolicyToRoleRequest.RoleName = s.roleName
+	if _, err := client.RamClient.AttachPolicyToRole(attachPolicyToRoleRequest); err != nil {
+		return halt(state, err, "Error attaching temporary RAM policy to role")
+	}
+	s.policyAttached = true
+
+	_, err := client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			request := ram.CreateGetRoleRequest()
+			request.SetScheme(requests.HTTPS)
+			request.RoleName = s.roleName
+			return client.RamClient.GetRole(request)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse([]string{RamRoleNotExistError}, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+	})
+	if err != nil {
+		return halt(state, err, "Timeout waiting for temporary RAM role")
+	}
+
+	state.Put("temporary_ram_role_name", s.roleName)
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigAlicloudRamRole) applyResult(state multistep.StateBag) {
+	config := state.Get("config").(*Config)
+	if roleName, ok := state.GetOk("temporary_ram_role_name"); ok {
+		config.RamRoleName = roleName.(string)
+	}
+}
+
+func (s *stepConfigAlicloudRamRole) Cleanup(state multistep.StateBag) {
+	if s.roleName == "" {
+		return
+	}
+
+	client := state.Get("client").(*ClientWrapper)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if s.policyAttached {
+		detachPolicyFromRoleRequest := ram.CreateDetachPolicyFromRoleRequest()
+		detachPolicyFromRoleRequest.SetScheme(requests.HTTPS)
+		detachPolicyFromRoleRequest.PolicyName = s.policyName
+		detachPolicyFromRoleRequest.PolicyType = RamPolicyTypeCustom
+		detachPolicyFromRoleRequest.RoleName = s.roleName
+		if _, err := client.RamClient.DetachPolicyFromRole(detachPolicyFromRoleRequest); err != nil {
+			ui.Say(fmt.Sprintf("Failed to detach temporary RAM policy from role: %s", err))
+		}
+	}
+
+	if s.policyName != "" {
+		cleanUpMessage(state, "temporary RAM policy")
+		deletePolicyRequest := ram.CreateDeletePolicyRequest()
+		deletePolicyRequest.SetScheme(requests.HTTPS)
+		deletePolicyRequest.PolicyName = s.policyName
+		if _, err := client.RamClient.DeletePolicy(deletePolicyRequest); err != nil {
+			reportCleanupFailed(state, ResourceTypeRamPolicy, s.policyName, err)
+			ui.Error(fmt.Sprintf("Error deleting temporary RAM policy, it may still be around: %s", err))
+		} else {
+			reportResourceDeleted(state, ResourceTypeRamPolicy, s.policyName)
+		}
+	}
+
+	cleanUpMessage(state, "temporary RAM role")
+	deleteRoleRequest := ram.CreateDeleteRoleRequest()
+	deleteRoleRequest.SetScheme(requests.HTTPS)
+	deleteRoleRequest.RoleName = s.roleName
+	if _, err := client.RamClient.DeleteRole(deleteRoleRequest); err != nil {
+		reportCleanupFailed(state, ResourceTypeRamRole, s.roleName, err)
+		ui.Error(fmt.Sprintf("Error deleting temporary RAM role, it may still be around: %s", err))
+		return
+	}
+
+	reportResourceDeleted(state, ResourceTypeRamRole, s.roleName)
+}
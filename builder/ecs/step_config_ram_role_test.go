@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// testRamAPI fakes the RAM API, records the calls, and fails the actions of
+// failures with the given error code.
+type testRamAPI struct {
+	failures map[string]testAPIError
+
+	lock  sync.Mutex
+	calls []string
+	roles map[string]bool
+}
+
+func (a *testRamAPI) handle(action string, params url.Values) interface{} {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.calls = append(a.calls, action)
+	if code, ok := a.failures[action]; ok {
+		return code
+	}
+
+	switch action {
+	case "CreateRole":
+		a.roles[params.Get("RoleName")] = true
+	case "GetRole":
+		if !a.roles[params.Get("RoleName")] {
+			return testAPIError(RamRoleNotExistError)
+		}
+	case "DeleteRole":
+		delete(a.roles, params.Get("RoleName"))
+	case "CreatePolicy", "AttachPolicyToRole", "DetachPolicyFromRole", "DeletePolicy":
+	default:
+		return testAPIError("InvalidAction.NotFound")
+	}
+
+	return map[string]string{"RequestId": "test"}
+}
+
+func testRamRoleState(t *testing.T, api *testRamAPI) multistep.StateBag {
+	api.roles = make(map[string]bool)
+
+	state := new(multistep.BasicStateBag)
+	state.Put("client", testClientWrapper(t, api.handle))
+	state.Put("config", &Config{})
+	state.Put("ui", packersdk.TestUi(t))
+	return state
+}
+
+func TestStepConfigAlicloudRamRole(t *testing.T) {
+	api := &testRamAPI{}
+	state := testRamRoleState(t, api)
+	step := &stepConfigAlicloudRamRole{PolicyDocument: "{}"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+
+	roleName, ok := state.GetOk("temporary_ram_role_name")
+	if !ok || !strings.HasPrefix(roleName.(string), "packer-") {
+		t.Fatalf("the temporary RAM role should be published: %v", roleName)
+	}
+	step.applyResult(state)
+	if config := state.Get("config").(*Config); config.RamRoleName != roleName {
+		t.Fatalf("the instance should use the temporary RAM role: %s", config.RamRoleName)
+	}
+
+	step.Cleanup(state)
+	expected := []string{
+		"CreateRole",
+		"CreatePolicy",
+		"AttachPolicyToRole",
+		"GetRole",
+		"DetachPolicyFromRole",
+		"DeletePolicy",
+		"DeleteRole",
+	}
+	if !reflect.DeepEqual(api.calls, expected) {
+		t.Fatalf("bad calls: %v", api.calls)
+	}
+	if len(api.roles) != 0 {
+		t.Fatalf("the temporary RAM role should be deleted: %v", api.roles)
+	}
+}
+
+func TestStepConfigAlicloudRamRole_attachFailure(t *testing.T) {
+	api := &testRamAPI{failures: map[string]testAPIError{"AttachPolicyToRole": "EntityNotExist.Policy"}}
+	state := testRamRoleState(t, api)
+	step := &stepConfigAlicloudRamRole{PolicyDocument: "{}"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("temporary_ram_role_name"); ok {
+		t.Fatal("the temporary RAM role shouldn't be published")
+	}
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	expected := []string{"CreateRole", "CreatePolicy", "AttachPolicyToRole", "DeletePolicy", "DeleteRole"}
+	if !reflect.DeepEqual(api.calls, expected) {
+		t.Fatalf("the policy which isn't attached shouldn't be detached: %v", api.calls)
+	}
+}
+
+func TestStepConfigAlicloudRamRole_cleanupFailure(t *testing.T) {
+	api := &testRamAPI{}
+	state := testRamRoleState(t, api)
+	report := NewBuildReport("test", "cn-test")
+	state.Put("build_report", report)
+	step := &stepConfigAlicloudRamRole{PolicyDocument: "{}"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+
+	api.lock.Lock()
+	api.failures = map[string]testAPIError{"DeletePolicy": "DeleteConflict.Policy.Version"}
+	api.lock.Unlock()
+	step.Cleanup(state)
+
+	// The role is still deleted when the policy can't be deleted
+	if api.calls[len(api.calls)-1] != "DeleteRole" || len(api.roles) != 0 {
+		t.Fatalf("the temporary RAM role should be deleted: %v", api.calls)
+	}
+	if len(report.FailedCleanups) != 1 || report.FailedCleanups[0].Type != ResourceTypeRamPolicy {
+		t.Fatalf("the RAM policy should be reported as not cleaned up: %#v", report.FailedCleanups)
+	}
+}
+
+func TestRunInstancesRetryErrors(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	if ContainsInArray(runInstancesRetryErrors(state), "InvalidRamRole.NotEcsRole") {
+		t.Fatal("a RAM role given by ram_role_name should fail right away")
+	}
+
+	state.Put("temporary_ram_role_name", "packer-test")
+	if !ContainsInArray(runInstancesRetryErrors(state), "InvalidRamRole.NotEcsRole") {
+		t.Fatal("the temporary RAM role should be retried until ECS knows it")
+	}
+	if ContainsInArray(createInstanceRetryErrors, "InvalidRamRole.NotEcsRole") {
+		t.Fatal("the retried errors shouldn't be changed")
+	}
+}
@@ -5,8 +5,11 @@ package ecs
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -15,12 +18,18 @@ import (
 )
 
 type stepConfigAlicloudSecurityGroup struct {
-	SecurityGroupId   string
-	SecurityGroupName string
-	Description       string
-	VpcId             string
-	RegionId          string
-	isCreate          bool
+	SecurityGroupId     string
+	SecurityGroupIds    []string
+	SecurityGroupFilter AlicloudResourceFilter
+	SecurityGroupName   string
+	Description         string
+	VpcId               string
+	RegionId            string
+	// IngressProtocol and IngressPortRange restrict the inbound traffic
+	// allowed by a created security group, which allows all by default.
+	IngressProtocol  string
+	IngressPortRange string
+	isCreate         bool
 }
 
 var createSecurityGroupRetryErrors = []string{
@@ -33,6 +42,7 @@ var deleteSecurityGroupRetryErrors = []string{
 
 func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 	networkType := state.Get("networktype").(InstanceNetWork)
 
@@ -40,9 +50,9 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 		describeSecurityGroupsRequest := ecs.CreateDescribeSecurityGroupsRequest()
 		describeSecurityGroupsRequest.RegionId = s.RegionId
 		describeSecurityGroupsRequest.SecurityGroupId = s.SecurityGroupId
-		if networkType == InstanceNetworkVpc {
-			vpcId := state.Get("vpcid").(string)
-			describeSecurityGroupsRequest.VpcId = vpcId
+		// The VPC is unknown when the vswitch of the launch template is used
+		if vpcId, ok := state.GetOk("vpcid"); ok && networkType == InstanceNetworkVpc {
+			describeSecurityGroupsRequest.VpcId = vpcId.(string)
 		}
 
 		securityGroupsResponse, err := client.DescribeSecurityGroups(describeSecurityGroupsRequest)
@@ -54,6 +64,7 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 		for _, securityGroupItem := range securityGroupItems {
 			if securityGroupItem.SecurityGroupId == s.SecurityGroupId {
 				state.Put("securitygroupid", s.SecurityGroupId)
+				state.Put("securitygroupids", []string{s.SecurityGroupId})
 				s.isCreate = false
 				return multistep.ActionContinue
 			}
@@ -64,6 +75,19 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 		return halt(state, err, "")
 	}
 
+	if len(s.SecurityGroupIds) > 0 || !s.SecurityGroupFilter.Empty() {
+		securityGroupIds, err := s.findSecurityGroups(state)
+		if err != nil {
+			return halt(state, err, "")
+		}
+
+		ui.Message(fmt.Sprintf("Using security groups: %s", strings.Join(securityGroupIds, ", ")))
+		state.Put("securitygroupid", securityGroupIds[0])
+		state.Put("securitygroupids", securityGroupIds)
+		s.isCreate = false
+		return multistep.ActionContinue
+	}
+
 	ui.Say("Creating security group...")
 
 	createSecurityGroupRequest := s.buildCreateSecurityGroupRequest(state)
@@ -71,7 +95,8 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 		RequestFunc: func() (responses.AcsResponse, error) {
 			return client.CreateSecurityGroup(createSecurityGroupRequest)
 		},
-		EvalFunc: client.EvalCouldRetryResponse(createSecurityGroupRetryErrors, EvalRetryErrorType),
+		EvalFunc:     client.EvalCouldRetryResponse(createSecurityGroupRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
 	})
 
 	if err != nil {
@@ -82,7 +107,9 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 
 	ui.Message(fmt.Sprintf("Created security group: %s", securityGroupId))
 	state.Put("securitygroupid", securityGroupId)
+	state.Put("securitygroupids", []string{securityGroupId})
 	s.isCreate = true
+	reportResourceCreated(state, ResourceTypeSecurityGroup, securityGroupId)
 	s.SecurityGroupId = securityGroupId
 
 	authorizeSecurityGroupEgressRequest := ecs.CreateAuthorizeSecurityGroupEgressRequest()
@@ -102,6 +129,10 @@ func (s *stepConfigAlicloudSecurityGroup) Run(ctx context.Context, state multist
 	authorizeSecurityGroupRequest.RegionId = s.RegionId
 	authorizeSecurityGroupRequest.IpProtocol = IpProtocolAll
 	authorizeSecurityGroupRequest.PortRange = DefaultPortRange
+	if s.IngressProtocol != "" {
+		authorizeSecurityGroupRequest.IpProtocol = s.IngressProtocol
+		authorizeSecurityGroupRequest.PortRange = s.IngressPortRange
+	}
 	authorizeSecurityGroupRequest.NicType = NicTypeInternet
 	authorizeSecurityGroupRequest.SourceCidrIp = DefaultCidrIp
 
@@ -120,6 +151,7 @@ func (s *stepConfigAlicloudSecurityGroup) Cleanup(state multistep.StateBag) {
 	cleanUpMessage(state, "security group")
 
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	_, err := client.WaitForExpected(&WaitForExpectArgs{
@@ -129,13 +161,86 @@ func (s *stepConfigAlicloudSecurityGroup) Cleanup(state multistep.StateBag) {
 			request.SecurityGroupId = s.SecurityGroupId
 			return client.DeleteSecurityGroup(request)
 		},
-		EvalFunc:   client.EvalCouldRetryResponse(deleteSecurityGroupRetryErrors, EvalRetryErrorType),
-		RetryTimes: shortRetryTimes,
+		EvalFunc:     client.EvalCouldRetryResponse(deleteSecurityGroupRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("security group %s to be deleted", s.SecurityGroupId),
 	})
 
 	if err != nil {
+		reportCleanupFailed(state, ResourceTypeSecurityGroup, s.SecurityGroupId, err)
 		ui.Error(fmt.Sprintf("Failed to delete security group, it may still be around: %s", err))
+		return
+	}
+
+	reportResourceDeleted(state, ResourceTypeSecurityGroup, s.SecurityGroupId)
+}
+
+// findSecurityGroups returns the IDs of the security groups given by
+// security_group_ids, or of the ones matching security_group_filter.
+func (s *stepConfigAlicloudSecurityGroup) findSecurityGroups(state multistep.StateBag) ([]string, error) {
+	client := state.Get("client").(*ClientWrapper)
+	networkType := state.Get("networktype").(InstanceNetWork)
+
+	request := ecs.CreateDescribeSecurityGroupsRequest()
+	request.RegionId = s.RegionId
+	request.PageSize = requests.NewInteger(DescribePageSize)
+	if networkType == InstanceNetworkVpc {
+		request.VpcId = state.Get("vpcid").(string)
+	}
+
+	if len(s.SecurityGroupIds) > 0 {
+		securityGroupIds, _ := json.Marshal(s.SecurityGroupIds)
+		request.SecurityGroupIds = string(securityGroupIds)
+	} else {
+		var tags []ecs.DescribeSecurityGroupsTag
+		for key, value := range s.SecurityGroupFilter.Tags {
+			tags = append(tags, ecs.DescribeSecurityGroupsTag{Key: key, Value: value})
+		}
+		if len(tags) > 0 {
+			request.Tag = &tags
+		}
+		request.SecurityGroupName = s.SecurityGroupFilter.Name
+	}
+
+	var found []string
+	for pageNumber := 1; ; pageNumber++ {
+		request.PageNumber = requests.NewInteger(pageNumber)
+		response, err := client.DescribeSecurityGroups(request)
+		if err != nil {
+			return nil, fmt.Errorf("Failed querying security groups: %s", err)
+		}
+
+		for _, securityGroup := range response.SecurityGroups.SecurityGroup {
+			found = append(found, securityGroup.SecurityGroupId)
+		}
+		if len(response.SecurityGroups.SecurityGroup) == 0 || pageNumber*DescribePageSize >= response.TotalCount {
+			break
+		}
 	}
+
+	if len(s.SecurityGroupIds) > 0 {
+		var missing []string
+		for _, securityGroupId := range s.SecurityGroupIds {
+			if !ContainsInArray(found, securityGroupId) {
+				missing = append(missing, securityGroupId)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("The specified security groups {%s} don't exist.", strings.Join(missing, ", "))
+		}
+
+		return s.SecurityGroupIds, nil
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("No security group matches security_group_filter")
+	}
+	if len(found) > MaxInstanceSecurityGroups {
+		return nil, fmt.Errorf("%d security groups match security_group_filter, but an instance can only be added to %d: %s",
+			len(found), MaxInstanceSecurityGroups, strings.Join(found, ", "))
+	}
+
+	return found, nil
 }
 
 func (s *stepConfigAlicloudSecurityGroup) buildCreateSecurityGroupRequest(state multistep.StateBag) *ecs.CreateSecurityGroupRequest {
@@ -7,9 +7,12 @@ import (
 	"context"
 	errorsNew "errors"
 	"fmt"
+	"strings"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
@@ -19,6 +22,7 @@ type stepConfigAlicloudVPC struct {
 	VpcId     string
 	CidrBlock string //192.168.0.0/16 or 172.16.0.0/16 (default)
 	VpcName   string
+	VpcFilter AlicloudResourceFilter
 	isCreate  bool
 }
 
@@ -61,6 +65,19 @@ func (s *stepConfigAlicloudVPC) Run(ctx context.Context, state multistep.StateBa
 		return halt(state, errorsNew.New(message), "")
 	}
 
+	if !s.VpcFilter.Empty() {
+		ui.Say("Querying vpc by filter...")
+		vpcId, err := s.findVpcByFilter(state)
+		if err != nil {
+			return halt(state, err, "")
+		}
+
+		ui.Message(fmt.Sprintf("Found vpc: %s", vpcId))
+		state.Put("vpcid", vpcId)
+		s.isCreate = false
+		return multistep.ActionContinue
+	}
+
 	ui.Say("Creating vpc...")
 
 	createVpcRequest := s.buildCreateVpcRequest(state)
@@ -68,7 +85,8 @@ func (s *stepConfigAlicloudVPC) Run(ctx context.Context, state multistep.StateBa
 		RequestFunc: func() (responses.AcsResponse, error) {
 			return client.CreateVpc(createVpcRequest)
 		},
-		EvalFunc: client.EvalCouldRetryResponse(createVpcRetryErrors, EvalRetryErrorType),
+		EvalFunc:     client.EvalCouldRetryResponse(createVpcRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
 	})
 	if err != nil {
 		return halt(state, err, "Failed creating vpc")
@@ -99,7 +117,14 @@ func (s *stepConfigAlicloudVPC) Run(ctx context.Context, state multistep.StateBa
 
 			return WaitForExpectToRetry
 		},
-		RetryTimes: shortRetryTimes,
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("vpc %s to be %s", vpcId, VpcStatusAvailable),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, vpc := range response.(*ecs.DescribeVpcsResponse).Vpcs.Vpc {
+				return vpc.Status
+			}
+			return "not found"
+		},
 	})
 
 	if err != nil {
@@ -109,6 +134,7 @@ func (s *stepConfigAlicloudVPC) Run(ctx context.Context, state multistep.StateBa
 	ui.Message(fmt.Sprintf("Created vpc: %s", vpcId))
 	state.Put("vpcid", vpcId)
 	s.isCreate = true
+	reportResourceCreated(state, ResourceTypeVpc, vpcId)
 	s.VpcId = vpcId
 	return multistep.ActionContinue
 }
@@ -121,6 +147,7 @@ func (s *stepConfigAlicloudVPC) Cleanup(state multistep.StateBag) {
 	cleanUpMessage(state, "VPC")
 
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	_, err := client.WaitForExpected(&WaitForExpectArgs{
@@ -129,12 +156,75 @@ func (s *stepConfigAlicloudVPC) Cleanup(state multistep.StateBag) {
 			request.VpcId = s.VpcId
 			return client.DeleteVpc(request)
 		},
-		EvalFunc:   client.EvalCouldRetryResponse(deleteVpcRetryErrors, EvalRetryErrorType),
-		RetryTimes: shortRetryTimes,
+		EvalFunc:     client.EvalCouldRetryResponse(deleteVpcRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("vpc %s to be deleted", s.VpcId),
 	})
 
 	if err != nil {
+		reportCleanupFailed(state, ResourceTypeVpc, s.VpcId, err)
 		ui.Error(fmt.Sprintf("Error deleting vpc, it may still be around: %s", err))
+		return
+	}
+
+	reportResourceDeleted(state, ResourceTypeVpc, s.VpcId)
+}
+
+func (s *stepConfigAlicloudVPC) findVpcByFilter(state multistep.StateBag) (string, error) {
+	config := state.Get("config").(*Config)
+	client := state.Get("client").(*ClientWrapper)
+
+	var tags []vpc.DescribeVpcsTag
+	for key, value := range s.VpcFilter.Tags {
+		tags = append(tags, vpc.DescribeVpcsTag{Key: key, Value: value})
+	}
+
+	request := vpc.CreateDescribeVpcsRequest()
+	request.RegionId = config.AlicloudRegion
+	request.VpcName = s.VpcFilter.Name
+	request.PageSize = requests.NewInteger(DescribePageSize)
+	if len(tags) > 0 {
+		request.Tag = &tags
+	}
+
+	var vpcs []vpc.Vpc
+	for pageNumber := 1; ; pageNumber++ {
+		request.PageNumber = requests.NewInteger(pageNumber)
+		response, err := client.VpcClient.DescribeVpcs(request)
+		if err != nil {
+			return "", fmt.Errorf("Failed querying vpcs: %s", err)
+		}
+
+		vpcs = append(vpcs, response.Vpcs.Vpc...)
+		if len(response.Vpcs.Vpc) == 0 || pageNumber*DescribePageSize >= response.TotalCount {
+			break
+		}
+	}
+
+	return selectVpcByFilter(vpcs, s.VpcFilter)
+}
+
+// selectVpcByFilter returns the ID of the only available VPC which matches the
+// CIDR block of the filter, the other criteria being applied by the API.
+func selectVpcByFilter(vpcs []vpc.Vpc, filter AlicloudResourceFilter) (string, error) {
+	var vpcIds []string
+	for _, v := range vpcs {
+		if v.Status != VpcStatusAvailable {
+			continue
+		}
+		if filter.CidrBlock != "" && v.CidrBlock != filter.CidrBlock {
+			continue
+		}
+		vpcIds = append(vpcIds, v.VpcId)
+	}
+
+	switch len(vpcIds) {
+	case 0:
+		return "", errorsNew.New("No vpc matches vpc_filter")
+	case 1:
+		return vpcIds[0], nil
+	default:
+		return "", fmt.Errorf("Multiple vpcs match vpc_filter, please make it more specific: %s", strings.Join(vpcIds, ", "))
 	}
 }
 
@@ -0,0 +1,31 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+)
+
+func TestSelectVpcByFilter(t *testing.T) {
+	vpcs := []vpc.Vpc{
+		{VpcId: "vpc-1", CidrBlock: "172.16.0.0/16", Status: VpcStatusAvailable},
+		{VpcId: "vpc-2", CidrBlock: "192.168.0.0/16", Status: VpcStatusAvailable},
+		{VpcId: "vpc-3", CidrBlock: "10.0.0.0/8", Status: VpcStatusPending},
+	}
+
+	vpcId, err := selectVpcByFilter(vpcs, AlicloudResourceFilter{CidrBlock: "192.168.0.0/16"})
+	if err != nil || vpcId != "vpc-2" {
+		t.Fatalf("bad: %s %s", vpcId, err)
+	}
+
+	if _, err := selectVpcByFilter(vpcs, AlicloudResourceFilter{Name: "build"}); err == nil {
+		t.Fatal("multiple vpcs should have err")
+	}
+
+	if _, err := selectVpcByFilter(vpcs, AlicloudResourceFilter{CidrBlock: "10.0.0.0/8"}); err == nil {
+		t.Fatal("unavailable vpc should have err")
+	}
+}
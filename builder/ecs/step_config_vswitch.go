@@ -5,27 +5,47 @@ package ecs
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 )
 
 type stepConfigAlicloudVSwitch struct {
-	VSwitchId   string
-	ZoneId      string
-	isCreate    bool
-	CidrBlock   string
-	VSwitchName string
+	VSwitchId     string
+	ZoneId        string
+	isCreate      bool
+	CidrBlock     string
+	VSwitchName   string
+	VSwitchFilter AlicloudResourceFilter
+	// The prefix length of the CIDR block allocated to the vswitch when
+	// vpc_cidr_block isn't set.
+	CidrPrefixLength int
 }
 
 var createVSwitchRetryErrors = []string{
 	"TOKEN_PROCESSING",
 }
 
+// createVSwitchCidrConflictErrors are returned by CreateVSwitch when the CIDR
+// block allocated to the vswitch has been taken in the meantime, e.g. by a
+// concurrent build.
+var createVSwitchCidrConflictErrors = []string{
+	"InvalidCidrBlock.Overlapped",
+}
+
+const maxVSwitchCidrAttempts = 5
+
 var deleteVSwitchRetryErrors = []string{
 	"IncorrectVSwitchStatus",
 	"DependencyViolation",
@@ -62,70 +82,63 @@ func (s *stepConfigAlicloudVSwitch) Run(ctx context.Context, state multistep.Sta
 		return halt(state, fmt.Errorf("The specified vswitch {%s} doesn't exist.", s.VSwitchId), "")
 	}
 
-	if s.ZoneId == "" {
-		describeZonesRequest := ecs.CreateDescribeZonesRequest()
-		describeZonesRequest.RegionId = config.AlicloudRegion
-
-		zonesResponse, err := client.DescribeZones(describeZonesRequest)
+	if !s.VSwitchFilter.Empty() {
+		ui.Say("Querying vswitch by filter...")
+		vswitch, err := s.findVSwitchByFilter(state, vpcId)
 		if err != nil {
-			return halt(state, err, "Query for available zones failed")
+			return halt(state, err, "")
 		}
 
-		var instanceTypes []string
-		zones := zonesResponse.Zones.Zone
-		for _, zone := range zones {
-			isVSwitchSupported := false
-			for _, resourceType := range zone.AvailableResourceCreation.ResourceTypes {
-				if resourceType == "VSwitch" {
-					isVSwitchSupported = true
-				}
-			}
+		ui.Message(fmt.Sprintf("Found vswitch %s in zone %s with %d free IP addresses",
+			vswitch.VSwitchId, vswitch.ZoneId, vswitch.AvailableIpAddressCount))
+		state.Put("vswitchid", vswitch.VSwitchId)
+		s.isCreate = false
+		return multistep.ActionContinue
+	}
 
-			if isVSwitchSupported {
-				for _, instanceType := range zone.AvailableInstanceTypes.InstanceTypes {
-					if instanceType == config.InstanceType {
-						s.ZoneId = zone.ZoneId
-						break
-					}
-					instanceTypes = append(instanceTypes, instanceType)
-				}
-			}
+	if s.ZoneId == "" {
+		zoneId, err := s.selectAvailableZone(state)
+		if err != nil {
+			return halt(state, err, "")
 		}
 
-		if s.ZoneId == "" {
-			if len(instanceTypes) > 0 {
-				ui.Say(fmt.Sprintf("The instance type %s isn't available in this region."+
-					"\n You can either change the instance to one of following: %v \n"+
-					"or choose another region.", config.InstanceType, instanceTypes))
+		ui.Message(fmt.Sprintf("Selected zone: %s", zoneId))
+		s.ZoneId = zoneId
+	}
 
-				state.Put("error", fmt.Errorf("The instance type %s isn't available in this region."+
-					"\n You can either change the instance to one of following: %v \n"+
-					"or choose another region.", config.InstanceType, instanceTypes))
-				return multistep.ActionHalt
-			} else {
-				ui.Say(fmt.Sprintf("The instance type %s isn't available in this region."+
-					"\n You can change to other regions.", config.InstanceType))
+	ui.Say("Creating vswitch...")
 
-				state.Put("error", fmt.Errorf("The instance type %s isn't available in this region."+
-					"\n You can change to other regions.", config.InstanceType))
-				return multistep.ActionHalt
+	var createVSwitchResponse responses.AcsResponse
+	var takenCidrBlocks []string
+	var err error
+	for attempt := 1; ; attempt++ {
+		if config.CidrBlock == "" {
+			cidrBlock, err := s.allocateCidrBlock(state, vpcId, takenCidrBlocks)
+			if err != nil {
+				return halt(state, err, "Error allocating CIDR block of vswitch")
 			}
-		}
-	}
 
-	if config.CidrBlock == "" {
-		s.CidrBlock = DefaultCidrBlock //use the default CirdBlock
-	}
+			ui.Message(fmt.Sprintf("Allocated CIDR block of vswitch: %s", cidrBlock))
+			s.CidrBlock = cidrBlock
+		}
 
-	ui.Say("Creating vswitch...")
+		createVSwitchRequest := s.buildCreateVSwitchRequest(state)
+		createVSwitchResponse, err = client.WaitForExpected(&WaitForExpectArgs{
+			RequestFunc: func() (responses.AcsResponse, error) {
+				return client.CreateVSwitch(createVSwitchRequest)
+			},
+			EvalFunc:     client.EvalCouldRetryResponse(createVSwitchRetryErrors, EvalRetryErrorType),
+			RetryTimeout: config.Timeouts.Network,
+		})
+		if err != nil && config.CidrBlock == "" && attempt < maxVSwitchCidrAttempts &&
+			isErrorCodeIn(err, createVSwitchCidrConflictErrors) {
+			ui.Message(fmt.Sprintf("CIDR block %s has been taken, allocating another one...", s.CidrBlock))
+			takenCidrBlocks = append(takenCidrBlocks, s.CidrBlock)
+			continue
+		}
 
-	createVSwitchRequest := s.buildCreateVSwitchRequest(state)
-	createVSwitchResponse, err := client.WaitForExpected(&WaitForExpectArgs{
-		RequestFunc: func() (responses.AcsResponse, error) {
-			return client.CreateVSwitch(createVSwitchRequest)
-		},
-		EvalFunc: client.EvalCouldRetryResponse(createVSwitchRetryErrors, EvalRetryErrorType),
-	})
+		break
+	}
 	if err != nil {
 		return halt(state, err, "Error Creating vswitch")
 	}
@@ -157,7 +170,14 @@ func (s *stepConfigAlicloudVSwitch) Run(ctx context.Context, state multistep.Sta
 
 			return WaitForExpectToRetry
 		},
-		RetryTimes: shortRetryTimes,
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("vswitch %s to be %s", vSwitchId, VSwitchStatusAvailable),
+		StatusFunc: func(response responses.AcsResponse) string {
+			for _, vSwitch := range response.(*ecs.DescribeVSwitchesResponse).VSwitches.VSwitch {
+				return vSwitch.Status
+			}
+			return "not found"
+		},
 	})
 
 	if err != nil {
@@ -167,6 +187,7 @@ func (s *stepConfigAlicloudVSwitch) Run(ctx context.Context, state multistep.Sta
 	ui.Message(fmt.Sprintf("Created vswitch: %s", vSwitchId))
 	state.Put("vswitchid", vSwitchId)
 	s.isCreate = true
+	reportResourceCreated(state, ResourceTypeVSwitch, vSwitchId)
 	s.VSwitchId = vSwitchId
 	return multistep.ActionContinue
 }
@@ -179,6 +200,7 @@ func (s *stepConfigAlicloudVSwitch) Cleanup(state multistep.StateBag) {
 	cleanUpMessage(state, "vSwitch")
 
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	_, err := client.WaitForExpected(&WaitForExpectArgs{
@@ -187,13 +209,344 @@ func (s *stepConfigAlicloudVSwitch) Cleanup(state multistep.StateBag) {
 			request.VSwitchId = s.VSwitchId
 			return client.DeleteVSwitch(request)
 		},
-		EvalFunc:   client.EvalCouldRetryResponse(deleteVSwitchRetryErrors, EvalRetryErrorType),
-		RetryTimes: shortRetryTimes,
+		EvalFunc:     client.EvalCouldRetryResponse(deleteVSwitchRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.Network,
+		WaitingFor:   fmt.Sprintf("vswitch %s to be deleted", s.VSwitchId),
 	})
 
 	if err != nil {
+		reportCleanupFailed(state, ResourceTypeVSwitch, s.VSwitchId, err)
 		ui.Error(fmt.Sprintf("Error deleting vswitch, it may still be around: %s", err))
+		return
+	}
+
+	reportResourceDeleted(state, ResourceTypeVSwitch, s.VSwitchId)
+}
+
+// selectAvailableZone picks the first zone of the region where a vswitch can be
+// created and the instance type, IO optimization and all disk categories are
+// available and in stock.
+func (s *stepConfigAlicloudVSwitch) selectAvailableZone(state multistep.StateBag) (string, error) {
+	zoneIds, err := s.availableZones(state)
+	if err != nil {
+		return "", err
+	}
+
+	return zoneIds[0], nil
+}
+
+// availableZones returns the zones of the region where a vswitch can be
+// created and the instance type, IO optimization and all disk categories are
+// available and in stock. At least one zone is returned when there is no error.
+func (s *stepConfigAlicloudVSwitch) availableZones(state multistep.StateBag) ([]string, error) {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+
+	describeZonesRequest := ecs.CreateDescribeZonesRequest()
+	describeZonesRequest.RegionId = config.AlicloudRegion
+
+	zonesResponse, err := client.DescribeZones(describeZonesRequest)
+	if err != nil {
+		return nil, fmt.Errorf("Query for available zones failed: %s", err)
+	}
+
+	var zoneIds []string
+	reasons := make(map[string][]string)
+	for _, zone := range zonesResponse.Zones.Zone {
+		if ContainsInArray(zone.AvailableResourceCreation.ResourceTypes, "VSwitch") {
+			zoneIds = append(zoneIds, zone.ZoneId)
+		}
+	}
+
+	var systemDiskCategories []string
+	if category := config.ECSSystemDiskMapping.DiskCategory; category != "" {
+		systemDiskCategories = append(systemDiskCategories, category)
+	}
+
+	var dataDiskCategories []string
+	for _, imageDisk := range config.ECSImagesDiskMappings {
+		if imageDisk.DiskCategory != "" && !ContainsInArray(dataDiskCategories, imageDisk.DiskCategory) {
+			dataDiskCategories = append(dataDiskCategories, imageDisk.DiskCategory)
+		}
+	}
+
+	destinations := map[string][]string{
+		DestinationResourceSystemDisk: systemDiskCategories,
+	}
+	if len(dataDiskCategories) > 0 {
+		destinations[DestinationResourceDataDisk] = dataDiskCategories
+	}
+
+	for destination, categories := range destinations {
+		request := ecs.CreateDescribeAvailableResourceRequest()
+		request.RegionId = config.AlicloudRegion
+		request.DestinationResource = destination
+		request.InstanceType = config.InstanceType
+		request.ResourceType = TagResourceInstance
+		request.NetworkCategory = InstanceNetworkVpc
+		if config.SpotStrategy != "" {
+			request.InstanceChargeType = InstanceChargeTypePostPaid
+			request.SpotStrategy = config.SpotStrategy
+		}
+		if config.IOOptimized.True() {
+			request.IoOptimized = IOOptimizedOptimized
+		} else if config.IOOptimized.False() {
+			request.IoOptimized = IOOptimizedNone
+		}
+
+		response, err := client.DescribeAvailableResource(request)
+		if err != nil {
+			return nil, fmt.Errorf("Query for available resources failed: %s", err)
+		}
+
+		available, unavailable := availableZonesForResource(response.AvailableZones.AvailableZone, destination, categories)
+		var remaining []string
+		for _, zoneId := range zoneIds {
+			if ContainsInArray(available, zoneId) {
+				remaining = append(remaining, zoneId)
+				continue
+			}
+
+			reason, ok := unavailable[zoneId]
+			if !ok {
+				reason = fmt.Sprintf("instance type %s is not offered", config.InstanceType)
+			}
+			reasons[zoneId] = append(reasons[zoneId], reason)
+		}
+		zoneIds = remaining
+	}
+
+	if len(zoneIds) == 0 {
+		var details []string
+		for zoneId, zoneReasons := range reasons {
+			details = append(details, fmt.Sprintf("%s: %s", zoneId, strings.Join(zoneReasons, ", ")))
+		}
+		sort.Strings(details)
+
+		return nil, fmt.Errorf("The instance type %s with system disk category %q and data disk categories %v "+
+			"isn't available in any zone of region %s.\n%s\nYou can either change the instance type or disk categories, "+
+			"or choose another region.", config.InstanceType, config.ECSSystemDiskMapping.DiskCategory, dataDiskCategories,
+			config.AlicloudRegion, strings.Join(details, "\n"))
+	}
+
+	return zoneIds, nil
+}
+
+func (s *stepConfigAlicloudVSwitch) findVSwitchByFilter(state multistep.StateBag, vpcId string) (*vpc.VSwitch, error) {
+	config := state.Get("config").(*Config)
+	client := state.Get("client").(*ClientWrapper)
+
+	var tags []vpc.DescribeVSwitchesTag
+	for key, value := range s.VSwitchFilter.Tags {
+		tags = append(tags, vpc.DescribeVSwitchesTag{Key: key, Value: value})
+	}
+
+	request := vpc.CreateDescribeVSwitchesRequest()
+	request.RegionId = config.AlicloudRegion
+	request.VpcId = vpcId
+	request.ZoneId = s.ZoneId
+	request.VSwitchName = s.VSwitchFilter.Name
+	if len(tags) > 0 {
+		request.Tag = &tags
+	}
+
+	vswitches, err := describeVSwitches(client, request)
+	if err != nil {
+		return nil, err
 	}
+
+	zoneIds := []string{s.ZoneId}
+	if s.ZoneId == "" {
+		zoneIds, err = s.availableZones(state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return selectVSwitchByFilter(vswitches, s.VSwitchFilter, zoneIds)
+}
+
+func describeVSwitches(client *ClientWrapper, request *vpc.DescribeVSwitchesRequest) ([]vpc.VSwitch, error) {
+	request.PageSize = requests.NewInteger(DescribePageSize)
+
+	var vswitches []vpc.VSwitch
+	for pageNumber := 1; ; pageNumber++ {
+		request.PageNumber = requests.NewInteger(pageNumber)
+		response, err := client.VpcClient.DescribeVSwitches(request)
+		if err != nil {
+			return nil, fmt.Errorf("Failed querying vswitches: %s", err)
+		}
+
+		vswitches = append(vswitches, response.VSwitches.VSwitch...)
+		if len(response.VSwitches.VSwitch) == 0 || pageNumber*DescribePageSize >= response.TotalCount {
+			break
+		}
+	}
+
+	return vswitches, nil
+}
+
+// allocateCidrBlock returns a CIDR block of the VPC for the vswitch, which
+// doesn't overlap any existing vswitch of the VPC nor the taken CIDR blocks.
+func (s *stepConfigAlicloudVSwitch) allocateCidrBlock(state multistep.StateBag, vpcId string, takenCidrBlocks []string) (string, error) {
+	config := state.Get("config").(*Config)
+	client := state.Get("client").(*ClientWrapper)
+
+	describeVpcsRequest := vpc.CreateDescribeVpcsRequest()
+	describeVpcsRequest.RegionId = config.AlicloudRegion
+	describeVpcsRequest.VpcId = vpcId
+	vpcsResponse, err := client.VpcClient.DescribeVpcs(describeVpcsRequest)
+	if err != nil {
+		return "", fmt.Errorf("Failed querying vpc: %s", err)
+	}
+	if len(vpcsResponse.Vpcs.Vpc) == 0 {
+		return "", fmt.Errorf("The vpc {%s} doesn't exist.", vpcId)
+	}
+	vpcAttribute := vpcsResponse.Vpcs.Vpc[0]
+	vpcCidrBlocks := append([]string{vpcAttribute.CidrBlock}, vpcAttribute.SecondaryCidrBlocks.SecondaryCidrBlock...)
+
+	describeVSwitchesRequest := vpc.CreateDescribeVSwitchesRequest()
+	describeVSwitchesRequest.RegionId = config.AlicloudRegion
+	describeVSwitchesRequest.VpcId = vpcId
+	vswitches, err := describeVSwitches(client, describeVSwitchesRequest)
+	if err != nil {
+		return "", err
+	}
+
+	usedCidrBlocks := append([]string{}, takenCidrBlocks...)
+	for _, vswitch := range vswitches {
+		usedCidrBlocks = append(usedCidrBlocks, vswitch.CidrBlock)
+	}
+
+	for _, vpcCidrBlock := range vpcCidrBlocks {
+		if vpcCidrBlock == "" {
+			continue
+		}
+
+		cidrBlock, err := freeCidrBlock(vpcCidrBlock, usedCidrBlocks, s.CidrPrefixLength)
+		if err == nil {
+			return cidrBlock, nil
+		}
+		log.Printf("[DEBUG] No free CIDR block in %s: %s", vpcCidrBlock, err)
+	}
+
+	return "", fmt.Errorf("No free /%d CIDR block is left in vpc %s (%s)",
+		s.CidrPrefixLength, vpcId, strings.Join(vpcCidrBlocks, ", "))
+}
+
+// freeCidrBlock returns the first IPv4 CIDR block with the prefix length in
+// the parent CIDR block, which doesn't overlap any of the used CIDR blocks.
+func freeCidrBlock(parentCidrBlock string, usedCidrBlocks []string, prefixLength int) (string, error) {
+	_, parent, err := net.ParseCIDR(parentCidrBlock)
+	if err != nil || parent.IP.To4() == nil {
+		return "", fmt.Errorf("invalid IPv4 CIDR block %q", parentCidrBlock)
+	}
+
+	parentPrefixLength, _ := parent.Mask.Size()
+	if prefixLength < parentPrefixLength || prefixLength > 32 {
+		return "", fmt.Errorf("prefix length %d doesn't fit in %s", prefixLength, parentCidrBlock)
+	}
+
+	var used []*net.IPNet
+	for _, usedCidrBlock := range usedCidrBlocks {
+		if _, usedNet, err := net.ParseCIDR(usedCidrBlock); err == nil {
+			used = append(used, usedNet)
+		}
+	}
+
+	start := binary.BigEndian.Uint32(parent.IP.To4())
+	size := uint64(1) << uint(32-prefixLength)
+	count := uint64(1) << uint(prefixLength-parentPrefixLength)
+	for i := uint64(0); i < count; i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, start+uint32(i*size))
+		candidate := &net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLength, 32)}
+
+		overlapped := false
+		for _, usedNet := range used {
+			if usedNet.Contains(candidate.IP) || candidate.Contains(usedNet.IP) {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped {
+			return candidate.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("all /%d CIDR blocks of %s are used", prefixLength, parentCidrBlock)
+}
+
+// selectVSwitchByFilter returns the available vswitch with the most free IP
+// addresses among the ones which are in one of the zones and match the CIDR
+// block of the filter, the other criteria being applied by the API.
+func selectVSwitchByFilter(vswitches []vpc.VSwitch, filter AlicloudResourceFilter, zoneIds []string) (*vpc.VSwitch, error) {
+	var selected *vpc.VSwitch
+	for i, vswitch := range vswitches {
+		if vswitch.Status != VSwitchStatusAvailable || !ContainsInArray(zoneIds, vswitch.ZoneId) {
+			continue
+		}
+		if filter.CidrBlock != "" && vswitch.CidrBlock != filter.CidrBlock {
+			continue
+		}
+		if vswitch.AvailableIpAddressCount <= 0 {
+			continue
+		}
+		if selected == nil || vswitch.AvailableIpAddressCount > selected.AvailableIpAddressCount {
+			selected = &vswitches[i]
+		}
+	}
+
+	if selected == nil {
+		return nil, fmt.Errorf("No vswitch with free IP addresses in zones %s matches vswitch_filter", strings.Join(zoneIds, ", "))
+	}
+
+	return selected, nil
+}
+
+// availableZonesForResource returns the zones where the resource is in stock
+// and supports every requested category, along with the reason why each
+// other zone was rejected.
+func availableZonesForResource(zones []ecs.AvailableZone, resourceType string, categories []string) ([]string, map[string]string) {
+	var available []string
+	unavailable := make(map[string]string)
+
+	for _, zone := range zones {
+		if zone.Status != ResourceStatusAvailable || zone.StatusCategory == ResourceStatusCategoryWithoutStock {
+			unavailable[zone.ZoneId] = "instance type is out of stock"
+			continue
+		}
+
+		var supported []string
+		for _, resource := range zone.AvailableResources.AvailableResource {
+			if resource.Type != resourceType {
+				continue
+			}
+
+			for _, supportedResource := range resource.SupportedResources.SupportedResource {
+				if supportedResource.Status == ResourceStatusAvailable &&
+					supportedResource.StatusCategory != ResourceStatusCategoryWithoutStock {
+					supported = append(supported, supportedResource.Value)
+				}
+			}
+		}
+
+		var missing []string
+		for _, category := range categories {
+			if !ContainsInArray(supported, category) {
+				missing = append(missing, category)
+			}
+		}
+
+		if len(missing) > 0 {
+			unavailable[zone.ZoneId] = fmt.Sprintf("%s category %s is not available", resourceType, strings.Join(missing, ", "))
+			continue
+		}
+
+		available = append(available, zone.ZoneId)
+	}
+
+	return available, unavailable
 }
 
 func (s *stepConfigAlicloudVSwitch) buildCreateVSwitchRequest(state multistep.StateBag) *ecs.CreateVSwitchRequest {
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/vpc"
+)
+
+func testAvailableZone(zoneId string, status string, categories ...string) ecs.AvailableZone {
+	var supportedResources []ecs.SupportedResource
+	for _, category := range categories {
+		supportedResources = append(supportedResources, ecs.SupportedResource{
+			Value:          category,
+			Status:         ResourceStatusAvailable,
+			StatusCategory: "WithStock",
+		})
+	}
+
+	zone := ecs.AvailableZone{
+		ZoneId:         zoneId,
+		Status:         status,
+		StatusCategory: "WithStock",
+	}
+	zone.AvailableResources.AvailableResource = []ecs.AvailableResource{
+		{
+			Type:               DestinationResourceSystemDisk,
+			SupportedResources: ecs.SupportedResourcesInDescribeAvailableResource{SupportedResource: supportedResources},
+		},
+	}
+
+	return zone
+}
+
+func TestAvailableZonesForResource(t *testing.T) {
+	zones := []ecs.AvailableZone{
+		testAvailableZone("cn-beijing-a", ResourceStatusAvailable, "cloud_efficiency"),
+		testAvailableZone("cn-beijing-b", ResourceStatusAvailable, "cloud_efficiency", "cloud_essd"),
+		testAvailableZone("cn-beijing-c", "SoldOut", "cloud_essd"),
+	}
+
+	available, unavailable := availableZonesForResource(zones, DestinationResourceSystemDisk, []string{"cloud_essd"})
+	if !reflect.DeepEqual(available, []string{"cn-beijing-b"}) {
+		t.Fatalf("invalid value, expected: %v, actual: %v", []string{"cn-beijing-b"}, available)
+	}
+	if _, ok := unavailable["cn-beijing-a"]; !ok {
+		t.Fatalf("cn-beijing-a should be unavailable: %v", unavailable)
+	}
+	if _, ok := unavailable["cn-beijing-c"]; !ok {
+		t.Fatalf("cn-beijing-c should be unavailable: %v", unavailable)
+	}
+
+	available, _ = availableZonesForResource(zones, DestinationResourceSystemDisk, nil)
+	if !reflect.DeepEqual(available, []string{"cn-beijing-a", "cn-beijing-b"}) {
+		t.Fatalf("invalid value, expected: %v, actual: %v", []string{"cn-beijing-a", "cn-beijing-b"}, available)
+	}
+}
+
+func TestSelectVSwitchByFilter(t *testing.T) {
+	vswitches := []vpc.VSwitch{
+		{VSwitchId: "vsw-1", ZoneId: "cn-beijing-a", CidrBlock: "172.16.0.0/24", Status: VSwitchStatusAvailable, AvailableIpAddressCount: 10},
+		{VSwitchId: "vsw-2", ZoneId: "cn-beijing-b", CidrBlock: "172.16.1.0/24", Status: VSwitchStatusAvailable, AvailableIpAddressCount: 200},
+		{VSwitchId: "vsw-3", ZoneId: "cn-beijing-c", CidrBlock: "172.16.2.0/24", Status: VSwitchStatusAvailable, AvailableIpAddressCount: 250},
+		{VSwitchId: "vsw-4", ZoneId: "cn-beijing-a", CidrBlock: "172.16.3.0/24", Status: VSwitchStatusPending, AvailableIpAddressCount: 250},
+	}
+
+	vswitch, err := selectVSwitchByFilter(vswitches, AlicloudResourceFilter{}, []string{"cn-beijing-a", "cn-beijing-b"})
+	if err != nil || vswitch.VSwitchId != "vsw-2" {
+		t.Fatalf("bad: %v %s", vswitch, err)
+	}
+
+	vswitch, err = selectVSwitchByFilter(vswitches, AlicloudResourceFilter{CidrBlock: "172.16.0.0/24"}, []string{"cn-beijing-a", "cn-beijing-b"})
+	if err != nil || vswitch.VSwitchId != "vsw-1" {
+		t.Fatalf("bad: %v %s", vswitch, err)
+	}
+
+	if _, err := selectVSwitchByFilter(vswitches, AlicloudResourceFilter{}, []string{"cn-beijing-d"}); err == nil {
+		t.Fatal("should have err")
+	}
+}
+
+func TestFreeCidrBlock(t *testing.T) {
+	cidrBlock, err := freeCidrBlock("172.16.0.0/12", nil, 24)
+	if err != nil || cidrBlock != "172.16.0.0/24" {
+		t.Fatalf("bad: %s %s", cidrBlock, err)
+	}
+
+	cidrBlock, err = freeCidrBlock("172.16.0.0/12", []string{"172.16.0.0/24", "172.16.1.128/25"}, 24)
+	if err != nil || cidrBlock != "172.16.2.0/24" {
+		t.Fatalf("bad: %s %s", cidrBlock, err)
+	}
+
+	cidrBlock, err = freeCidrBlock("192.168.0.0/16", []string{"192.168.0.0/17"}, 20)
+	if err != nil || cidrBlock != "192.168.128.0/20" {
+		t.Fatalf("bad: %s %s", cidrBlock, err)
+	}
+
+	if _, err := freeCidrBlock("192.168.0.0/24", []string{"192.168.0.0/24"}, 26); err == nil {
+		t.Fatal("full vpc should have err")
+	}
+
+	if _, err := freeCidrBlock("192.168.0.0/24", nil, 16); err == nil {
+		t.Fatal("prefix larger than vpc should have err")
+	}
+}
@@ -19,7 +19,7 @@ import (
 
 type stepCreateAlicloudImage struct {
 	AlicloudImageIgnoreDataDisks bool
-	WaitSnapshotReadyTimeout     int
+	WaitSnapshotReadyTimeout     time.Duration
 	Tags                         map[string]string
 	image                        *ecs.Image
 }
@@ -34,7 +34,8 @@ func (s *stepCreateAlicloudImage) Run(ctx context.Context, state multistep.State
 	ui := state.Get("ui").(packersdk.Ui)
 
 	tempImageName := config.AlicloudImageName
-	if config.ImageEncrypted.True() {
+	encryptedByCopy := config.ImageEncrypted.True() && config.EncryptImageByCopy()
+	if encryptedByCopy {
 		tempImageName = fmt.Sprintf("packer_%s", random.AlphaNum(7))
 		ui.Say(fmt.Sprintf("Creating temporary image for encryption: %s", tempImageName))
 	} else {
@@ -46,7 +47,8 @@ func (s *stepCreateAlicloudImage) Run(ctx context.Context, state multistep.State
 		RequestFunc: func() (responses.AcsResponse, error) {
 			return client.CreateImage(createImageRequest)
 		},
-		EvalFunc: client.EvalCouldRetryResponse(createImageRetryErrors, EvalRetryErrorType),
+		EvalFunc:     client.EvalCouldRetryResponse(createImageRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.ImageCreate,
 	})
 
 	if err != nil {
@@ -54,8 +56,11 @@ func (s *stepCreateAlicloudImage) Run(ctx context.Context, state multistep.State
 	}
 
 	imageId := createImageResponse.(*ecs.CreateImageResponse).ImageId
+	if encryptedByCopy {
+		reportResourceCreated(state, ResourceTypeImage, imageId)
+	}
 
-	imagesResponse, err := client.WaitForImageStatus(config.AlicloudRegion, imageId, ImageStatusAvailable, time.Duration(s.WaitSnapshotReadyTimeout)*time.Second)
+	imagesResponse, err := client.WaitForImageStatus(config.AlicloudRegion, imageId, ImageStatusAvailable, s.WaitSnapshotReadyTimeout)
 
 	// save image first for cleaning up if timeout
 	images := imagesResponse.(*ecs.DescribeImagesResponse).Images.Image
@@ -89,7 +94,7 @@ func (s *stepCreateAlicloudImage) Cleanup(state multistep.StateBag) {
 	}
 
 	config := state.Get("config").(*Config)
-	encryptedSet := config.ImageEncrypted.True()
+	encryptedSet := config.ImageEncrypted.True() && config.EncryptImageByCopy()
 
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
@@ -111,15 +116,18 @@ func (s *stepCreateAlicloudImage) Cleanup(state multistep.StateBag) {
 	deleteImageRequest.RegionId = config.AlicloudRegion
 	deleteImageRequest.ImageId = s.image.ImageId
 	if _, err := client.DeleteImage(deleteImageRequest); err != nil {
+		reportCleanupFailed(state, ResourceTypeImage, s.image.ImageId, err)
 		ui.Error(fmt.Sprintf("Error deleting image, it may still be around: %s", err))
 		return
 	}
+	reportResourceDeleted(state, ResourceTypeImage, s.image.ImageId)
 
 	//Delete the snapshot of this image
 	for _, diskDevices := range s.image.DiskDeviceMappings.DiskDeviceMapping {
 		deleteSnapshotRequest := ecs.CreateDeleteSnapshotRequest()
 		deleteSnapshotRequest.SnapshotId = diskDevices.SnapshotId
 		if _, err := client.DeleteSnapshot(deleteSnapshotRequest); err != nil {
+			reportCleanupFailed(state, ResourceTypeSnapshot, diskDevices.SnapshotId, err)
 			ui.Error(fmt.Sprintf("Error deleting snapshot, it may still be around: %s", err))
 			return
 		}
@@ -138,6 +146,10 @@ func (s *stepCreateAlicloudImage) buildCreateImageRequest(state multistep.StateB
 	request.ResourceGroupId = config.AlicloudResourceGroupId
 	request.ImageFamily = config.AlicloudTargetImageFamily
 	request.BootMode = config.AlicloudBootMode
+	// The request of the SDK has no field for the ImdsSupport feature
+	if config.AlicloudImageImdsSupport != "" {
+		request.QueryParams["Features.ImdsSupport"] = config.AlicloudImageImdsSupport
+	}
 
 	if s.AlicloudImageIgnoreDataDisks {
 		snapshotId := state.Get("alicloudsnapshot").(string)
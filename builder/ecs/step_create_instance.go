@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 
@@ -22,10 +23,10 @@ import (
 
 type stepCreateAlicloudInstance struct {
 	IOOptimized                 confighelper.Trilean
-	InstanceType                string
 	UserData                    string
 	UserDataFile                string
-	RamRoleName                 string
+	WinRMBootstrapUserData      string
+	MetadataOptions             MetadataOptions
 	Tags                        map[string]string
 	RegionId                    string
 	InternetChargeType          string
@@ -34,6 +35,8 @@ type stepCreateAlicloudInstance struct {
 	ZoneId                      string
 	SecurityEnhancementStrategy string
 	AlicloudImageFamily         string
+	SpotStrategy                string
+	SpotPriceLimit              float64
 	instance                    *ecs.Instance
 }
 
@@ -41,12 +44,27 @@ var createInstanceRetryErrors = []string{
 	"IdempotentProcessing",
 }
 
+// temporaryRamRoleRetryErrors are returned by RunInstances while the temporary
+// RAM role isn't known to ECS yet. A RAM role given by ram_role_name fails
+// with them right away.
+var temporaryRamRoleRetryErrors = []string{
+	"InvalidRamRole.NotEcsRole",
+}
+
+// runInstancesKeyPairErrors are returned by RunInstances when the key pair
+// can't be bound at launch, in which case it is attached after the instance
+// has been stopped instead.
+var runInstancesKeyPairErrors = []string{
+	"DependencyViolation.WindowsInstance",
+}
+
 var deleteInstanceRetryErrors = []string{
 	"IncorrectInstanceStatus.Initializing",
 }
 
 func (s *stepCreateAlicloudInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	ui.Say("Creating instance...")
@@ -55,20 +73,23 @@ func (s *stepCreateAlicloudInstance) Run(ctx context.Context, state multistep.St
 		return halt(state, err, "")
 	}
 
-	runInstancesResponse, err := client.WaitForExpected(&WaitForExpectArgs{
-		RequestFunc: func() (responses.AcsResponse, error) {
-			return client.RunInstances(runInstanceRequest)
-		},
-		EvalFunc: client.EvalCouldRetryResponse(createInstanceRetryErrors, EvalRetryErrorType),
-	})
+	retryErrors := runInstancesRetryErrors(state)
+	runInstancesResponse, err := s.runInstances(client, runInstanceRequest, retryErrors, config.Timeouts.InstanceStart)
+	if err != nil && runInstanceRequest.KeyPairName != "" && isErrorCodeIn(err, runInstancesKeyPairErrors) {
+		ui.Say(fmt.Sprintf("The keypair can't be bound at launch, it will be attached after creation: %s", err))
+		runInstanceRequest.KeyPairName = ""
+		runInstanceRequest.ClientToken = uuid.TimeOrderedUUID()
+		runInstancesResponse, err = s.runInstances(client, runInstanceRequest, retryErrors, config.Timeouts.InstanceStart)
+	}
 
 	if err != nil {
 		return halt(state, err, "Error creating instance")
 	}
 
 	instanceId := runInstancesResponse.(*ecs.RunInstancesResponse).InstanceIdSets.InstanceIdSet[0]
+	reportResourceCreated(state, ResourceTypeInstance, instanceId)
 
-	_, err = client.WaitForInstanceStatus(s.RegionId, instanceId, InstanceStatusRunning)
+	_, err = client.WaitForInstanceStatus(s.RegionId, instanceId, InstanceStatusRunning, config.Timeouts.InstanceStart)
 	if err != nil {
 		return halt(state, err, "Error waiting create instance")
 	}
@@ -79,8 +100,11 @@ func (s *stepCreateAlicloudInstance) Run(ctx context.Context, state multistep.St
 	if err != nil {
 		return halt(state, err, "")
 	}
-	status := instances.Instances.Instance[0].Status
-	if status == InstanceStatusRunning {
+	instance := &instances.Instances.Instance[0]
+
+	// The instance is only stopped when the keypair has to be attached after
+	// creation, so that it boots exactly once otherwise.
+	if config.Comm.SSHKeyPairName != "" && runInstanceRequest.KeyPairName == "" && instance.Status == InstanceStatusRunning {
 		stopInstanceRequest := ecs.CreateStopInstanceRequest()
 		stopInstanceRequest.InstanceId = instanceId
 		if _, err := client.StopInstance(stopInstanceRequest); err != nil {
@@ -89,14 +113,15 @@ func (s *stepCreateAlicloudInstance) Run(ctx context.Context, state multistep.St
 
 		ui.Say(fmt.Sprintf("Stoping instance: %s", instanceId))
 
-		_, err = client.WaitForInstanceStatus(s.RegionId, instanceId, InstanceStatusStopped)
+		_, err = client.WaitForInstanceStatus(s.RegionId, instanceId, InstanceStatusStopped, config.Timeouts.InstanceStop)
 		if err != nil {
 			return halt(state, err, "Timeout waiting for instance to stop")
 		}
+		instance.Status = InstanceStatusStopped
 	}
 
 	ui.Message(fmt.Sprintf("Created instance: %s", instanceId))
-	s.instance = &instances.Instances.Instance[0]
+	s.instance = instance
 	state.Put("instance", s.instance)
 	// instance_id is the generic term used so that users can have access to the
 	// instance id inside of the provisioners, used in step_provision.
@@ -112,6 +137,7 @@ func (s *stepCreateAlicloudInstance) Cleanup(state multistep.StateBag) {
 	cleanUpMessage(state, "instance")
 
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	_, err := client.WaitForExpected(&WaitForExpectArgs{
@@ -121,38 +147,85 @@ func (s *stepCreateAlicloudInstance) Cleanup(state multistep.StateBag) {
 			request.Force = requests.NewBoolean(true)
 			return client.DeleteInstance(request)
 		},
-		EvalFunc:   client.EvalCouldRetryResponse(deleteInstanceRetryErrors, EvalRetryErrorType),
-		RetryTimes: shortRetryTimes,
+		EvalFunc:     client.EvalCouldRetryResponse(deleteInstanceRetryErrors, EvalRetryErrorType),
+		RetryTimeout: config.Timeouts.InstanceStop,
 	})
 
 	if err != nil {
+		reportCleanupFailed(state, ResourceTypeInstance, s.instance.InstanceId, err)
 		ui.Say(fmt.Sprintf("Failed to clean up instance %s: %s", s.instance.InstanceId, err))
+		return
 	}
+
+	reportResourceDeleted(state, ResourceTypeInstance, s.instance.InstanceId)
+}
+
+func (s *stepCreateAlicloudInstance) runInstances(client *ClientWrapper, request *ecs.RunInstancesRequest, retryErrors []string,
+	timeout time.Duration) (responses.AcsResponse, error) {
+	return client.WaitForExpected(&WaitForExpectArgs{
+		RequestFunc: func() (responses.AcsResponse, error) {
+			return client.RunInstances(request)
+		},
+		EvalFunc:     client.EvalCouldRetryResponse(retryErrors, EvalRetryErrorType),
+		RetryTimeout: timeout,
+	})
+}
+
+// runInstancesRetryErrors returns the errors of RunInstances which are
+// retried, including the ones of a temporary RAM role which is still
+// propagating.
+func runInstancesRetryErrors(state multistep.StateBag) []string {
+	if _, ok := state.GetOk("temporary_ram_role_name"); !ok {
+		return createInstanceRetryErrors
+	}
+
+	retryErrors := append([]string{}, createInstanceRetryErrors...)
+	return append(retryErrors, temporaryRamRoleRetryErrors...)
 }
 
 func (s *stepCreateAlicloudInstance) buildCreateInstanceRequest(state multistep.StateBag) (*ecs.RunInstancesRequest, error) {
 	request := ecs.CreateRunInstancesRequest()
 	request.ClientToken = uuid.TimeOrderedUUID()
 	request.RegionId = s.RegionId
-	request.InstanceType = s.InstanceType
+	request.InstanceType = state.Get("config").(*Config).InstanceType
 	request.InstanceName = s.InstanceName
-	request.RamRoleName = s.RamRoleName
+	request.RamRoleName = state.Get("config").(*Config).RamRoleName
 	request.Tag = buildCreateInstanceTags(s.Tags)
 	request.ZoneId = s.ZoneId
 	request.SecurityEnhancementStrategy = s.SecurityEnhancementStrategy
+	request.SpotStrategy = s.SpotStrategy
+	setRunInstancesLaunchTemplate(request, &state.Get("config").(*Config).RunConfig)
+	setRunInstancesPlacement(request, &state.Get("config").(*Config).RunConfig)
+	request.HttpEndpoint = s.MetadataOptions.HttpEndpoint
+	request.HttpTokens = s.MetadataOptions.HttpTokens
+	request.HttpPutResponseHopLimit = requests.Integer(convertNumber(s.MetadataOptions.HttpPutResponseHopLimit))
+	if s.SpotPriceLimit > 0 {
+		request.SpotPriceLimit = requests.NewFloat(s.SpotPriceLimit)
+	}
 	if s.AlicloudImageFamily != "" {
 		request.ImageFamily = s.AlicloudImageFamily
 	} else {
 		sourceImage := state.Get("source_image").(*ecs.Image)
 		request.ImageId = sourceImage.ImageId
 	}
-	securityGroupId := state.Get("securitygroupid").(string)
-	request.SecurityGroupId = securityGroupId
+	// Without a security group or a vswitch in the state, the ones of the
+	// launch template are used
+	if rawSecurityGroupIds, ok := state.GetOk("securitygroupids"); ok {
+		securityGroupIds := rawSecurityGroupIds.([]string)
+		if len(securityGroupIds) > 1 {
+			request.SecurityGroupIds = &securityGroupIds
+		} else {
+			request.SecurityGroupId = securityGroupIds[0]
+		}
+	}
 
+	config := state.Get("config").(*Config)
 	networkType := state.Get("networktype").(InstanceNetWork)
 	if networkType == InstanceNetworkVpc {
-		vswitchId := state.Get("vswitchid").(string)
-		request.VSwitchId = vswitchId
+		if vswitchId, ok := state.GetOk("vswitchid"); ok {
+			request.VSwitchId = vswitchId.(string)
+		}
+		request.KeyPairName = config.Comm.SSHKeyPairName
 
 		userData, err := s.getUserData(state)
 		if err != nil {
@@ -178,7 +251,6 @@ func (s *stepCreateAlicloudInstance) buildCreateInstanceRequest(state multistep.
 		request.IoOptimized = IOOptimizedNone
 	}
 
-	config := state.Get("config").(*Config)
 	password := config.Comm.SSHPassword
 	if password == "" && config.Comm.WinRMPassword != "" {
 		password = config.Comm.WinRMPassword
@@ -188,14 +260,11 @@ func (s *stepCreateAlicloudInstance) buildCreateInstanceRequest(state multistep.
 	systemDisk := config.AlicloudImageConfig.ECSSystemDiskMapping
 	request.SystemDiskDiskName = systemDisk.DiskName
 	request.SystemDiskCategory = systemDisk.DiskCategory
-	request.SystemDiskSize = strconv.Itoa(systemDisk.DiskSize)
+	request.SystemDiskSize = convertNumber(systemDisk.DiskSize)
 	request.SystemDiskDescription = systemDisk.Description
-
-	var runInstancesSystemDisk ecs.RunInstancesSystemDisk
-	if systemDisk.Encrypted != confighelper.TriUnset {
-		runInstancesSystemDisk.Encrypted = strconv.FormatBool(systemDisk.Encrypted.True())
-	}
-	request.SystemDisk = runInstancesSystemDisk
+	request.SystemDiskPerformanceLevel = systemDisk.PerformanceLevel
+	request.SystemDiskAutoSnapshotPolicyId = systemDisk.AutoSnapshotPolicyId
+	request.SystemDisk = buildRunInstancesSystemDisk(systemDisk)
 
 	imageDisks := config.AlicloudImageConfig.ECSImagesDiskMappings
 	var dataDisks []ecs.RunInstancesDataDisk
@@ -208,9 +277,7 @@ func (s *stepCreateAlicloudInstance) buildCreateInstanceRequest(state multistep.
 		dataDisk.Description = imageDisk.Description
 		dataDisk.DeleteWithInstance = strconv.FormatBool(imageDisk.DeleteWithInstance)
 		dataDisk.Device = imageDisk.Device
-		if imageDisk.Encrypted != confighelper.TriUnset {
-			dataDisk.Encrypted = strconv.FormatBool(imageDisk.Encrypted.True())
-		}
+		setRunInstancesDataDiskOptions(&dataDisk, imageDisk)
 
 		dataDisks = append(dataDisks, dataDisk)
 	}
@@ -231,6 +298,10 @@ func (s *stepCreateAlicloudInstance) getUserData(state multistep.StateBag) (stri
 		userData = string(data)
 	}
 
+	if userData == "" {
+		userData = s.WinRMBootstrapUserData
+	}
+
 	if userData != "" {
 		userData = base64.StdEncoding.EncodeToString([]byte(userData))
 	}
@@ -239,6 +310,63 @@ func (s *stepCreateAlicloudInstance) getUserData(state multistep.StateBag) (stri
 
 }
 
+// setRunInstancesLaunchTemplate sets the launch template of the request. The
+// other options of the request override the values of the template.
+func setRunInstancesLaunchTemplate(request *ecs.RunInstancesRequest, config *RunConfig) {
+	request.LaunchTemplateId = config.LaunchTemplateId
+	request.LaunchTemplateName = config.LaunchTemplateName
+	if config.LaunchTemplateVersion > 0 {
+		request.LaunchTemplateVersion = requests.NewInteger(config.LaunchTemplateVersion)
+	}
+}
+
+// setRunInstancesPlacement sets the options of the request which control
+// where the instance is placed.
+func setRunInstancesPlacement(request *ecs.RunInstancesRequest, config *RunConfig) {
+	request.DeploymentSetId = config.DeploymentSetId
+	request.DedicatedHostId = config.DedicatedHostId
+	request.Tenancy = config.Tenancy
+	request.PrivateIpAddress = config.PrivateIpAddress
+	request.HostName = config.HostName
+	request.HpcClusterId = config.HpcClusterId
+	request.CreditSpecification = config.CreditSpecification
+}
+
+// buildRunInstancesSystemDisk returns the encryption and performance options
+// of the system disk.
+func buildRunInstancesSystemDisk(disk AlicloudDiskDevice) ecs.RunInstancesSystemDisk {
+	var systemDisk ecs.RunInstancesSystemDisk
+	if disk.Encrypted != confighelper.TriUnset {
+		systemDisk.Encrypted = strconv.FormatBool(disk.Encrypted.True())
+	}
+	systemDisk.KMSKeyId = disk.KMSKeyId
+	if disk.ProvisionedIops > 0 {
+		systemDisk.ProvisionedIops = strconv.Itoa(disk.ProvisionedIops)
+	}
+	if disk.BurstingEnabled {
+		systemDisk.BurstingEnabled = strconv.FormatBool(disk.BurstingEnabled)
+	}
+
+	return systemDisk
+}
+
+// setRunInstancesDataDiskOptions sets the encryption, performance and
+// automatic snapshot options of the data disk.
+func setRunInstancesDataDiskOptions(dataDisk *ecs.RunInstancesDataDisk, disk AlicloudDiskDevice) {
+	if disk.Encrypted != confighelper.TriUnset {
+		dataDisk.Encrypted = strconv.FormatBool(disk.Encrypted.True())
+	}
+	dataDisk.KMSKeyId = disk.KMSKeyId
+	dataDisk.PerformanceLevel = disk.PerformanceLevel
+	dataDisk.AutoSnapshotPolicyId = disk.AutoSnapshotPolicyId
+	if disk.ProvisionedIops > 0 {
+		dataDisk.ProvisionedIops = strconv.Itoa(disk.ProvisionedIops)
+	}
+	if disk.BurstingEnabled {
+		dataDisk.BurstingEnabled = strconv.FormatBool(disk.BurstingEnabled)
+	}
+}
+
 func buildCreateInstanceTags(tags map[string]string) *[]ecs.RunInstancesTag {
 	var ecsTags []ecs.RunInstancesTag
 
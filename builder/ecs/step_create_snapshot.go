@@ -16,7 +16,7 @@ import (
 
 type stepCreateAlicloudSnapshot struct {
 	snapshot                 *ecs.Snapshot
-	WaitSnapshotReadyTimeout int
+	WaitSnapshotReadyTimeout time.Duration
 }
 
 func (s *stepCreateAlicloudSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -49,7 +49,7 @@ func (s *stepCreateAlicloudSnapshot) Run(ctx context.Context, state multistep.St
 	// Create the alicloud snapshot
 	ui.Say(fmt.Sprintf("Creating snapshot from system disk %s: %s", disks[0].DiskId, snapshot.SnapshotId))
 
-	snapshotsResponse, err := client.WaitForSnapshotStatus(config.AlicloudRegion, snapshot.SnapshotId, SnapshotStatusAccomplished, time.Duration(s.WaitSnapshotReadyTimeout)*time.Second)
+	snapshotsResponse, err := client.WaitForSnapshotStatus(config.AlicloudRegion, snapshot.SnapshotId, SnapshotStatusAccomplished, s.WaitSnapshotReadyTimeout)
 	if err != nil {
 		_, ok := err.(errors.Error)
 		if ok {
@@ -87,6 +87,7 @@ func (s *stepCreateAlicloudSnapshot) Cleanup(state multistep.StateBag) {
 	deleteSnapshotRequest := ecs.CreateDeleteSnapshotRequest()
 	deleteSnapshotRequest.SnapshotId = s.snapshot.SnapshotId
 	if _, err := client.DeleteSnapshot(deleteSnapshotRequest); err != nil {
+		reportCleanupFailed(state, ResourceTypeSnapshot, s.snapshot.SnapshotId, err)
 		ui.Error(fmt.Sprintf("Error deleting snapshot, it may still be around: %s", err))
 		return
 	}
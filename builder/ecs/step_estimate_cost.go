@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	confighelper "github.com/hashicorp/packer-plugin-sdk/template/config"
+)
+
+type stepEstimateAlicloudCost struct {
+	RegionId                string
+	ZoneId                  string
+	IOOptimized             confighelper.Trilean
+	InternetChargeType      string
+	InternetMaxBandwidthOut int
+	SpotStrategy            string
+	MaxHourlyPrice          float64
+	GeneratedData           *packerbuilderdata.GeneratedData
+
+	startTime   time.Time
+	hourlyPrice float64
+	currency    string
+}
+
+func (s *stepEstimateAlicloudCost) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	s.startTime = time.Now()
+
+	ui.Say("Estimating build cost...")
+	priceResponse, err := client.DescribePrice(s.buildDescribePriceRequest(state))
+	if err != nil {
+		if s.MaxHourlyPrice > 0 {
+			return halt(state, err, "Error estimating build cost, which is required by max_hourly_price")
+		}
+
+		ui.Say(fmt.Sprintf("Failed to estimate build cost, continuing without it: %s", err))
+		return multistep.ActionContinue
+	}
+
+	price := priceResponse.PriceInfo.Price
+	s.hourlyPrice = price.TradePrice
+	s.currency = price.Currency
+	s.GeneratedData.Put("EstimatedHourlyPrice", formatPrice(s.hourlyPrice))
+	s.GeneratedData.Put("PriceCurrency", s.currency)
+
+	ui.Message(fmt.Sprintf("Estimated hourly price: %s %s", formatPrice(s.hourlyPrice), s.currency))
+	if excluded := excludedFromEstimate(config, state.Get("networktype").(InstanceNetWork)); len(excluded) > 0 {
+		// The guard can't be enforced when a part of the cost is unknown
+		if s.MaxHourlyPrice > 0 {
+			err := fmt.Errorf("max_hourly_price can't be enforced, the estimate excludes the %s", strings.Join(excluded, " and the "))
+			return halt(state, err, "")
+		}
+
+		ui.Message(fmt.Sprintf("The estimate excludes the %s", strings.Join(excluded, " and the ")))
+	}
+
+	if s.MaxHourlyPrice > 0 && s.hourlyPrice > s.MaxHourlyPrice {
+		err := fmt.Errorf("The estimated hourly price %s %s exceeds max_hourly_price %s",
+			formatPrice(s.hourlyPrice), s.currency, formatPrice(s.MaxHourlyPrice))
+		return halt(state, err, "")
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepEstimateAlicloudCost) Cleanup(state multistep.StateBag) {
+	if s.startTime.IsZero() {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	duration := time.Since(s.startTime).Round(time.Second)
+	s.GeneratedData.Put("BuildDuration", duration.String())
+	if s.currency == "" {
+		return
+	}
+
+	cost := estimateCost(s.hourlyPrice, duration)
+	s.GeneratedData.Put("EstimatedCost", formatPrice(cost))
+	ui.Say(fmt.Sprintf("Estimated cost of this build: %s %s for %s", formatPrice(cost), s.currency, duration))
+}
+
+func (s *stepEstimateAlicloudCost) buildDescribePriceRequest(state multistep.StateBag) *ecs.DescribePriceRequest {
+	config := state.Get("config").(*Config)
+	networkType := state.Get("networktype").(InstanceNetWork)
+
+	request := ecs.CreateDescribePriceRequest()
+	request.RegionId = s.RegionId
+	request.ZoneId = s.ZoneId
+	request.ResourceType = TagResourceInstance
+	request.InstanceType = config.InstanceType
+	request.InstanceNetworkType = string(networkType)
+	request.PriceUnit = PriceUnitHour
+	request.SpotStrategy = s.SpotStrategy
+	request.InternetChargeType = s.InternetChargeType
+	request.InternetMaxBandwidthOut = requests.Integer(convertNumber(s.InternetMaxBandwidthOut))
+
+	if s.IOOptimized.True() {
+		request.IoOptimized = IOOptimizedOptimized
+	} else if s.IOOptimized.False() {
+		request.IoOptimized = IOOptimizedNone
+	}
+
+	systemDisk := config.AlicloudImageConfig.ECSSystemDiskMapping
+	request.SystemDiskCategory = systemDisk.DiskCategory
+	request.SystemDiskSize = requests.Integer(convertNumber(systemDisk.DiskSize))
+	request.SystemDiskPerformanceLevel = systemDisk.PerformanceLevel
+
+	var dataDisks []ecs.DescribePriceDataDisk
+	for _, imageDisk := range config.AlicloudImageConfig.ECSImagesDiskMappings {
+		dataDisks = append(dataDisks, ecs.DescribePriceDataDisk{
+			Category:         imageDisk.DiskCategory,
+			Size:             convertNumber(imageDisk.DiskSize),
+			PerformanceLevel: imageDisk.PerformanceLevel,
+		})
+	}
+	if len(dataDisks) > 0 {
+		request.DataDisk = &dataDisks
+	}
+
+	return request
+}
+
+// excludedFromEstimate returns the billed resources of the build which
+// DescribePrice can't price: the EIPs are billed by the VPC service, which
+// has no pricing API.
+func excludedFromEstimate(config *Config, networkType InstanceNetWork) []string {
+	var excluded []string
+	if networkType == InstanceNetworkVpc && config.AssociatePublicIpAddress && config.EIPId == "" {
+		excluded = append(excluded, "EIP of the instance")
+	}
+	if config.TemporaryNatGateway {
+		excluded = append(excluded, "NAT gateway with its EIP")
+	}
+
+	return excluded
+}
+
+func estimateCost(hourlyPrice float64, duration time.Duration) float64 {
+	return hourlyPrice * duration.Hours()
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 4, 64)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+func TestStepEstimateAlicloudCost(t *testing.T) {
+	var spotStrategy string
+	client := testClientWrapper(t, func(action string, params url.Values) interface{} {
+		if action != "DescribePrice" {
+			return testAPIError("InvalidAction.NotFound")
+		}
+
+		spotStrategy = params.Get("SpotStrategy")
+		return map[string]interface{}{"PriceInfo": map[string]interface{}{"Price": map[string]interface{}{
+			"TradePrice": 0.5,
+			"Currency":   "CNY",
+		}}}
+	})
+
+	config := &Config{}
+	config.InstanceType = "ecs.g6.large"
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("networktype", InstanceNetWork(InstanceNetworkVpc))
+
+	step := &stepEstimateAlicloudCost{
+		RegionId:       "cn-test",
+		SpotStrategy:   SpotStrategyAsPriceGo,
+		MaxHourlyPrice: 1,
+		GeneratedData:  &packerbuilderdata.GeneratedData{State: state},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if spotStrategy != SpotStrategyAsPriceGo {
+		t.Fatalf("the spot strategy should be priced: %q", spotStrategy)
+	}
+
+	// The guard fails closed when a part of the cost can't be priced
+	config.AssociatePublicIpAddress = true
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err := state.Get("error").(error); !strings.Contains(err.Error(), "EIP of the instance") {
+		t.Fatalf("bad error: %s", err)
+	}
+
+	state.Remove("error")
+	step.MaxHourlyPrice = 0
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+}
@@ -6,8 +6,14 @@ package ecs
 import (
 	"context"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/kms"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 )
@@ -17,13 +23,36 @@ type stepPreValidate struct {
 	ForceDelete           bool
 }
 
+// Error code prefixes returned by a dry run which mean that the real request
+// would fail as well. Other errors usually come from resources which are not
+// created yet, such as the vswitch or the security group, and are ignored.
+var preflightFatalErrorPrefixes = []string{
+	"Forbidden",
+	"NoPermission",
+	"QuotaExceed",
+	"OperationDenied.NoStock",
+	"Zone.NotOnSale",
+	"InvalidInstanceType",
+	"InvalidAccountStatus",
+	"InvalidResourceType.NotSupported",
+}
+
 func (s *stepPreValidate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	var errs *packersdk.MultiError
 	if err := s.validateRegions(state); err != nil {
-		return halt(state, err, "")
+		errs = packersdk.MultiErrorAppend(errs, err)
 	}
 
 	if err := s.validateDestImageName(state); err != nil {
-		return halt(state, err, "")
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if err := s.validatePreflight(state); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return halt(state, errs, "")
 	}
 
 	return multistep.ActionContinue
@@ -87,4 +116,412 @@ func (s *stepPreValidate) validateDestImageName(state multistep.StateBag) error
 	return nil
 }
 
+func (s *stepPreValidate) validatePreflight(state multistep.StateBag) error {
+	ui := state.Get("ui").(packersdk.Ui)
+	config := state.Get("config").(*Config)
+
+	if !config.PreflightValidation {
+		return nil
+	}
+
+	ui.Say("Prevalidating instance creation, permissions and quotas...")
+
+	var errs *packersdk.MultiError
+	if err := s.dryRunCreateInstance(state); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if err := s.validateQuotas(state); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if !config.SkipCreateImage {
+		if err := s.dryRunCreateImage(state); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+
+		for index, destinationRegion := range config.AlicloudImageDestinationRegions {
+			if destinationRegion == config.AlicloudRegion {
+				continue
+			}
+
+			if err := s.validateCopyDestination(state, index, destinationRegion); err != nil {
+				errs = packersdk.MultiErrorAppend(errs, err)
+			}
+		}
+	}
+
+	if err := s.validateKMSKeys(state); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (s *stepPreValidate) dryRunCreateInstance(state multistep.StateBag) error {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+
+	request := ecs.CreateRunInstancesRequest()
+	request.DryRun = requests.NewBoolean(true)
+	request.RegionId = config.AlicloudRegion
+	request.InstanceType = config.InstanceType
+	request.ZoneId = config.ZoneId
+	request.VSwitchId = config.VSwitchId
+	request.SecurityGroupId = config.SecurityGroupId
+	if len(config.SecurityGroupIds) > 1 {
+		request.SecurityGroupIds = &config.SecurityGroupIds
+	} else if len(config.SecurityGroupIds) == 1 {
+		request.SecurityGroupId = config.SecurityGroupIds[0]
+	}
+	request.RamRoleName = config.RamRoleName
+	setRunInstancesLaunchTemplate(request, &config.RunConfig)
+	setRunInstancesPlacement(request, &config.RunConfig)
+	if config.AlicloudImageFamily != "" {
+		request.ImageFamily = config.AlicloudImageFamily
+	} else {
+		request.ImageId = config.AlicloudSourceImage
+	}
+
+	if config.IOOptimized.True() {
+		request.IoOptimized = IOOptimizedOptimized
+	} else if config.IOOptimized.False() {
+		request.IoOptimized = IOOptimizedNone
+	}
+
+	systemDisk := config.AlicloudImageConfig.ECSSystemDiskMapping
+	request.SystemDiskCategory = systemDisk.DiskCategory
+	request.SystemDiskSize = convertNumber(systemDisk.DiskSize)
+	request.SystemDiskPerformanceLevel = systemDisk.PerformanceLevel
+	request.SystemDiskAutoSnapshotPolicyId = systemDisk.AutoSnapshotPolicyId
+	request.SystemDisk = buildRunInstancesSystemDisk(systemDisk)
+
+	var dataDisks []ecs.RunInstancesDataDisk
+	for _, imageDisk := range config.AlicloudImageConfig.ECSImagesDiskMappings {
+		var dataDisk ecs.RunInstancesDataDisk
+		dataDisk.Category = imageDisk.DiskCategory
+		dataDisk.Size = convertNumber(imageDisk.DiskSize)
+		dataDisk.SnapshotId = imageDisk.SnapshotId
+		setRunInstancesDataDiskOptions(&dataDisk, imageDisk)
+		dataDisks = append(dataDisks, dataDisk)
+	}
+	request.DataDisk = &dataDisks
+
+	_, err := client.RunInstances(request)
+	return evalDryRunError("RunInstances", err)
+}
+
+// dryRunCreateImage checks the permission to create the image. CreateImage
+// doesn't support DryRun, so the request is sent without a source instance or
+// snapshot, which can't create anything and only reports the RAM denials.
+func (s *stepPreValidate) dryRunCreateImage(state multistep.StateBag) error {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+
+	request := ecs.CreateCreateImageRequest()
+	request.RegionId = config.AlicloudRegion
+	request.ImageName = config.AlicloudImageName
+	request.ResourceGroupId = config.AlicloudResourceGroupId
+	request.QueryParams["DryRun"] = "true"
+
+	_, err := client.CreateImage(request)
+	return evalDryRunError("CreateImage", err)
+}
+
+// validateCopyDestination checks that the image can be copied into the
+// destination region: the region must be valid, the copy permitted and the
+// image name free. Like CreateImage, CopyImage doesn't support DryRun, so the
+// request is sent without a source image.
+func (s *stepPreValidate) validateCopyDestination(state multistep.StateBag, index int, destinationRegion string) error {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+
+	if err := config.ValidateRegion(destinationRegion); err != nil {
+		return fmt.Errorf("Error validating destination region %s: %s", destinationRegion, err)
+	}
+
+	var errs *packersdk.MultiError
+	request := ecs.CreateCopyImageRequest()
+	request.RegionId = config.AlicloudRegion
+	request.DestinationRegionId = destinationRegion
+	request.ResourceGroupId = config.AlicloudResourceGroupId
+	request.QueryParams["DryRun"] = "true"
+	_, err := client.CopyImage(request)
+	if err := evalDryRunError(fmt.Sprintf("CopyImage to %s", destinationRegion), err); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if !s.ForceDelete && index < len(config.AlicloudImageDestinationNames) {
+		if err := s.validateDestinationImageName(state, destinationRegion, config.AlicloudImageDestinationNames[index]); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (s *stepPreValidate) validateDestinationImageName(state multistep.StateBag, destinationRegion, destinationName string) error {
+	client := state.Get("client").(*ClientWrapper)
+
+	describeImagesRequest := ecs.CreateDescribeImagesRequest()
+	describeImagesRequest.RegionId = destinationRegion
+	describeImagesRequest.ImageName = destinationName
+	describeImagesRequest.Status = ImageStatusQueried
+
+	imagesResponse, err := client.DescribeImages(describeImagesRequest)
+	if err != nil {
+		return fmt.Errorf("Error querying alicloud image in %s: %s", destinationRegion, err)
+	}
+
+	if images := imagesResponse.Images.Image; len(images) > 0 {
+		return fmt.Errorf("Error: Image Name: '%s' is used by an existing alicloud image in %s: %s",
+			images[0].ImageName, destinationRegion, images[0].ImageId)
+	}
+
+	return nil
+}
+
+// validateKMSKeys checks that the KMS keys used to encrypt the disks and the
+// image copies exist in their region and are enabled. Like the quotas,
+// missing permissions are only reported as a warning.
+func (s *stepPreValidate) validateKMSKeys(state multistep.StateBag) error {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	var errs *packersdk.MultiError
+	for _, key := range requestedKMSKeys(config) {
+		request := kms.CreateDescribeKeyRequest()
+		request.RegionId = key.region
+		request.KeyId = key.id
+
+		response, err := client.KmsClient.DescribeKey(request)
+		if e, ok := err.(errors.Error); ok && e.ErrorCode() == KMSKeyNotFoundErrorCode {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("The KMS key %s doesn't exist in region %s", key.id, key.region))
+			continue
+		}
+		if isPermissionError(err) {
+			ui.Error(fmt.Sprintf("Warning: skipping the KMS key validation, the keys can't be queried: %s", err))
+			return nil
+		}
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Error querying KMS key %s in %s: %s", key.id, key.region, err))
+			continue
+		}
+
+		if keyState := response.KeyMetadata.KeyState; keyState != KMSKeyStateEnabled {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("The KMS key %s in region %s is %s", key.id, key.region, keyState))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+type kmsKey struct {
+	region string
+	id     string
+}
+
+// requestedKMSKeys returns the KMS keys used by the disks of the instance and
+// by the image copies, once each.
+func requestedKMSKeys(config *Config) []kmsKey {
+	var keys []kmsKey
+	seen := make(map[kmsKey]bool)
+	add := func(region, id string) {
+		key := kmsKey{region: region, id: id}
+		if id == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	add(config.AlicloudRegion, config.AlicloudKMSKeyId)
+	add(config.AlicloudRegion, config.ECSSystemDiskMapping.KMSKeyId)
+	for _, disk := range config.ECSImagesDiskMappings {
+		add(config.AlicloudRegion, disk.KMSKeyId)
+	}
+
+	if !config.SkipCreateImage {
+		for index, destinationRegion := range config.AlicloudImageDestinationRegions {
+			if index < len(config.AlicloudKMSKeyCopyIds) {
+				add(destinationRegion, config.AlicloudKMSKeyCopyIds[index])
+			}
+		}
+	}
+
+	return keys
+}
+
+// validateQuotas checks the quotas of the account. The build itself doesn't
+// need the permissions to query them, so missing permissions are only
+// reported as a warning.
+func (s *stepPreValidate) validateQuotas(state multistep.StateBag) error {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	describeInstanceTypesRequest := ecs.CreateDescribeInstanceTypesRequest()
+	describeInstanceTypesRequest.RegionId = config.AlicloudRegion
+	describeInstanceTypesRequest.InstanceTypes = &[]string{config.InstanceType}
+	instanceTypesResponse, err := client.DescribeInstanceTypes(describeInstanceTypesRequest)
+	if isPermissionError(err) {
+		ui.Error(fmt.Sprintf("Warning: skipping the quota validation, the instance types can't be queried: %s", err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error querying instance type %s: %s", config.InstanceType, err)
+	}
+
+	instanceTypes := instanceTypesResponse.InstanceTypes.InstanceType
+	if len(instanceTypes) == 0 {
+		return fmt.Errorf("The instance type %s isn't available in region %s", config.InstanceType, config.AlicloudRegion)
+	}
+
+	describeAccountAttributesRequest := ecs.CreateDescribeAccountAttributesRequest()
+	describeAccountAttributesRequest.RegionId = config.AlicloudRegion
+	describeAccountAttributesRequest.ZoneId = config.ZoneId
+	describeAccountAttributesRequest.AttributeName = &[]string{
+		AccountAttributeMaxPostpaidVcpu,
+		AccountAttributeUsedPostpaidVcpu,
+		AccountAttributeMaxPostpaidDiskSize,
+		AccountAttributeUsedPostpaidDiskSize,
+	}
+	attributesResponse, err := client.DescribeAccountAttributes(describeAccountAttributesRequest)
+	if isPermissionError(err) {
+		ui.Error(fmt.Sprintf("Warning: skipping the quota validation, the account attributes can't be queried: %s", err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error querying account attributes: %s", err)
+	}
+
+	return checkAccountQuotas(attributesResponse.AccountAttributeItems.AccountAttributeItem,
+		instanceTypes[0].CpuCoreCount, requestedDiskSizes(&config.AlicloudImageConfig))
+}
+
+// checkAccountQuotas reports every quota which is too small for the build
+// instance and its disks.
+func checkAccountQuotas(items []ecs.AccountAttributeItem, vcpus int, diskSizes map[string]int) error {
+	var errs *packersdk.MultiError
+
+	isPostPaid := func(item ecs.ValueItem) bool {
+		return item.InstanceChargeType == "" || item.InstanceChargeType == InstanceChargeTypePostPaid
+	}
+	maxVcpus, maxFound := sumAccountAttribute(items, AccountAttributeMaxPostpaidVcpu, isPostPaid)
+	usedVcpus, _ := sumAccountAttribute(items, AccountAttributeUsedPostpaidVcpu, isPostPaid)
+	if maxFound && usedVcpus+vcpus > maxVcpus {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("vCPU quota exceeded: %d of %d pay-as-you-go vCPUs are used "+
+			"and the instance requires %d more", usedVcpus, maxVcpus, vcpus))
+	}
+
+	for category, size := range diskSizes {
+		isCategory := func(item ecs.ValueItem) bool {
+			return item.DiskCategory == category
+		}
+		maxSize, maxFound := sumAccountAttribute(items, AccountAttributeMaxPostpaidDiskSize, isCategory)
+		usedSize, _ := sumAccountAttribute(items, AccountAttributeUsedPostpaidDiskSize, isCategory)
+		if maxFound && usedSize+size > maxSize {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("Disk capacity quota of %s exceeded: %d of %d GiB are used "+
+				"and the instance requires %d GiB more", category, usedSize, maxSize, size))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func sumAccountAttribute(items []ecs.AccountAttributeItem, name string, filter func(ecs.ValueItem) bool) (int, bool) {
+	total := 0
+	found := false
+	for _, item := range items {
+		if item.AttributeName != name {
+			continue
+		}
+
+		for _, value := range item.AttributeValues.ValueItem {
+			if !filter(value) {
+				continue
+			}
+
+			count, err := strconv.Atoi(value.Value)
+			if err != nil {
+				log.Printf("[DEBUG] Ignoring non-numeric value %q of account attribute %s", value.Value, name)
+				continue
+			}
+
+			total += count
+			found = true
+		}
+	}
+
+	return total, found
+}
+
+// requestedDiskSizes returns the disk capacity in GiB requested for each disk
+// category. Disks without an explicit category or size are left out since
+// their final size is only known by the API.
+func requestedDiskSizes(c *AlicloudImageConfig) map[string]int {
+	sizes := make(map[string]int)
+
+	disks := append([]AlicloudDiskDevice{c.ECSSystemDiskMapping}, c.ECSImagesDiskMappings...)
+	for _, disk := range disks {
+		if disk.DiskCategory == "" || disk.DiskSize <= 0 {
+			continue
+		}
+		sizes[disk.DiskCategory] += disk.DiskSize
+	}
+
+	return sizes
+}
+
+func evalDryRunError(action string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	e, ok := err.(errors.Error)
+	if !ok {
+		return fmt.Errorf("%s dry run failed: %s", action, err)
+	}
+
+	if e.ErrorCode() == DryRunOperationErrorCode {
+		return nil
+	}
+
+	for _, prefix := range preflightFatalErrorPrefixes {
+		if strings.HasPrefix(e.ErrorCode(), prefix) {
+			return fmt.Errorf("%s dry run failed: %s: %s", action, e.ErrorCode(), e.Message())
+		}
+	}
+
+	log.Printf("[DEBUG] Ignoring %s dry run error: %s", action, err)
+	return nil
+}
+
+// isPermissionError returns whether the request was denied by RAM.
+func isPermissionError(err error) bool {
+	e, ok := err.(errors.Error)
+	if !ok {
+		return false
+	}
+
+	return strings.HasPrefix(e.ErrorCode(), "Forbidden") || strings.HasPrefix(e.ErrorCode(), "NoPermission")
+}
+
 func (s *stepPreValidate) Cleanup(multistep.StateBag) {}
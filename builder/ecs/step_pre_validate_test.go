@@ -0,0 +1,223 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testServerError(code string) error {
+	return errors.NewServerError(400, fmt.Sprintf(`{"Code": "%s", "Message": "test"}`, code), "")
+}
+
+func TestEvalDryRunError(t *testing.T) {
+	if err := evalDryRunError("RunInstances", nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	if err := evalDryRunError("RunInstances", testServerError(DryRunOperationErrorCode)); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	if err := evalDryRunError("RunInstances", testServerError("InvalidVSwitchId.Missing")); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	for _, code := range []string{"Forbidden.RAM", "QuotaExceed.PostPaidInstance", "OperationDenied.NoStock"} {
+		if err := evalDryRunError("RunInstances", testServerError(code)); err == nil {
+			t.Fatalf("should have err for %s", code)
+		}
+	}
+
+	if err := evalDryRunError("RunInstances", fmt.Errorf("connection refused")); err == nil {
+		t.Fatal("should have err")
+	}
+}
+
+func testAccountAttributes(vcpuMax, vcpuUsed, diskMax, diskUsed string) []ecs.AccountAttributeItem {
+	return []ecs.AccountAttributeItem{
+		{
+			AttributeName: AccountAttributeMaxPostpaidVcpu,
+			AttributeValues: ecs.AttributeValues{ValueItem: []ecs.ValueItem{
+				{Value: vcpuMax, InstanceChargeType: InstanceChargeTypePostPaid},
+			}},
+		},
+		{
+			AttributeName: AccountAttributeUsedPostpaidVcpu,
+			AttributeValues: ecs.AttributeValues{ValueItem: []ecs.ValueItem{
+				{Value: vcpuUsed, InstanceChargeType: InstanceChargeTypePostPaid},
+			}},
+		},
+		{
+			AttributeName: AccountAttributeMaxPostpaidDiskSize,
+			AttributeValues: ecs.AttributeValues{ValueItem: []ecs.ValueItem{
+				{Value: diskMax, DiskCategory: "cloud_essd"},
+			}},
+		},
+		{
+			AttributeName: AccountAttributeUsedPostpaidDiskSize,
+			AttributeValues: ecs.AttributeValues{ValueItem: []ecs.ValueItem{
+				{Value: diskUsed, DiskCategory: "cloud_essd"},
+			}},
+		},
+	}
+}
+
+func TestCheckAccountQuotas(t *testing.T) {
+	diskSizes := map[string]int{"cloud_essd": 100}
+
+	if err := checkAccountQuotas(testAccountAttributes("100", "10", "1000", "100"), 4, diskSizes); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	if err := checkAccountQuotas(testAccountAttributes("100", "98", "1000", "100"), 4, diskSizes); err == nil {
+		t.Fatal("should have vCPU quota err")
+	}
+
+	if err := checkAccountQuotas(testAccountAttributes("100", "10", "1000", "950"), 4, diskSizes); err == nil {
+		t.Fatal("should have disk quota err")
+	}
+
+	err := checkAccountQuotas(testAccountAttributes("100", "98", "1000", "950"), 4, diskSizes)
+	if err == nil {
+		t.Fatal("should have err")
+	}
+	if errs, ok := err.(*packersdk.MultiError); !ok || len(errs.Errors) != 2 {
+		t.Fatalf("should report both quotas, got: %s", err)
+	}
+
+	if err := checkAccountQuotas(nil, 4, diskSizes); err != nil {
+		t.Fatalf("unknown quotas shouldn't fail: %s", err)
+	}
+}
+
+func TestRequestedDiskSizes(t *testing.T) {
+	c := testAlicloudImageConfig()
+	c.ECSSystemDiskMapping = AlicloudDiskDevice{DiskCategory: "cloud_essd", DiskSize: 40}
+	c.ECSImagesDiskMappings = []AlicloudDiskDevice{
+		{DiskCategory: "cloud_essd", DiskSize: 100},
+		{DiskCategory: "cloud_efficiency", DiskSize: 50},
+		{DiskSize: 20},
+	}
+
+	sizes := requestedDiskSizes(c)
+	if len(sizes) != 2 || sizes["cloud_essd"] != 140 || sizes["cloud_efficiency"] != 50 {
+		t.Fatalf("invalid value: %v", sizes)
+	}
+}
+
+func TestIsPermissionError(t *testing.T) {
+	for _, code := range []string{"Forbidden.RAM", "NoPermission"} {
+		if !isPermissionError(testServerError(code)) {
+			t.Fatalf("%s should be a permission error", code)
+		}
+	}
+
+	for _, err := range []error{nil, testServerError("Throttling"), fmt.Errorf("connection refused")} {
+		if isPermissionError(err) {
+			t.Fatalf("%v shouldn't be a permission error", err)
+		}
+	}
+}
+
+func TestStepPreValidate_validatePreflight(t *testing.T) {
+	var copyRegions []string
+	client := testClientWrapper(t, func(action string, params url.Values) interface{} {
+		switch action {
+		case "RunInstances":
+			return testAPIError(DryRunOperationErrorCode)
+		case "DescribeInstanceTypes":
+			return testAPIError("Forbidden.RAM")
+		case "CreateImage":
+			return testAPIError("MissingParameter")
+		case "DescribeRegions":
+			return map[string]interface{}{"Regions": map[string]interface{}{"Region": []map[string]string{
+				{"RegionId": "cn-test"}, {"RegionId": "cn-dest"}, {"RegionId": "cn-other"},
+			}}}
+		case "CopyImage":
+			if params.Get("ImageId") != "" || params.Get("DryRun") != "true" {
+				t.Errorf("CopyImage shouldn't copy an image: %v", params)
+			}
+			copyRegions = append(copyRegions, params.Get("DestinationRegionId"))
+			if params.Get("DestinationRegionId") == "cn-dest" {
+				return testAPIError("Forbidden.RAM")
+			}
+			return testAPIError("MissingParameter")
+		case "DescribeImages":
+			return map[string]interface{}{"Images": map[string]interface{}{"Image": []interface{}{}}}
+		case "DescribeKey":
+			switch params.Get("KeyId") {
+			case "key-disabled":
+				return map[string]interface{}{"KeyMetadata": map[string]string{"KeyId": "key-disabled", "KeyState": "Disabled"}}
+			case "key-missing":
+				return testAPIError(KMSKeyNotFoundErrorCode)
+			}
+			return map[string]interface{}{"KeyMetadata": map[string]string{"KeyId": params.Get("KeyId"), "KeyState": KMSKeyStateEnabled}}
+		default:
+			return testAPIError("InvalidAction.NotFound")
+		}
+	})
+
+	config := &Config{}
+	config.client = client
+	config.AlicloudRegion = "cn-test"
+	config.PreflightValidation = true
+	config.InstanceType = "ecs.g6.large"
+	config.AlicloudImageName = "packer-test"
+	config.AlicloudImageDestinationRegions = []string{"cn-dest", "cn-invalid", "cn-other"}
+	config.AlicloudImageDestinationNames = []string{"packer-dest", "packer-invalid", "packer-other"}
+	config.AlicloudKMSKeyId = "key-source"
+	config.AlicloudKMSKeyCopyIds = []string{"key-disabled", "key-missing", "key-source"}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+
+	step := &stepPreValidate{}
+	err := step.validatePreflight(state)
+	errs, ok := err.(*packersdk.MultiError)
+	if !ok {
+		t.Fatalf("should have errors: %v", err)
+	}
+
+	var messages []string
+	for _, err := range flattenMultiError(errs) {
+		messages = append(messages, err.Error())
+	}
+	expected := []string{
+		"CopyImage to cn-dest dry run failed: Forbidden.RAM: test",
+		"Error validating destination region cn-invalid: Not a valid alicloud region: cn-invalid",
+		"The KMS key key-disabled in region cn-dest is Disabled",
+		"The KMS key key-missing doesn't exist in region cn-invalid",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Fatalf("bad errors, expected: %q, actual: %q", expected, messages)
+	}
+
+	if !reflect.DeepEqual(copyRegions, []string{"cn-dest", "cn-other"}) {
+		t.Fatalf("the copy to every valid destination region should be checked: %v", copyRegions)
+	}
+}
+
+func flattenMultiError(errs *packersdk.MultiError) []error {
+	var result []error
+	for _, err := range errs.Errors {
+		if nested, ok := err.(*packersdk.MultiError); ok {
+			result = append(result, flattenMultiError(nested)...)
+		} else {
+			result = append(result, err)
+		}
+	}
+
+	return result
+}
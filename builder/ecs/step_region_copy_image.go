@@ -20,13 +20,13 @@ type stepRegionCopyAlicloudImage struct {
 	AlicloudImageDestinationNames   []string
 	KmsKeyIds                       []string
 	RegionId                        string
-	WaitCopyingImageReadyTimeout    int
+	WaitCopyingImageReadyTimeout    time.Duration
 }
 
 func (s *stepRegionCopyAlicloudImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 
-	if config.ImageEncrypted != confighelper.TriUnset {
+	if config.EncryptImageByCopy() {
 		s.AlicloudImageDestinationRegions = append(s.AlicloudImageDestinationRegions, s.RegionId)
 		s.AlicloudImageDestinationNames = append(s.AlicloudImageDestinationNames, config.AlicloudImageName)
 		s.KmsKeyIds = append(s.KmsKeyIds, config.AlicloudKMSKeyId)
@@ -45,7 +45,7 @@ func (s *stepRegionCopyAlicloudImage) Run(ctx context.Context, state multistep.S
 
 	ui.Say(fmt.Sprintf("Coping image %s from %s...", srcImageId, s.RegionId))
 	for index, destinationRegion := range s.AlicloudImageDestinationRegions {
-		if destinationRegion == s.RegionId && config.ImageEncrypted == confighelper.TriUnset {
+		if destinationRegion == s.RegionId && !config.EncryptImageByCopy() {
 			continue
 		}
 
@@ -76,8 +76,8 @@ func (s *stepRegionCopyAlicloudImage) Run(ctx context.Context, state multistep.S
 		ui.Message(fmt.Sprintf("Copy image from %s(%s) to %s(%s)", s.RegionId, srcImageId, destinationRegion, imageResponse.ImageId))
 	}
 
-	if config.ImageEncrypted != confighelper.TriUnset {
-		if _, err := client.WaitForImageStatus(s.RegionId, alicloudImages[s.RegionId], ImageStatusAvailable, time.Duration(s.WaitCopyingImageReadyTimeout)*time.Second); err != nil {
+	if config.EncryptImageByCopy() {
+		if _, err := client.WaitForImageStatus(s.RegionId, alicloudImages[s.RegionId], ImageStatusAvailable, s.WaitCopyingImageReadyTimeout); err != nil {
 			return halt(state, err, fmt.Sprintf("Timeout waiting image %s finish copying", alicloudImages[s.RegionId]))
 		}
 	}
@@ -109,7 +109,7 @@ func (s *stepRegionCopyAlicloudImage) Cleanup(state multistep.StateBag) {
 		cancelCopyImageRequest.RegionId = copiedRegionId
 		cancelCopyImageRequest.ImageId = copiedImageId
 		if _, err := client.CancelCopyImage(cancelCopyImageRequest); err != nil {
-
+			reportCleanupFailed(state, ResourceTypeImage, copiedImageId, err)
 			ui.Error(fmt.Sprintf("Error cancelling copy image: %v", err))
 		}
 	}
@@ -18,9 +18,16 @@ type stepRunAlicloudInstance struct {
 
 func (s *stepRunAlicloudInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 	instance := state.Get("instance").(*ecs.Instance)
 
+	// The instance is only stopped after creation when the keypair had to be
+	// attached to it
+	if instance.Status == InstanceStatusRunning {
+		return multistep.ActionContinue
+	}
+
 	startInstanceRequest := ecs.CreateStartInstanceRequest()
 	startInstanceRequest.InstanceId = instance.InstanceId
 	if _, err := client.StartInstance(startInstanceRequest); err != nil {
@@ -29,7 +36,7 @@ func (s *stepRunAlicloudInstance) Run(ctx context.Context, state multistep.State
 
 	ui.Say(fmt.Sprintf("Starting instance: %s", instance.InstanceId))
 
-	_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusRunning)
+	_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusRunning, config.Timeouts.InstanceStart)
 	if err != nil {
 		return halt(state, err, "Timeout waiting for instance to start")
 	}
@@ -47,6 +54,7 @@ func (s *stepRunAlicloudInstance) Cleanup(state multistep.StateBag) {
 
 	ui := state.Get("ui").(packersdk.Ui)
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	instance := state.Get("instance").(*ecs.Instance)
 
 	describeInstancesRequest := ecs.CreateDescribeInstancesRequest()
@@ -67,7 +75,7 @@ func (s *stepRunAlicloudInstance) Cleanup(state multistep.StateBag) {
 			return
 		}
 
-		_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusStopped)
+		_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusStopped, config.Timeouts.InstanceStop)
 		if err != nil {
 			ui.Say(fmt.Sprintf("Error stopping instance %s, it may still be around %s", instance.InstanceId, err))
 		}
@@ -0,0 +1,258 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	instanceTypesPageSize = 100
+	// The number of the smallest matching instance types of which the price
+	// is queried, to choose the cheapest one
+	maxInstanceTypePriceQueries = 10
+)
+
+var instanceTypeGenerationRegexp = regexp.MustCompile(`^ecs\.[a-z]+(\d+)`)
+
+// stepSelectAlicloudInstanceType chooses the instance type which matches
+// `instance_type_selector` among the instance types which are available in the
+// region or zone. The architecture of the source image is used unless the
+// selector sets it.
+type stepSelectAlicloudInstanceType struct {
+	RegionId string
+	ZoneId   string
+	Selector InstanceTypeSelector
+}
+
+func (s *stepSelectAlicloudInstanceType) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	ui.Say("Selecting instance type...")
+
+	architecture := s.Selector.Architecture
+	if architecture == "" {
+		architecture = state.Get("source_image").(*ecs.Image).Architecture
+	}
+
+	instanceTypes, err := s.describeInstanceTypes(client, architecture)
+	if err != nil {
+		return halt(state, err, "Error querying instance types")
+	}
+
+	available, err := s.availableInstanceTypes(client, config)
+	if err != nil {
+		return halt(state, err, "Error querying available instance types")
+	}
+
+	candidates := filterInstanceTypes(instanceTypes, available, s.Selector.Burstable.ToBoolPointer())
+	if len(candidates) == 0 {
+		location := s.RegionId
+		if s.ZoneId != "" {
+			location = s.ZoneId
+		}
+		return halt(state, fmt.Errorf("No available instance type in %s matches instance_type_selector", location), "")
+	}
+
+	sortInstanceTypes(candidates, s.Selector.OrderBy)
+	instanceType := candidates[0]
+	if s.Selector.OrderBy == InstanceTypeOrderCheapest {
+		instanceType = s.cheapestInstanceType(state, candidates)
+	}
+
+	config.InstanceType = instanceType.InstanceTypeId
+	ui.Message(fmt.Sprintf("Selected instance type: %s (%d vCPUs, %s GiB memory)", instanceType.InstanceTypeId,
+		instanceType.CpuCoreCount, strconv.FormatFloat(instanceType.MemorySize, 'f', -1, 64)))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepSelectAlicloudInstanceType) Cleanup(multistep.StateBag) {}
+
+func (s *stepSelectAlicloudInstanceType) describeInstanceTypes(client *ClientWrapper, architecture string) ([]ecs.InstanceType, error) {
+	var instanceTypes []ecs.InstanceType
+	nextToken := ""
+	for {
+		request := ecs.CreateDescribeInstanceTypesRequest()
+		request.RegionId = s.RegionId
+		request.CpuArchitecture = imageCpuArchitecture(architecture)
+		request.MaxResults = requests.NewInteger(instanceTypesPageSize)
+		request.NextToken = nextToken
+		if s.Selector.MinVCpus > 0 {
+			request.MinimumCpuCoreCount = requests.NewInteger(s.Selector.MinVCpus)
+		}
+		if s.Selector.MinMemory > 0 {
+			request.MinimumMemorySize = requests.NewFloat(s.Selector.MinMemory)
+		}
+		if len(s.Selector.InstanceTypeFamilies) > 0 {
+			request.InstanceTypeFamilies = &s.Selector.InstanceTypeFamilies
+		}
+
+		response, err := client.DescribeInstanceTypes(request)
+		if err != nil {
+			return nil, err
+		}
+
+		instanceTypes = append(instanceTypes, response.InstanceTypes.InstanceType...)
+		if response.NextToken == "" {
+			return instanceTypes, nil
+		}
+		nextToken = response.NextToken
+	}
+}
+
+// availableInstanceTypes returns the instance types which are in stock in the
+// zone, or in any zone of the region if the zone is not set.
+func (s *stepSelectAlicloudInstanceType) availableInstanceTypes(client *ClientWrapper, config *Config) (map[string]bool, error) {
+	request := ecs.CreateDescribeAvailableResourceRequest()
+	request.RegionId = s.RegionId
+	request.ZoneId = s.ZoneId
+	request.DestinationResource = DestinationResourceInstanceType
+	request.ResourceType = TagResourceInstance
+	request.InstanceChargeType = InstanceChargeTypePostPaid
+	request.SpotStrategy = config.SpotStrategy
+	request.NetworkCategory = InstanceNetworkVpc
+	if config.IOOptimized.True() {
+		request.IoOptimized = IOOptimizedOptimized
+	} else if config.IOOptimized.False() {
+		request.IoOptimized = IOOptimizedNone
+	}
+
+	response, err := client.DescribeAvailableResource(request)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]bool)
+	for _, zone := range response.AvailableZones.AvailableZone {
+		if zone.Status != ResourceStatusAvailable || zone.StatusCategory == ResourceStatusCategoryWithoutStock {
+			continue
+		}
+
+		for _, resource := range zone.AvailableResources.AvailableResource {
+			if resource.Type != DestinationResourceInstanceType {
+				continue
+			}
+
+			for _, supportedResource := range resource.SupportedResources.SupportedResource {
+				if supportedResource.Status == ResourceStatusAvailable &&
+					supportedResource.StatusCategory != ResourceStatusCategoryWithoutStock {
+					available[supportedResource.Value] = true
+				}
+			}
+		}
+	}
+
+	return available, nil
+}
+
+// cheapestInstanceType queries the hourly price of the smallest candidates,
+// which are sorted by size, and returns the cheapest one. The smallest
+// candidate is returned if no price is known.
+func (s *stepSelectAlicloudInstanceType) cheapestInstanceType(state multistep.StateBag, candidates []ecs.InstanceType) ecs.InstanceType {
+	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	networkType := state.Get("networktype").(InstanceNetWork)
+
+	cheapest := candidates[0]
+	lowestPrice := -1.0
+	for i, candidate := range candidates {
+		if i == maxInstanceTypePriceQueries {
+			break
+		}
+
+		request := ecs.CreateDescribePriceRequest()
+		request.RegionId = s.RegionId
+		request.ZoneId = s.ZoneId
+		request.ResourceType = TagResourceInstance
+		request.InstanceType = candidate.InstanceTypeId
+		request.InstanceNetworkType = string(networkType)
+		request.PriceUnit = PriceUnitHour
+		request.SpotStrategy = config.SpotStrategy
+
+		response, err := client.DescribePrice(request)
+		if err != nil {
+			ui.Message(fmt.Sprintf("Failed to query the price of instance type %s: %s", candidate.InstanceTypeId, err))
+			continue
+		}
+
+		price := response.PriceInfo.Price.TradePrice
+		if lowestPrice < 0 || price < lowestPrice {
+			cheapest = candidate
+			lowestPrice = price
+		}
+	}
+
+	return cheapest
+}
+
+// filterInstanceTypes returns the instance types which are available and
+// burstable or not as required.
+func filterInstanceTypes(instanceTypes []ecs.InstanceType, available map[string]bool, burstable *bool) []ecs.InstanceType {
+	var candidates []ecs.InstanceType
+	for _, instanceType := range instanceTypes {
+		if !available[instanceType.InstanceTypeId] {
+			continue
+		}
+
+		if burstable != nil && *burstable != (instanceType.InstanceFamilyLevel == InstanceFamilyLevelCreditEntry) {
+			continue
+		}
+
+		candidates = append(candidates, instanceType)
+	}
+
+	return candidates
+}
+
+// sortInstanceTypes sorts the instance types by size, from the smallest one.
+// When ordered by `newest`, the instance types of the latest generation come
+// first.
+func sortInstanceTypes(instanceTypes []ecs.InstanceType, orderBy string) {
+	sort.SliceStable(instanceTypes, func(i, j int) bool {
+		a, b := instanceTypes[i], instanceTypes[j]
+		if orderBy == InstanceTypeOrderNewest {
+			if generationA, generationB := instanceTypeGeneration(a), instanceTypeGeneration(b); generationA != generationB {
+				return generationA > generationB
+			}
+		}
+
+		if a.CpuCoreCount != b.CpuCoreCount {
+			return a.CpuCoreCount < b.CpuCoreCount
+		}
+		if a.MemorySize != b.MemorySize {
+			return a.MemorySize < b.MemorySize
+		}
+		return a.InstanceTypeId < b.InstanceTypeId
+	})
+}
+
+// instanceTypeGeneration returns the generation of the instance type family,
+// e.g. 7 for `ecs.g7` and `ecs.c7a`, or 0 if it is unknown.
+func instanceTypeGeneration(instanceType ecs.InstanceType) int {
+	family := instanceType.InstanceTypeFamily
+	if family == "" {
+		family = instanceType.InstanceTypeId
+	}
+
+	match := instanceTypeGenerationRegexp.FindStringSubmatch(family)
+	if match == nil {
+		return 0
+	}
+
+	generation, _ := strconv.Atoi(match[1])
+	return generation
+}
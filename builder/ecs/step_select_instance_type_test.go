@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testInstanceTypes() []ecs.InstanceType {
+	return []ecs.InstanceType{
+		{InstanceTypeId: "ecs.g6.xlarge", InstanceTypeFamily: "ecs.g6", CpuCoreCount: 4, MemorySize: 16},
+		{InstanceTypeId: "ecs.g7.large", InstanceTypeFamily: "ecs.g7", CpuCoreCount: 2, MemorySize: 8},
+		{InstanceTypeId: "ecs.t6-c1m2.large", InstanceTypeFamily: "ecs.t6", CpuCoreCount: 2, MemorySize: 4,
+			InstanceFamilyLevel: InstanceFamilyLevelCreditEntry},
+		{InstanceTypeId: "ecs.c7a.large", InstanceTypeFamily: "ecs.c7a", CpuCoreCount: 2, MemorySize: 4},
+	}
+}
+
+func instanceTypeIds(instanceTypes []ecs.InstanceType) []string {
+	var ids []string
+	for _, instanceType := range instanceTypes {
+		ids = append(ids, instanceType.InstanceTypeId)
+	}
+	return ids
+}
+
+func TestFilterInstanceTypes(t *testing.T) {
+	available := map[string]bool{
+		"ecs.g6.xlarge":     true,
+		"ecs.t6-c1m2.large": true,
+		"ecs.c7a.large":     true,
+	}
+
+	candidates := filterInstanceTypes(testInstanceTypes(), available, nil)
+	expected := []string{"ecs.g6.xlarge", "ecs.t6-c1m2.large", "ecs.c7a.large"}
+	if ids := instanceTypeIds(candidates); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("bad instance types: %v", ids)
+	}
+
+	burstable := true
+	candidates = filterInstanceTypes(testInstanceTypes(), available, &burstable)
+	if ids := instanceTypeIds(candidates); !reflect.DeepEqual(ids, []string{"ecs.t6-c1m2.large"}) {
+		t.Fatalf("bad burstable instance types: %v", ids)
+	}
+
+	burstable = false
+	candidates = filterInstanceTypes(testInstanceTypes(), available, &burstable)
+	if ids := instanceTypeIds(candidates); !reflect.DeepEqual(ids, []string{"ecs.g6.xlarge", "ecs.c7a.large"}) {
+		t.Fatalf("bad instance types which aren't burstable: %v", ids)
+	}
+}
+
+func TestSortInstanceTypes(t *testing.T) {
+	instanceTypes := testInstanceTypes()
+	sortInstanceTypes(instanceTypes, InstanceTypeOrderCheapest)
+	expected := []string{"ecs.c7a.large", "ecs.t6-c1m2.large", "ecs.g7.large", "ecs.g6.xlarge"}
+	if ids := instanceTypeIds(instanceTypes); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("bad order by size: %v", ids)
+	}
+
+	sortInstanceTypes(instanceTypes, InstanceTypeOrderNewest)
+	expected = []string{"ecs.c7a.large", "ecs.g7.large", "ecs.t6-c1m2.large", "ecs.g6.xlarge"}
+	if ids := instanceTypeIds(instanceTypes); !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("bad order by generation: %v", ids)
+	}
+}
+
+func TestStepSelectAlicloudInstanceType_sourceImageArchitecture(t *testing.T) {
+	var cpuArchitecture string
+	prices := map[string]float64{"ecs.g8y.large": 0.5, "ecs.c8y.large": 0.3}
+	client := testClientWrapper(t, func(action string, params url.Values) interface{} {
+		switch action {
+		case "DescribeInstanceTypes":
+			cpuArchitecture = params.Get("CpuArchitecture")
+			return map[string]interface{}{"InstanceTypes": map[string]interface{}{"InstanceType": []map[string]interface{}{
+				{"InstanceTypeId": "ecs.g8y.large", "CpuCoreCount": 2, "MemorySize": 8},
+				{"InstanceTypeId": "ecs.c8y.large", "CpuCoreCount": 2, "MemorySize": 4},
+			}}}
+		case "DescribeAvailableResource":
+			resources := []map[string]string{
+				{"Value": "ecs.g8y.large", "Status": ResourceStatusAvailable},
+				{"Value": "ecs.c8y.large", "Status": ResourceStatusAvailable},
+			}
+			return map[string]interface{}{"AvailableZones": map[string]interface{}{"AvailableZone": []map[string]interface{}{{
+				"Status": ResourceStatusAvailable,
+				"AvailableResources": map[string]interface{}{"AvailableResource": []map[string]interface{}{{
+					"Type":               DestinationResourceInstanceType,
+					"SupportedResources": map[string]interface{}{"SupportedResource": resources},
+				}}},
+			}}}}
+		case "DescribePrice":
+			return map[string]interface{}{"PriceInfo": map[string]interface{}{"Price": map[string]float64{
+				"TradePrice": prices[params.Get("InstanceType")],
+			}}}
+		default:
+			return testAPIError("InvalidAction.NotFound")
+		}
+	})
+
+	config := &Config{}
+	state := new(multistep.BasicStateBag)
+	state.Put("client", client)
+	state.Put("config", config)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("networktype", InstanceNetWork(InstanceNetworkVpc))
+	state.Put("source_image", &ecs.Image{ImageId: "m-test", Architecture: ImageArchitectureArm64})
+
+	step := &stepSelectAlicloudInstanceType{
+		RegionId: "cn-test",
+		Selector: InstanceTypeSelector{MinVCpus: 2, OrderBy: InstanceTypeOrderCheapest},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v, error: %s", action, state.Get("error"))
+	}
+	if cpuArchitecture != CpuArchitectureARM {
+		t.Fatalf("the architecture of the source image should be used: %s", cpuArchitecture)
+	}
+	if config.InstanceType != "ecs.c8y.large" {
+		t.Fatalf("the cheapest instance type should be selected: %s", config.InstanceType)
+	}
+}
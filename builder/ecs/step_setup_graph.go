@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+type setupGraphNode struct {
+	Name      string
+	Step      multistep.Step
+	DependsOn []string
+}
+
+// setupGraphResult is implemented by the setup steps which change the config.
+// Since the steps run concurrently, they only publish their results to the
+// state under their own keys, and the results are applied to the config once
+// every step has finished.
+type setupGraphResult interface {
+	applyResult(state multistep.StateBag)
+}
+
+// stepSetupGraph runs the setup steps concurrently, each one as soon as the
+// steps it depends on have finished. When a step halts, the steps which have
+// not started yet are skipped, and the errors of all the halted steps are
+// reported. The steps which ran are cleaned up one by one in the reverse order
+// of their completion, so that a step is always cleaned up before the steps it
+// depends on.
+type stepSetupGraph struct {
+	Nodes []*setupGraphNode
+
+	lock     sync.Mutex
+	finished []multistep.Step
+}
+
+func (s *stepSetupGraph) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	done := make(map[string]chan struct{}, len(s.Nodes))
+	for _, node := range s.Nodes {
+		done[node.Name] = make(chan struct{})
+	}
+	for _, node := range s.Nodes {
+		for _, dependency := range node.DependsOn {
+			if _, ok := done[dependency]; !ok {
+				return halt(state, fmt.Errorf("Setup step %s depends on unknown step %s", node.Name, dependency), "")
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	halted := false
+	nodeStates := make([]*setupNodeState, len(s.Nodes))
+	var wg sync.WaitGroup
+	for i, node := range s.Nodes {
+		nodeStates[i] = &setupNodeState{StateBag: state}
+		wg.Add(1)
+		go func(node *setupGraphNode, nodeState *setupNodeState) {
+			defer wg.Done()
+			defer close(done[node.Name])
+
+			for _, dependency := range node.DependsOn {
+				<-done[dependency]
+			}
+
+			s.lock.Lock()
+			if halted || ctx.Err() != nil {
+				halted = true
+				s.lock.Unlock()
+				return
+			}
+			s.lock.Unlock()
+
+			action := node.Step.Run(ctx, nodeState)
+
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			s.finished = append(s.finished, node.Step)
+			if action == multistep.ActionHalt {
+				halted = true
+				cancel()
+			}
+		}(node, nodeStates[i])
+	}
+	wg.Wait()
+
+	if halted {
+		var errs *packersdk.MultiError
+		for _, nodeState := range nodeStates {
+			if nodeState.err != nil {
+				errs = packersdk.MultiErrorAppend(errs, nodeState.err)
+			}
+		}
+		if errs != nil && len(errs.Errors) == 1 {
+			state.Put("error", errs.Errors[0])
+		} else if errs != nil {
+			state.Put("error", errs)
+		}
+		return multistep.ActionHalt
+	}
+
+	for _, node := range s.Nodes {
+		if result, ok := node.Step.(setupGraphResult); ok {
+			result.applyResult(state)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepSetupGraph) Cleanup(state multistep.StateBag) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := len(s.finished) - 1; i >= 0; i-- {
+		s.finished[i].Cleanup(state)
+	}
+}
+
+// setupNodeState keeps the error of a setup step, so that it isn't
+// overwritten by the error of another step halting at the same time.
+type setupNodeState struct {
+	multistep.StateBag
+
+	err error
+}
+
+func (s *setupNodeState) Put(key string, value interface{}) {
+	if key == "error" {
+		s.err = value.(error)
+		return
+	}
+
+	s.StateBag.Put(key, value)
+}
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func testState(t *testing.T) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	return state
+}
+
+type testGraphEvents struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (e *testGraphEvents) add(event string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.events = append(e.events, event)
+}
+
+// testGraphStep waits for the other steps of the barrier, if any, before it
+// runs.
+type testGraphStep struct {
+	name    string
+	barrier *sync.WaitGroup
+	action  multistep.StepAction
+	events  *testGraphEvents
+}
+
+func (s *testGraphStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.barrier != nil {
+		s.barrier.Done()
+		s.barrier.Wait()
+	}
+	s.events.add("run " + s.name)
+	if s.action == multistep.ActionHalt {
+		return halt(state, fmt.Errorf("%s failed", s.name), "")
+	}
+	return s.action
+}
+
+func (s *testGraphStep) applyResult(state multistep.StateBag) {
+	s.events.add("apply " + s.name)
+}
+
+func (s *testGraphStep) Cleanup(state multistep.StateBag) {
+	s.events.add("cleanup " + s.name)
+}
+
+// testSetupGraph chains the steps, in another order than the nodes, so that
+// the order of the events is deterministic.
+func testSetupGraph(events *testGraphEvents, vpcAction multistep.StepAction) *stepSetupGraph {
+	return &stepSetupGraph{
+		Nodes: []*setupGraphNode{
+			{
+				Name:      "security_group",
+				Step:      &testGraphStep{name: "security_group", events: events},
+				DependsOn: []string{"vpc"},
+			},
+			{
+				Name:      "vpc",
+				Step:      &testGraphStep{name: "vpc", action: vpcAction, events: events},
+				DependsOn: []string{"key_pair"},
+			},
+			{
+				Name: "key_pair",
+				Step: &testGraphStep{name: "key_pair", events: events},
+			},
+		},
+	}
+}
+
+func TestStepSetupGraph_order(t *testing.T) {
+	events := &testGraphEvents{}
+	step := testSetupGraph(events, multistep.ActionContinue)
+
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	expected := []string{
+		"run key_pair",
+		"run vpc",
+		"run security_group",
+		"apply security_group",
+		"apply vpc",
+		"apply key_pair",
+		"cleanup security_group",
+		"cleanup vpc",
+		"cleanup key_pair",
+	}
+	if !reflect.DeepEqual(events.events, expected) {
+		t.Fatalf("bad events: %v", events.events)
+	}
+}
+
+func TestStepSetupGraph_halt(t *testing.T) {
+	events := &testGraphEvents{}
+	step := testSetupGraph(events, multistep.ActionHalt)
+
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	expected := []string{
+		"run key_pair",
+		"run vpc",
+		"cleanup vpc",
+		"cleanup key_pair",
+	}
+	if !reflect.DeepEqual(events.events, expected) {
+		t.Fatalf("bad events: %v", events.events)
+	}
+	if err := state.Get("error").(error); err.Error() != "vpc failed" {
+		t.Fatalf("bad error: %s", err)
+	}
+}
+
+func TestStepSetupGraph_haltErrors(t *testing.T) {
+	events := &testGraphEvents{}
+	// Both steps halt only once both have started
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+	step := &stepSetupGraph{
+		Nodes: []*setupGraphNode{
+			{
+				Name: "vpc",
+				Step: &testGraphStep{name: "vpc", barrier: barrier, action: multistep.ActionHalt, events: events},
+			},
+			{
+				Name: "key_pair",
+				Step: &testGraphStep{name: "key_pair", barrier: barrier, action: multistep.ActionHalt, events: events},
+			},
+			{
+				Name:      "security_group",
+				Step:      &testGraphStep{name: "security_group", events: events},
+				DependsOn: []string{"vpc", "key_pair"},
+			},
+		},
+	}
+
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	err := state.Get("error").(error)
+	if !strings.Contains(err.Error(), "vpc failed") || !strings.Contains(err.Error(), "key_pair failed") {
+		t.Fatalf("the errors of both steps should be reported: %s", err)
+	}
+	for _, event := range events.events {
+		if strings.HasPrefix(event, "apply") || event == "run security_group" {
+			t.Fatalf("bad events: %v", events.events)
+		}
+	}
+}
+
+func TestStepSetupGraph_unknownDependency(t *testing.T) {
+	step := &stepSetupGraph{
+		Nodes: []*setupGraphNode{
+			{
+				Name:      "vswitch",
+				Step:      &testGraphStep{name: "vswitch", events: &testGraphEvents{}},
+				DependsOn: []string{"vpc"},
+			},
+		},
+	}
+
+	state := testState(t)
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
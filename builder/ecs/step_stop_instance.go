@@ -22,6 +22,7 @@ type stepStopAlicloudInstance struct {
 
 func (s *stepStopAlicloudInstance) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	client := state.Get("client").(*ClientWrapper)
+	config := state.Get("config").(*Config)
 	instance := state.Get("instance").(*ecs.Instance)
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -38,7 +39,7 @@ func (s *stepStopAlicloudInstance) Run(ctx context.Context, state multistep.Stat
 
 	ui.Say(fmt.Sprintf("Waiting instance stopped: %s", instance.InstanceId))
 
-	_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusStopped)
+	_, err := client.WaitForInstanceStatus(instance.RegionId, instance.InstanceId, InstanceStatusStopped, config.Timeouts.InstanceStop)
 	if err != nil {
 		return halt(state, err, "Error waiting for alicloud instance to stop")
 	}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package ecs
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+const (
+	DefaultWinRMUsername   = "Administrator"
+	windowsPasswordLength  = 24
+	windowsPasswordSpecial = "()`~!@#$%^&*-+=|{}[]:;<>,.?/"
+)
+
+// winRMHttpsListener replaces the HTTPS listener of WinRM with one using a
+// self-signed certificate.
+const winRMHttpsListener = `$cert = New-SelfSignedCertificate -DnsName $env:COMPUTERNAME -CertStoreLocation Cert:\LocalMachine\My
+Get-ChildItem WSMan:\localhost\Listener | Where-Object { $_.Keys -contains "Transport=HTTPS" } | Remove-Item -Recurse -Force
+New-Item -Path WSMan:\localhost\Listener -Transport HTTPS -Address * -Port %d -CertificateThumbPrint $cert.Thumbprint -Force`
+
+// winRMBootstrapUserData returns the user data which enables WinRM over HTTPS
+// on the port of the communicator, and allows it through the Windows
+// firewall. The password is never sent over an unencrypted listener.
+func winRMBootstrapUserData(port int) string {
+	listener := fmt.Sprintf(winRMHttpsListener, port)
+
+	return fmt.Sprintf(`[powershell]
+$ErrorActionPreference = "Stop"
+
+Enable-PSRemoting -SkipNetworkProfileCheck -Force
+
+%s
+
+Set-Item WSMan:\localhost\Service\Auth\Basic -Value $true
+Set-Item WSMan:\localhost\MaxTimeoutms -Value 1800000
+
+New-NetFirewallRule -DisplayName "WinRM" -Direction Inbound -Protocol TCP -LocalPort %d -Action Allow
+Restart-Service WinRM
+`, listener, port)
+}
+
+// generateWindowsPassword returns a random password which meets the
+// complexity requirements of ECS: it contains uppercase and lowercase
+// letters, digits and special characters.
+func generateWindowsPassword() (string, error) {
+	classes := []string{
+		"ABCDEFGHJKLMNPQRSTUVWXYZ",
+		"abcdefghijkmnopqrstuvwxyz",
+		"23456789",
+		windowsPasswordSpecial,
+	}
+
+	var all string
+	for _, class := range classes {
+		all += class
+	}
+
+	password := make([]byte, windowsPasswordLength)
+	for i := range password {
+		chars := all
+		if i < len(classes) {
+			chars = classes[i]
+		}
+
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Shuffle the password, so that the characters of each class aren't
+	// always at the start of it
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+
+	return chars[i.Int64()], nil
+}
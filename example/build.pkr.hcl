@@ -16,9 +16,13 @@ source "alicloud-ecs" "test" {
   image_name           = "packer_basic"
   source_image         = "centos_7_03_64_20G_alibase_20170818.vhd"
   ssh_username         = "root"
-  instance_type        = "ecs.t5-lc1m1.small"
   internet_charge_type = "PayByTraffic"
   io_optimized         = "true"
+
+  instance_type_selector {
+    min_vcpus  = 1
+    min_memory = 1
+  }
 }
 
 build {
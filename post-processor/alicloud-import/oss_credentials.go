@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package alicloudimport
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	packerecs "github.com/hashicorp/packer-plugin-alicloud/builder/ecs"
+)
+
+// ossCredentialsProvider provides the OSS client with the current credentials
+// of the access config, so that the credentials of RAM roles are refreshed.
+// When they can't be refreshed, the last credentials are still used, since
+// they may not have expired yet, and the error is kept for the operation
+// which fails.
+type ossCredentialsProvider struct {
+	config *packerecs.AlicloudAccessConfig
+
+	lock        sync.Mutex
+	credentials packerecs.SessionCredentials
+	err         error
+}
+
+func (o *ossCredentialsProvider) GetCredentials() oss.Credentials {
+	credentials, err := o.config.SessionCredentials()
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if err != nil {
+		log.Printf("[WARN] Failed to refresh the OSS credentials, using the last ones: %s", err)
+		o.err = err
+	} else {
+		o.credentials = credentials
+		o.err = nil
+	}
+
+	return &ossCredentials{credentials: o.credentials}
+}
+
+// wrapError adds the error of the last refresh of the credentials, if it
+// failed, to the error of an OSS operation.
+func (o *ossCredentialsProvider) wrapError(err error) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if err == nil || o.err == nil {
+		return err
+	}
+	return fmt.Errorf("%s (the credentials couldn't be refreshed: %s)", err, o.err)
+}
+
+type ossCredentials struct {
+	credentials packerecs.SessionCredentials
+}
+
+func (o *ossCredentials) GetAccessKeyID() string {
+	return o.credentials.AccessKeyId
+}
+
+func (o *ossCredentials) GetAccessKeySecret() string {
+	return o.credentials.AccessKeySecret
+}
+
+func (o *ossCredentials) GetSecurityToken() string {
+	return o.credentials.SecurityToken
+}
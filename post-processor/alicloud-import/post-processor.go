@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -30,7 +31,6 @@ import (
 const (
 	Packer        = "HashiCorp-Packer"
 	BuilderId     = "packer.post-processor.alicloud-import"
-	OSSSuffix     = "oss-"
 	RAWFileFormat = "raw"
 	VHDFileFormat = "vhd"
 )
@@ -38,23 +38,10 @@ const (
 const (
 	PolicyTypeSystem        = "System"
 	NoSetRoleError          = "NoSetRoletoECSServiceAcount"
-	RoleNotExistError       = "EntityNotExist.Role"
+	RoleNotExistError       = packerecs.RamRoleNotExistError
 	DefaultImportRoleName   = "AliyunECSImageImportDefaultRole"
 	DefaultImportPolicyName = "AliyunECSImageImportRolePolicy"
-	DefaultImportRolePolicy = `{
-  "Statement": [
-    {
-      "Action": "sts:AssumeRole",
-      "Effect": "Allow",
-      "Principal": {
-        "Service": [
-          "ecs.aliyuncs.com"
-        ]
-      }
-    }
-  ],
-  "Version": "1"
-}`
+	DefaultImportRolePolicy = packerecs.RamRoleEcsTrustPolicy
 )
 
 // Configuration of this post processor
@@ -100,8 +87,9 @@ type PostProcessor struct {
 	config            Config
 	DiskDeviceMapping []ecs.DiskDeviceMapping
 
-	ossClient *oss.Client
-	ramClient *ram.Client
+	ossClient      *oss.Client
+	ossCredentials *ossCredentialsProvider
+	ramClient      *ram.Client
 }
 
 func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
@@ -133,6 +121,7 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 
 	// Check we have alicloud access variables defined somewhere
 	errs = packersdk.MultiErrorAppend(errs, p.config.AlicloudAccessConfig.Prepare(&p.config.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, p.config.Timeouts.Prepare()...)
 
 	// define all our required parameters
 	templates := map[string]*string{
@@ -151,7 +140,7 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return errs
 	}
 
-	packersdk.LogSecretFilter.Set(p.config.AlicloudAccessKey, p.config.AlicloudSecretKey)
+	packersdk.LogSecretFilter.Set(p.config.AlicloudAccessKey, p.config.AlicloudSecretKey, p.config.SecurityToken)
 	log.Println(p.config)
 	return nil
 }
@@ -196,8 +185,9 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	if err != nil {
 		return nil, false, false, fmt.Errorf("Failed to connect alicloud ecs  %s", err)
 	}
+	ecsClient.PollInterval = p.config.Timeouts.PollInterval
 
-	endpoint := getEndPoint(p.config.AlicloudRegion, p.config.OSSBucket)
+	endpoint := getEndPoint(p.config.Endpoints.OssEndpoint(p.config.AlicloudRegion), p.config.OSSBucket)
 
 	describeImagesRequest := ecs.CreateDescribeImagesRequest()
 	describeImagesRequest.RegionId = p.config.AlicloudRegion
@@ -222,7 +212,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 
 	err = bucket.PutObjectFromFile(p.config.OSSKey, source)
 	if err != nil {
-		return nil, false, false, fmt.Errorf("Failed to upload image %s: %s", source, err)
+		return nil, false, false, fmt.Errorf("Failed to upload image %s: %s", source, p.ossCredentials.wrapError(err))
 	}
 
 	ui.Say(fmt.Sprintf("Image file %s has been uploaded to OSS", source))
@@ -285,7 +275,7 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	imageId := importImageResponse.ImageId
 
 	ui.Say(fmt.Sprintf("Waiting for importing %s/%s to alicloud...", endpoint, p.config.OSSKey))
-	_, err = ecsClient.WaitForImageStatus(p.config.AlicloudRegion, imageId, packerecs.ImageStatusAvailable, time.Duration(packerecs.ALICLOUD_DEFAULT_LONG_TIMEOUT)*time.Second)
+	_, err = ecsClient.WaitForImageStatus(p.config.AlicloudRegion, imageId, packerecs.ImageStatusAvailable, p.config.Timeouts.Import)
 	if err != nil {
 		return nil, false, false, fmt.Errorf("Import image %s failed: %s", imageId, err)
 	}
@@ -303,44 +293,67 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifa
 	if !p.config.SkipClean {
 		ui.Message(fmt.Sprintf("Deleting import source %s/%s/%s", endpoint, p.config.OSSBucket, p.config.OSSKey))
 		if err = bucket.DeleteObject(p.config.OSSKey); err != nil {
-			return nil, false, false, fmt.Errorf("Failed to delete %s/%s/%s: %s", endpoint, p.config.OSSBucket, p.config.OSSKey, err)
+			return nil, false, false, fmt.Errorf("Failed to delete %s/%s/%s: %s", endpoint, p.config.OSSBucket, p.config.OSSKey,
+				p.ossCredentials.wrapError(err))
 		}
 	}
 
 	return artifact, false, false, nil
 }
 
-func (p *PostProcessor) getOssClient() *oss.Client {
+func (p *PostProcessor) getOssClient() (*oss.Client, error) {
 	if p.ossClient == nil {
 		log.Println("Creating OSS Client")
-		ossClient, _ := oss.New(getEndPoint(p.config.AlicloudRegion, ""), p.config.AlicloudAccessKey,
-			p.config.AlicloudSecretKey)
+		// The credentials are resolved the same way as the ECS client, the
+		// provider returns the current ones of the RAM role if any
+		if _, err := p.config.AlicloudAccessConfig.SessionCredentials(); err != nil {
+			return nil, err
+		}
+
+		transport, err := p.config.AlicloudAccessConfig.HTTPTransport()
+		if err != nil {
+			return nil, err
+		}
+
+		p.ossCredentials = &ossCredentialsProvider{config: &p.config.AlicloudAccessConfig}
+		ossClient, err := oss.New(getEndPoint(p.config.Endpoints.OssEndpoint(p.config.AlicloudRegion), ""), "", "",
+			oss.SetCredentialsProvider(p.ossCredentials),
+			oss.HTTPClient(&http.Client{Transport: transport}))
+		if err != nil {
+			return nil, err
+		}
 		p.ossClient = ossClient
 	}
 
-	return p.ossClient
+	return p.ossClient, nil
 }
 
-func (p *PostProcessor) getRamClient() *ram.Client {
+func (p *PostProcessor) getRamClient() (*ram.Client, error) {
 	if p.ramClient == nil {
-		ramClient, _ := ram.NewClientWithAccessKey(p.config.AlicloudRegion, p.config.AlicloudAccessKey, p.config.AlicloudSecretKey)
-		p.ramClient = ramClient
+		client, err := p.config.AlicloudAccessConfig.Client()
+		if err != nil {
+			return nil, err
+		}
+		p.ramClient = client.RamClient
 	}
 
-	return p.ramClient
+	return p.ramClient, nil
 }
 
 func (p *PostProcessor) queryOrCreateBucket(bucketName string) (*oss.Bucket, error) {
-	ossClient := p.getOssClient()
+	ossClient, err := p.getOssClient()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create OSS client: %s", err)
+	}
 
 	isExist, err := ossClient.IsBucketExist(bucketName)
 	if err != nil {
-		return nil, err
+		return nil, p.ossCredentials.wrapError(err)
 	}
 	if !isExist {
 		err = ossClient.CreateBucket(bucketName)
 		if err != nil {
-			return nil, err
+			return nil, p.ossCredentials.wrapError(err)
 		}
 	}
 	bucket, err := ossClient.Bucket(bucketName)
@@ -352,12 +365,15 @@ func (p *PostProcessor) queryOrCreateBucket(bucketName string) (*oss.Bucket, err
 }
 
 func (p *PostProcessor) prepareImportRole() error {
-	ramClient := p.getRamClient()
+	ramClient, err := p.getRamClient()
+	if err != nil {
+		return fmt.Errorf("Failed to create RAM client: %s", err)
+	}
 
 	getRoleRequest := ram.CreateGetRoleRequest()
 	getRoleRequest.SetScheme(requests.HTTPS)
 	getRoleRequest.RoleName = DefaultImportRoleName
-	_, err := ramClient.GetRole(getRoleRequest)
+	_, err = ramClient.GetRole(getRoleRequest)
 	if err == nil {
 		if e := p.updateOrAttachPolicy(); e != nil {
 			return e
@@ -368,7 +384,7 @@ func (p *PostProcessor) prepareImportRole() error {
 
 	e, ok := err.(errors.Error)
 	if !ok || e.ErrorCode() != RoleNotExistError {
-		return e
+		return err
 	}
 
 	if err := p.createRoleAndAttachPolicy(); err != nil {
@@ -380,12 +396,15 @@ func (p *PostProcessor) prepareImportRole() error {
 }
 
 func (p *PostProcessor) updateOrAttachPolicy() error {
-	ramClient := p.getRamClient()
+	ramClient, err := p.getRamClient()
+	if err != nil {
+		return fmt.Errorf("Failed to create RAM client: %s", err)
+	}
 
 	listPoliciesForRoleRequest := ram.CreateListPoliciesForRoleRequest()
 	listPoliciesForRoleRequest.SetScheme(requests.HTTPS)
 	listPoliciesForRoleRequest.RoleName = DefaultImportRoleName
-	policyListResponse, err := p.ramClient.ListPoliciesForRole(listPoliciesForRoleRequest)
+	policyListResponse, err := ramClient.ListPoliciesForRole(listPoliciesForRoleRequest)
 	if err != nil {
 		return fmt.Errorf("Failed to list policies: %s", err)
 	}
@@ -421,7 +440,10 @@ func (p *PostProcessor) updateOrAttachPolicy() error {
 }
 
 func (p *PostProcessor) createRoleAndAttachPolicy() error {
-	ramClient := p.getRamClient()
+	ramClient, err := p.getRamClient()
+	if err != nil {
+		return fmt.Errorf("Failed to create RAM client: %s", err)
+	}
 
 	createRoleRequest := ram.CreateCreateRoleRequest()
 	createRoleRequest.SetScheme(requests.HTTPS)
@@ -462,17 +484,18 @@ func (p *PostProcessor) buildImportImageRequest() *ecs.ImportImageRequest {
 	return request
 }
 
-func getEndPoint(region string, bucket string) string {
-	if bucket != "" {
-		return "https://" + bucket + "." + getOSSRegion(region) + ".aliyuncs.com"
+// getEndPoint returns the URL of the bucket on the OSS endpoint, over HTTPS
+// unless the endpoint has another scheme.
+func getEndPoint(endpoint string, bucket string) string {
+	scheme := "https://"
+	if strings.HasPrefix(endpoint, "http://") {
+		scheme = "http://"
 	}
+	host := strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
 
-	return "https://" + getOSSRegion(region) + ".aliyuncs.com"
-}
-
-func getOSSRegion(region string) string {
-	if strings.HasPrefix(region, OSSSuffix) {
-		return region
+	if bucket != "" {
+		return scheme + bucket + "." + host
 	}
-	return OSSSuffix + region
+
+	return scheme + host
 }
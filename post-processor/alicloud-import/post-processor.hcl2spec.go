@@ -12,133 +12,167 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName                   *string                      `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType                 *string                      `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion                 *string                      `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug                       *bool                        `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce                       *bool                        `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError                     *string                      `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars                    map[string]string            `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars               []string                     `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	AlicloudAccessKey                 *string                      `mapstructure:"access_key" required:"true" cty:"access_key" hcl:"access_key"`
-	AlicloudSecretKey                 *string                      `mapstructure:"secret_key" required:"true" cty:"secret_key" hcl:"secret_key"`
-	AlicloudRegion                    *string                      `mapstructure:"region" required:"true" cty:"region" hcl:"region"`
-	AlicloudRamRole                   *string                      `mapstructure:"ram_role_name" required:"true" cty:"ram_role_name" hcl:"ram_role_name"`
-	AlicloudRamRoleArn                *string                      `mapstructure:"ram_role_arn" required:"true" cty:"ram_role_arn" hcl:"ram_role_arn"`
-	AlicloudRamSessionName            *string                      `mapstructure:"ram_session_name" required:"true" cty:"ram_session_name" hcl:"ram_session_name"`
-	AlicloudSkipValidation            *bool                        `mapstructure:"skip_region_validation" required:"false" cty:"skip_region_validation" hcl:"skip_region_validation"`
-	AlicloudSkipImageValidation       *bool                        `mapstructure:"skip_image_validation" required:"false" cty:"skip_image_validation" hcl:"skip_image_validation"`
-	AlicloudProfile                   *string                      `mapstructure:"profile" required:"false" cty:"profile" hcl:"profile"`
-	AlicloudSharedCredentialsFile     *string                      `mapstructure:"shared_credentials_file" required:"false" cty:"shared_credentials_file" hcl:"shared_credentials_file"`
-	SecurityToken                     *string                      `mapstructure:"security_token" required:"false" cty:"security_token" hcl:"security_token"`
-	CustomEndpointEcs                 *string                      `mapstructure:"custom_endpoint_ecs" required:"false" cty:"custom_endpoint_ecs" hcl:"custom_endpoint_ecs"`
-	AlicloudImageName                 *string                      `mapstructure:"image_name" required:"true" cty:"image_name" hcl:"image_name"`
-	AlicloudImageVersion              *string                      `mapstructure:"image_version" required:"false" cty:"image_version" hcl:"image_version"`
-	AlicloudImageDescription          *string                      `mapstructure:"image_description" required:"false" cty:"image_description" hcl:"image_description"`
-	AlicloudResourceGroupId           *string                      `mapstructure:"resource_group_id" required:"false" cty:"resource_group_id" hcl:"resource_group_id"`
-	AlicloudImageShareAccounts        []string                     `mapstructure:"image_share_account" required:"false" cty:"image_share_account" hcl:"image_share_account"`
-	AlicloudImageUNShareAccounts      []string                     `mapstructure:"image_unshare_account" cty:"image_unshare_account" hcl:"image_unshare_account"`
-	AlicloudImageDestinationRegions   []string                     `mapstructure:"image_copy_regions" required:"false" cty:"image_copy_regions" hcl:"image_copy_regions"`
-	AlicloudImageDestinationNames     []string                     `mapstructure:"image_copy_names" required:"false" cty:"image_copy_names" hcl:"image_copy_names"`
-	ImageEncrypted                    *bool                        `mapstructure:"image_encrypted" required:"false" cty:"image_encrypted" hcl:"image_encrypted"`
-	AlicloudImageForceDelete          *bool                        `mapstructure:"image_force_delete" required:"false" cty:"image_force_delete" hcl:"image_force_delete"`
-	AlicloudImageForceDeleteSnapshots *bool                        `mapstructure:"image_force_delete_snapshots" required:"false" cty:"image_force_delete_snapshots" hcl:"image_force_delete_snapshots"`
-	AlicloudImageForceDeleteInstances *bool                        `mapstructure:"image_force_delete_instances" cty:"image_force_delete_instances" hcl:"image_force_delete_instances"`
-	AlicloudImageIgnoreDataDisks      *bool                        `mapstructure:"image_ignore_data_disks" required:"false" cty:"image_ignore_data_disks" hcl:"image_ignore_data_disks"`
-	AlicloudImageTags                 map[string]string            `mapstructure:"tags" required:"false" cty:"tags" hcl:"tags"`
-	AlicloudImageTag                  []config.FlatKeyValue        `mapstructure:"tag" required:"false" cty:"tag" hcl:"tag"`
-	ECSSystemDiskMapping              *ecs.FlatAlicloudDiskDevice  `mapstructure:"system_disk_mapping" required:"false" cty:"system_disk_mapping" hcl:"system_disk_mapping"`
-	ECSImagesDiskMappings             []ecs.FlatAlicloudDiskDevice `mapstructure:"image_disk_mappings" required:"false" cty:"image_disk_mappings" hcl:"image_disk_mappings"`
-	AlicloudTargetImageFamily         *string                      `mapstructure:"target_image_family" required:"false" cty:"target_image_family" hcl:"target_image_family"`
-	AlicloudBootMode                  *string                      `mapstructure:"boot_mode" required:"false" cty:"boot_mode" hcl:"boot_mode"`
-	AlicloudKMSKeyCopyIds             []string                     `mapstructure:"kms_key_copy_ids" required:"false" cty:"kms_key_copy_ids" hcl:"kms_key_copy_ids"`
-	AlicloudKMSKeyId                  *string                      `mapstructure:"kms_key_id" required:"false" cty:"kms_key_id" hcl:"kms_key_id"`
-	AssociatePublicIpAddress          *bool                        `mapstructure:"associate_public_ip_address" cty:"associate_public_ip_address" hcl:"associate_public_ip_address"`
-	ZoneId                            *string                      `mapstructure:"zone_id" required:"false" cty:"zone_id" hcl:"zone_id"`
-	IOOptimized                       *bool                        `mapstructure:"io_optimized" required:"false" cty:"io_optimized" hcl:"io_optimized"`
-	InstanceType                      *string                      `mapstructure:"instance_type" required:"true" cty:"instance_type" hcl:"instance_type"`
-	Description                       *string                      `mapstructure:"description" cty:"description" hcl:"description"`
-	AlicloudSourceImage               *string                      `mapstructure:"source_image" required:"true" cty:"source_image" hcl:"source_image"`
-	AlicloudImageFamily               *string                      `mapstructure:"image_family" required:"true" cty:"image_family" hcl:"image_family"`
-	ForceStopInstance                 *bool                        `mapstructure:"force_stop_instance" required:"false" cty:"force_stop_instance" hcl:"force_stop_instance"`
-	DisableStopInstance               *bool                        `mapstructure:"disable_stop_instance" required:"false" cty:"disable_stop_instance" hcl:"disable_stop_instance"`
-	RamRoleName                       *string                      `mapstructure:"ecs_ram_role_name" required:"false" cty:"ecs_ram_role_name" hcl:"ecs_ram_role_name"`
-	RunTags                           map[string]string            `mapstructure:"run_tags" required:"false" cty:"run_tags" hcl:"run_tags"`
-	SecurityGroupId                   *string                      `mapstructure:"security_group_id" required:"false" cty:"security_group_id" hcl:"security_group_id"`
-	SecurityGroupName                 *string                      `mapstructure:"security_group_name" required:"false" cty:"security_group_name" hcl:"security_group_name"`
-	SecurityEnhancementStrategy       *string                      `mapstructure:"security_enhancement_strategy" required:"false" cty:"security_enhancement_strategy" hcl:"security_enhancement_strategy"`
-	UserData                          *string                      `mapstructure:"user_data" required:"false" cty:"user_data" hcl:"user_data"`
-	UserDataFile                      *string                      `mapstructure:"user_data_file" required:"false" cty:"user_data_file" hcl:"user_data_file"`
-	VpcId                             *string                      `mapstructure:"vpc_id" required:"false" cty:"vpc_id" hcl:"vpc_id"`
-	VpcName                           *string                      `mapstructure:"vpc_name" required:"false" cty:"vpc_name" hcl:"vpc_name"`
-	CidrBlock                         *string                      `mapstructure:"vpc_cidr_block" required:"false" cty:"vpc_cidr_block" hcl:"vpc_cidr_block"`
-	VSwitchId                         *string                      `mapstructure:"vswitch_id" required:"false" cty:"vswitch_id" hcl:"vswitch_id"`
-	VSwitchName                       *string                      `mapstructure:"vswitch_name" required:"false" cty:"vswitch_name" hcl:"vswitch_name"`
-	EIPId                             *string                      `mapstructure:"eip_id" required:"false" cty:"eip_id" hcl:"eip_id"`
-	InstanceName                      *string                      `mapstructure:"instance_name" required:"false" cty:"instance_name" hcl:"instance_name"`
-	InternetChargeType                *string                      `mapstructure:"internet_charge_type" required:"false" cty:"internet_charge_type" hcl:"internet_charge_type"`
-	InternetMaxBandwidthOut           *int                         `mapstructure:"internet_max_bandwidth_out" required:"false" cty:"internet_max_bandwidth_out" hcl:"internet_max_bandwidth_out"`
-	WaitSnapshotReadyTimeout          *int                         `mapstructure:"wait_snapshot_ready_timeout" required:"false" cty:"wait_snapshot_ready_timeout" hcl:"wait_snapshot_ready_timeout"`
-	WaitCopyingImageReadyTimeout      *int                         `mapstructure:"wait_copying_image_ready_timeout" required:"false" cty:"wait_copying_image_ready_timeout" hcl:"wait_copying_image_ready_timeout"`
-	Type                              *string                      `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect                *string                      `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                           *string                      `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                           *int                         `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername                       *string                      `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword                       *string                      `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName                    *string                      `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName           *string                      `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType           *string                      `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits           *int                         `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                        []string                     `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys            *bool                        `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos                       []string                     `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile                 *string                      `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile                *string                      `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                            *bool                        `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                        *string                      `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout                    *string                      `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth                      *bool                        `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding         *bool                        `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts              *int                         `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost                    *string                      `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort                    *int                         `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth               *bool                        `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername                *string                      `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword                *string                      `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive             *bool                        `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile          *string                      `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile         *string                      `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod             *string                      `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost                      *string                      `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort                      *int                         `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername                  *string                      `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword                  *string                      `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval              *string                      `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout               *string                      `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels                  []string                     `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels                   []string                     `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey                      []byte                       `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey                     []byte                       `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                         *string                      `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword                     *string                      `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                         *string                      `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy                      *bool                        `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                         *int                         `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout                      *string                      `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL                       *bool                        `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure                     *bool                        `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM                      *bool                        `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	SSHPrivateIp                      *bool                        `mapstructure:"ssh_private_ip" required:"false" cty:"ssh_private_ip" hcl:"ssh_private_ip"`
-	SkipCreateImage                   *bool                        `mapstructure:"skip_create_image" required:"false" cty:"skip_create_image" hcl:"skip_create_image"`
-	OSSBucket                         *string                      `mapstructure:"oss_bucket_name" required:"true" cty:"oss_bucket_name" hcl:"oss_bucket_name"`
-	OSSKey                            *string                      `mapstructure:"oss_key_name" cty:"oss_key_name" hcl:"oss_key_name"`
-	SkipClean                         *bool                        `mapstructure:"skip_clean" cty:"skip_clean" hcl:"skip_clean"`
-	OSType                            *string                      `mapstructure:"image_os_type" required:"true" cty:"image_os_type" hcl:"image_os_type"`
-	Platform                          *string                      `mapstructure:"image_platform" required:"true" cty:"image_platform" hcl:"image_platform"`
-	Architecture                      *string                      `mapstructure:"image_architecture" required:"true" cty:"image_architecture" hcl:"image_architecture"`
-	Size                              *string                      `mapstructure:"image_system_size" cty:"image_system_size" hcl:"image_system_size"`
-	Format                            *string                      `mapstructure:"format" required:"true" cty:"format" hcl:"format"`
+	PackerBuildName                   *string                         `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType                 *string                         `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion                 *string                         `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug                       *bool                           `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce                       *bool                           `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError                     *string                         `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars                    map[string]string               `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars               []string                        `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	AlicloudAccessKey                 *string                         `mapstructure:"access_key" required:"true" cty:"access_key" hcl:"access_key"`
+	AlicloudSecretKey                 *string                         `mapstructure:"secret_key" required:"true" cty:"secret_key" hcl:"secret_key"`
+	AlicloudRegion                    *string                         `mapstructure:"region" required:"true" cty:"region" hcl:"region"`
+	AlicloudRamRole                   *string                         `mapstructure:"ram_role_name" required:"true" cty:"ram_role_name" hcl:"ram_role_name"`
+	AlicloudRamRoleArn                *string                         `mapstructure:"ram_role_arn" required:"true" cty:"ram_role_arn" hcl:"ram_role_arn"`
+	AlicloudRamSessionName            *string                         `mapstructure:"ram_session_name" required:"true" cty:"ram_session_name" hcl:"ram_session_name"`
+	AlicloudSkipValidation            *bool                           `mapstructure:"skip_region_validation" required:"false" cty:"skip_region_validation" hcl:"skip_region_validation"`
+	AlicloudSkipImageValidation       *bool                           `mapstructure:"skip_image_validation" required:"false" cty:"skip_image_validation" hcl:"skip_image_validation"`
+	AlicloudProfile                   *string                         `mapstructure:"profile" required:"false" cty:"profile" hcl:"profile"`
+	AlicloudSharedCredentialsFile     *string                         `mapstructure:"shared_credentials_file" required:"false" cty:"shared_credentials_file" hcl:"shared_credentials_file"`
+	SecurityToken                     *string                         `mapstructure:"security_token" required:"false" cty:"security_token" hcl:"security_token"`
+	CustomEndpointEcs                 *string                         `mapstructure:"custom_endpoint_ecs" required:"false" cty:"custom_endpoint_ecs" hcl:"custom_endpoint_ecs"`
+	Endpoints                         *ecs.FlatEndpointsConfig        `mapstructure:"endpoints" required:"false" cty:"endpoints" hcl:"endpoints"`
+	HttpProxy                         *string                         `mapstructure:"http_proxy" required:"false" cty:"http_proxy" hcl:"http_proxy"`
+	HttpsProxy                        *string                         `mapstructure:"https_proxy" required:"false" cty:"https_proxy" hcl:"https_proxy"`
+	NoProxy                           *string                         `mapstructure:"no_proxy" required:"false" cty:"no_proxy" hcl:"no_proxy"`
+	CABundle                          *string                         `mapstructure:"ca_bundle" required:"false" cty:"ca_bundle" hcl:"ca_bundle"`
+	AssumeRole                        *ecs.FlatAssumeRoleConfig       `mapstructure:"assume_role" required:"false" cty:"assume_role" hcl:"assume_role"`
+	AlicloudImageName                 *string                         `mapstructure:"image_name" required:"true" cty:"image_name" hcl:"image_name"`
+	AlicloudImageVersion              *string                         `mapstructure:"image_version" required:"false" cty:"image_version" hcl:"image_version"`
+	AlicloudImageDescription          *string                         `mapstructure:"image_description" required:"false" cty:"image_description" hcl:"image_description"`
+	AlicloudResourceGroupId           *string                         `mapstructure:"resource_group_id" required:"false" cty:"resource_group_id" hcl:"resource_group_id"`
+	AlicloudImageShareAccounts        []string                        `mapstructure:"image_share_account" required:"false" cty:"image_share_account" hcl:"image_share_account"`
+	AlicloudImageUNShareAccounts      []string                        `mapstructure:"image_unshare_account" cty:"image_unshare_account" hcl:"image_unshare_account"`
+	AlicloudImageDestinationRegions   []string                        `mapstructure:"image_copy_regions" required:"false" cty:"image_copy_regions" hcl:"image_copy_regions"`
+	AlicloudImageDestinationNames     []string                        `mapstructure:"image_copy_names" required:"false" cty:"image_copy_names" hcl:"image_copy_names"`
+	ImageEncrypted                    *bool                           `mapstructure:"image_encrypted" required:"false" cty:"image_encrypted" hcl:"image_encrypted"`
+	ImageEncryptionMode               *string                         `mapstructure:"image_encryption_mode" required:"false" cty:"image_encryption_mode" hcl:"image_encryption_mode"`
+	AlicloudImageForceDelete          *bool                           `mapstructure:"image_force_delete" required:"false" cty:"image_force_delete" hcl:"image_force_delete"`
+	AlicloudImageForceDeleteSnapshots *bool                           `mapstructure:"image_force_delete_snapshots" required:"false" cty:"image_force_delete_snapshots" hcl:"image_force_delete_snapshots"`
+	AlicloudImageForceDeleteInstances *bool                           `mapstructure:"image_force_delete_instances" cty:"image_force_delete_instances" hcl:"image_force_delete_instances"`
+	AlicloudImageIgnoreDataDisks      *bool                           `mapstructure:"image_ignore_data_disks" required:"false" cty:"image_ignore_data_disks" hcl:"image_ignore_data_disks"`
+	AlicloudImageTags                 map[string]string               `mapstructure:"tags" required:"false" cty:"tags" hcl:"tags"`
+	AlicloudImageTag                  []config.FlatKeyValue           `mapstructure:"tag" required:"false" cty:"tag" hcl:"tag"`
+	ECSSystemDiskMapping              *ecs.FlatAlicloudDiskDevice     `mapstructure:"system_disk_mapping" required:"false" cty:"system_disk_mapping" hcl:"system_disk_mapping"`
+	ECSImagesDiskMappings             []ecs.FlatAlicloudDiskDevice    `mapstructure:"image_disk_mappings" required:"false" cty:"image_disk_mappings" hcl:"image_disk_mappings"`
+	AlicloudTargetImageFamily         *string                         `mapstructure:"target_image_family" required:"false" cty:"target_image_family" hcl:"target_image_family"`
+	AlicloudBootMode                  *string                         `mapstructure:"boot_mode" required:"false" cty:"boot_mode" hcl:"boot_mode"`
+	AlicloudImageImdsSupport          *string                         `mapstructure:"imds_support" required:"false" cty:"imds_support" hcl:"imds_support"`
+	AlicloudKMSKeyCopyIds             []string                        `mapstructure:"kms_key_copy_ids" required:"false" cty:"kms_key_copy_ids" hcl:"kms_key_copy_ids"`
+	AlicloudKMSKeyId                  *string                         `mapstructure:"kms_key_id" required:"false" cty:"kms_key_id" hcl:"kms_key_id"`
+	AssociatePublicIpAddress          *bool                           `mapstructure:"associate_public_ip_address" cty:"associate_public_ip_address" hcl:"associate_public_ip_address"`
+	ZoneId                            *string                         `mapstructure:"zone_id" required:"false" cty:"zone_id" hcl:"zone_id"`
+	IOOptimized                       *bool                           `mapstructure:"io_optimized" required:"false" cty:"io_optimized" hcl:"io_optimized"`
+	InstanceType                      *string                         `mapstructure:"instance_type" required:"true" cty:"instance_type" hcl:"instance_type"`
+	Description                       *string                         `mapstructure:"description" cty:"description" hcl:"description"`
+	InstanceTypeSelector              *ecs.FlatInstanceTypeSelector   `mapstructure:"instance_type_selector" required:"false" cty:"instance_type_selector" hcl:"instance_type_selector"`
+	LaunchTemplateId                  *string                         `mapstructure:"launch_template_id" required:"false" cty:"launch_template_id" hcl:"launch_template_id"`
+	LaunchTemplateName                *string                         `mapstructure:"launch_template_name" required:"false" cty:"launch_template_name" hcl:"launch_template_name"`
+	LaunchTemplateVersion             *int                            `mapstructure:"launch_template_version" required:"false" cty:"launch_template_version" hcl:"launch_template_version"`
+	AlicloudSourceImage               *string                         `mapstructure:"source_image" required:"true" cty:"source_image" hcl:"source_image"`
+	AlicloudImageFamily               *string                         `mapstructure:"image_family" required:"true" cty:"image_family" hcl:"image_family"`
+	ForceStopInstance                 *bool                           `mapstructure:"force_stop_instance" required:"false" cty:"force_stop_instance" hcl:"force_stop_instance"`
+	DisableStopInstance               *bool                           `mapstructure:"disable_stop_instance" required:"false" cty:"disable_stop_instance" hcl:"disable_stop_instance"`
+	RamRoleName                       *string                         `mapstructure:"ecs_ram_role_name" required:"false" cty:"ecs_ram_role_name" hcl:"ecs_ram_role_name"`
+	TemporaryRamRolePolicyDocument    *string                         `mapstructure:"temporary_ram_role_policy_document" required:"false" cty:"temporary_ram_role_policy_document" hcl:"temporary_ram_role_policy_document"`
+	DeploymentSetId                   *string                         `mapstructure:"deployment_set_id" required:"false" cty:"deployment_set_id" hcl:"deployment_set_id"`
+	DedicatedHostId                   *string                         `mapstructure:"dedicated_host_id" required:"false" cty:"dedicated_host_id" hcl:"dedicated_host_id"`
+	Tenancy                           *string                         `mapstructure:"tenancy" required:"false" cty:"tenancy" hcl:"tenancy"`
+	PrivateIpAddress                  *string                         `mapstructure:"private_ip_address" required:"false" cty:"private_ip_address" hcl:"private_ip_address"`
+	HostName                          *string                         `mapstructure:"host_name" required:"false" cty:"host_name" hcl:"host_name"`
+	HpcClusterId                      *string                         `mapstructure:"hpc_cluster_id" required:"false" cty:"hpc_cluster_id" hcl:"hpc_cluster_id"`
+	CreditSpecification               *string                         `mapstructure:"credit_specification" required:"false" cty:"credit_specification" hcl:"credit_specification"`
+	MetadataOptions                   *ecs.FlatMetadataOptions        `mapstructure:"metadata_options" required:"false" cty:"metadata_options" hcl:"metadata_options"`
+	RunTags                           map[string]string               `mapstructure:"run_tags" required:"false" cty:"run_tags" hcl:"run_tags"`
+	SecurityGroupId                   *string                         `mapstructure:"security_group_id" required:"false" cty:"security_group_id" hcl:"security_group_id"`
+	SecurityGroupName                 *string                         `mapstructure:"security_group_name" required:"false" cty:"security_group_name" hcl:"security_group_name"`
+	SecurityGroupIds                  []string                        `mapstructure:"security_group_ids" required:"false" cty:"security_group_ids" hcl:"security_group_ids"`
+	SecurityGroupFilter               *ecs.FlatAlicloudResourceFilter `mapstructure:"security_group_filter" required:"false" cty:"security_group_filter" hcl:"security_group_filter"`
+	SecurityEnhancementStrategy       *string                         `mapstructure:"security_enhancement_strategy" required:"false" cty:"security_enhancement_strategy" hcl:"security_enhancement_strategy"`
+	UserData                          *string                         `mapstructure:"user_data" required:"false" cty:"user_data" hcl:"user_data"`
+	UserDataFile                      *string                         `mapstructure:"user_data_file" required:"false" cty:"user_data_file" hcl:"user_data_file"`
+	WinRMBootstrap                    *bool                           `mapstructure:"winrm_bootstrap" required:"false" cty:"winrm_bootstrap" hcl:"winrm_bootstrap"`
+	VpcId                             *string                         `mapstructure:"vpc_id" required:"false" cty:"vpc_id" hcl:"vpc_id"`
+	VpcName                           *string                         `mapstructure:"vpc_name" required:"false" cty:"vpc_name" hcl:"vpc_name"`
+	CidrBlock                         *string                         `mapstructure:"vpc_cidr_block" required:"false" cty:"vpc_cidr_block" hcl:"vpc_cidr_block"`
+	VSwitchId                         *string                         `mapstructure:"vswitch_id" required:"false" cty:"vswitch_id" hcl:"vswitch_id"`
+	VSwitchName                       *string                         `mapstructure:"vswitch_name" required:"false" cty:"vswitch_name" hcl:"vswitch_name"`
+	VSwitchCidrPrefixLength           *int                            `mapstructure:"vswitch_cidr_prefix_length" required:"false" cty:"vswitch_cidr_prefix_length" hcl:"vswitch_cidr_prefix_length"`
+	VpcFilter                         *ecs.FlatAlicloudResourceFilter `mapstructure:"vpc_filter" required:"false" cty:"vpc_filter" hcl:"vpc_filter"`
+	VSwitchFilter                     *ecs.FlatAlicloudResourceFilter `mapstructure:"vswitch_filter" required:"false" cty:"vswitch_filter" hcl:"vswitch_filter"`
+	EIPId                             *string                         `mapstructure:"eip_id" required:"false" cty:"eip_id" hcl:"eip_id"`
+	InstanceName                      *string                         `mapstructure:"instance_name" required:"false" cty:"instance_name" hcl:"instance_name"`
+	InternetChargeType                *string                         `mapstructure:"internet_charge_type" required:"false" cty:"internet_charge_type" hcl:"internet_charge_type"`
+	InternetMaxBandwidthOut           *int                            `mapstructure:"internet_max_bandwidth_out" required:"false" cty:"internet_max_bandwidth_out" hcl:"internet_max_bandwidth_out"`
+	WaitSnapshotReadyTimeout          *int                            `mapstructure:"wait_snapshot_ready_timeout" required:"false" cty:"wait_snapshot_ready_timeout" hcl:"wait_snapshot_ready_timeout"`
+	WaitCopyingImageReadyTimeout      *int                            `mapstructure:"wait_copying_image_ready_timeout" required:"false" cty:"wait_copying_image_ready_timeout" hcl:"wait_copying_image_ready_timeout"`
+	Timeouts                          *ecs.FlatTimeoutsConfig         `mapstructure:"timeouts" required:"false" cty:"timeouts" hcl:"timeouts"`
+	SpotStrategy                      *string                         `mapstructure:"spot_strategy" required:"false" cty:"spot_strategy" hcl:"spot_strategy"`
+	SpotPriceLimit                    *float64                        `mapstructure:"spot_price_limit" required:"false" cty:"spot_price_limit" hcl:"spot_price_limit"`
+	MaxHourlyPrice                    *float64                        `mapstructure:"max_hourly_price" required:"false" cty:"max_hourly_price" hcl:"max_hourly_price"`
+	PreflightValidation               *bool                           `mapstructure:"preflight_validation" required:"false" cty:"preflight_validation" hcl:"preflight_validation"`
+	BuildReportPath                   *string                         `mapstructure:"build_report_path" required:"false" cty:"build_report_path" hcl:"build_report_path"`
+	Type                              *string                         `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect                *string                         `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                           *string                         `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                           *int                            `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername                       *string                         `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword                       *string                         `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName                    *string                         `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName           *string                         `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType           *string                         `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits           *int                            `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                        []string                        `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys            *bool                           `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos                       []string                        `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile                 *string                         `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile                *string                         `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                            *bool                           `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                        *string                         `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout                    *string                         `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth                      *bool                           `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding         *bool                           `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts              *int                            `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost                    *string                         `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort                    *int                            `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth               *bool                           `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername                *string                         `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword                *string                         `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive             *bool                           `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile          *string                         `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile         *string                         `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod             *string                         `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost                      *string                         `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort                      *int                            `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername                  *string                         `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword                  *string                         `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval              *string                         `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout               *string                         `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels                  []string                        `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels                   []string                        `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey                      []byte                          `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey                     []byte                          `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                         *string                         `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword                     *string                         `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                         *string                         `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy                      *bool                           `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                         *int                            `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout                      *string                         `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL                       *bool                           `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure                     *bool                           `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM                      *bool                           `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	SSHPrivateIp                      *bool                           `mapstructure:"ssh_private_ip" required:"false" cty:"ssh_private_ip" hcl:"ssh_private_ip"`
+	TemporaryNatGateway               *bool                           `mapstructure:"temporary_nat_gateway" required:"false" cty:"temporary_nat_gateway" hcl:"temporary_nat_gateway"`
+	SkipCreateImage                   *bool                           `mapstructure:"skip_create_image" required:"false" cty:"skip_create_image" hcl:"skip_create_image"`
+	OSSBucket                         *string                         `mapstructure:"oss_bucket_name" required:"true" cty:"oss_bucket_name" hcl:"oss_bucket_name"`
+	OSSKey                            *string                         `mapstructure:"oss_key_name" cty:"oss_key_name" hcl:"oss_key_name"`
+	SkipClean                         *bool                           `mapstructure:"skip_clean" cty:"skip_clean" hcl:"skip_clean"`
+	OSType                            *string                         `mapstructure:"image_os_type" required:"true" cty:"image_os_type" hcl:"image_os_type"`
+	Platform                          *string                         `mapstructure:"image_platform" required:"true" cty:"image_platform" hcl:"image_platform"`
+	Architecture                      *string                         `mapstructure:"image_architecture" required:"true" cty:"image_architecture" hcl:"image_architecture"`
+	Size                              *string                         `mapstructure:"image_system_size" cty:"image_system_size" hcl:"image_system_size"`
+	Format                            *string                         `mapstructure:"format" required:"true" cty:"format" hcl:"format"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -153,133 +187,167 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"packer_build_name":                &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
-		"packer_builder_type":              &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
-		"packer_core_version":              &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
-		"packer_debug":                     &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
-		"packer_force":                     &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
-		"packer_on_error":                  &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
-		"packer_user_variables":            &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
-		"packer_sensitive_variables":       &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
-		"access_key":                       &hcldec.AttrSpec{Name: "access_key", Type: cty.String, Required: false},
-		"secret_key":                       &hcldec.AttrSpec{Name: "secret_key", Type: cty.String, Required: false},
-		"region":                           &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
-		"ram_role_name":                    &hcldec.AttrSpec{Name: "ram_role_name", Type: cty.String, Required: false},
-		"ram_role_arn":                     &hcldec.AttrSpec{Name: "ram_role_arn", Type: cty.String, Required: false},
-		"ram_session_name":                 &hcldec.AttrSpec{Name: "ram_session_name", Type: cty.String, Required: false},
-		"skip_region_validation":           &hcldec.AttrSpec{Name: "skip_region_validation", Type: cty.Bool, Required: false},
-		"skip_image_validation":            &hcldec.AttrSpec{Name: "skip_image_validation", Type: cty.Bool, Required: false},
-		"profile":                          &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
-		"shared_credentials_file":          &hcldec.AttrSpec{Name: "shared_credentials_file", Type: cty.String, Required: false},
-		"security_token":                   &hcldec.AttrSpec{Name: "security_token", Type: cty.String, Required: false},
-		"custom_endpoint_ecs":              &hcldec.AttrSpec{Name: "custom_endpoint_ecs", Type: cty.String, Required: false},
-		"image_name":                       &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
-		"image_version":                    &hcldec.AttrSpec{Name: "image_version", Type: cty.String, Required: false},
-		"image_description":                &hcldec.AttrSpec{Name: "image_description", Type: cty.String, Required: false},
-		"resource_group_id":                &hcldec.AttrSpec{Name: "resource_group_id", Type: cty.String, Required: false},
-		"image_share_account":              &hcldec.AttrSpec{Name: "image_share_account", Type: cty.List(cty.String), Required: false},
-		"image_unshare_account":            &hcldec.AttrSpec{Name: "image_unshare_account", Type: cty.List(cty.String), Required: false},
-		"image_copy_regions":               &hcldec.AttrSpec{Name: "image_copy_regions", Type: cty.List(cty.String), Required: false},
-		"image_copy_names":                 &hcldec.AttrSpec{Name: "image_copy_names", Type: cty.List(cty.String), Required: false},
-		"image_encrypted":                  &hcldec.AttrSpec{Name: "image_encrypted", Type: cty.Bool, Required: false},
-		"image_force_delete":               &hcldec.AttrSpec{Name: "image_force_delete", Type: cty.Bool, Required: false},
-		"image_force_delete_snapshots":     &hcldec.AttrSpec{Name: "image_force_delete_snapshots", Type: cty.Bool, Required: false},
-		"image_force_delete_instances":     &hcldec.AttrSpec{Name: "image_force_delete_instances", Type: cty.Bool, Required: false},
-		"image_ignore_data_disks":          &hcldec.AttrSpec{Name: "image_ignore_data_disks", Type: cty.Bool, Required: false},
-		"tags":                             &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String), Required: false},
-		"tag":                              &hcldec.BlockListSpec{TypeName: "tag", Nested: hcldec.ObjectSpec((*config.FlatKeyValue)(nil).HCL2Spec())},
-		"system_disk_mapping":              &hcldec.BlockSpec{TypeName: "system_disk_mapping", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudDiskDevice)(nil).HCL2Spec())},
-		"image_disk_mappings":              &hcldec.BlockListSpec{TypeName: "image_disk_mappings", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudDiskDevice)(nil).HCL2Spec())},
-		"target_image_family":              &hcldec.AttrSpec{Name: "target_image_family", Type: cty.String, Required: false},
-		"boot_mode":                        &hcldec.AttrSpec{Name: "boot_mode", Type: cty.String, Required: false},
-		"kms_key_copy_ids":                 &hcldec.AttrSpec{Name: "kms_key_copy_ids", Type: cty.List(cty.String), Required: false},
-		"kms_key_id":                       &hcldec.AttrSpec{Name: "kms_key_id", Type: cty.String, Required: false},
-		"associate_public_ip_address":      &hcldec.AttrSpec{Name: "associate_public_ip_address", Type: cty.Bool, Required: false},
-		"zone_id":                          &hcldec.AttrSpec{Name: "zone_id", Type: cty.String, Required: false},
-		"io_optimized":                     &hcldec.AttrSpec{Name: "io_optimized", Type: cty.Bool, Required: false},
-		"instance_type":                    &hcldec.AttrSpec{Name: "instance_type", Type: cty.String, Required: false},
-		"description":                      &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
-		"source_image":                     &hcldec.AttrSpec{Name: "source_image", Type: cty.String, Required: false},
-		"image_family":                     &hcldec.AttrSpec{Name: "image_family", Type: cty.String, Required: false},
-		"force_stop_instance":              &hcldec.AttrSpec{Name: "force_stop_instance", Type: cty.Bool, Required: false},
-		"disable_stop_instance":            &hcldec.AttrSpec{Name: "disable_stop_instance", Type: cty.Bool, Required: false},
-		"ecs_ram_role_name":                &hcldec.AttrSpec{Name: "ecs_ram_role_name", Type: cty.String, Required: false},
-		"run_tags":                         &hcldec.AttrSpec{Name: "run_tags", Type: cty.Map(cty.String), Required: false},
-		"security_group_id":                &hcldec.AttrSpec{Name: "security_group_id", Type: cty.String, Required: false},
-		"security_group_name":              &hcldec.AttrSpec{Name: "security_group_name", Type: cty.String, Required: false},
-		"security_enhancement_strategy":    &hcldec.AttrSpec{Name: "security_enhancement_strategy", Type: cty.String, Required: false},
-		"user_data":                        &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
-		"user_data_file":                   &hcldec.AttrSpec{Name: "user_data_file", Type: cty.String, Required: false},
-		"vpc_id":                           &hcldec.AttrSpec{Name: "vpc_id", Type: cty.String, Required: false},
-		"vpc_name":                         &hcldec.AttrSpec{Name: "vpc_name", Type: cty.String, Required: false},
-		"vpc_cidr_block":                   &hcldec.AttrSpec{Name: "vpc_cidr_block", Type: cty.String, Required: false},
-		"vswitch_id":                       &hcldec.AttrSpec{Name: "vswitch_id", Type: cty.String, Required: false},
-		"vswitch_name":                     &hcldec.AttrSpec{Name: "vswitch_name", Type: cty.String, Required: false},
-		"eip_id":                           &hcldec.AttrSpec{Name: "eip_id", Type: cty.String, Required: false},
-		"instance_name":                    &hcldec.AttrSpec{Name: "instance_name", Type: cty.String, Required: false},
-		"internet_charge_type":             &hcldec.AttrSpec{Name: "internet_charge_type", Type: cty.String, Required: false},
-		"internet_max_bandwidth_out":       &hcldec.AttrSpec{Name: "internet_max_bandwidth_out", Type: cty.Number, Required: false},
-		"wait_snapshot_ready_timeout":      &hcldec.AttrSpec{Name: "wait_snapshot_ready_timeout", Type: cty.Number, Required: false},
-		"wait_copying_image_ready_timeout": &hcldec.AttrSpec{Name: "wait_copying_image_ready_timeout", Type: cty.Number, Required: false},
-		"communicator":                     &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":          &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                         &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                         &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                     &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                     &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":                 &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":          &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":          &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":          &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                      &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":        &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":      &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":             &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":             &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                          &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                      &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":                 &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":                   &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding":     &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":           &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":                 &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":                 &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":           &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":             &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":             &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":          &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file":     &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file":     &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":         &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":                   &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":                   &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":               &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":               &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":          &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":           &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":               &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":                &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":                   &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":                  &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":                   &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":                   &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                       &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":                   &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                       &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                    &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                    &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":                   &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":                   &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
-		"ssh_private_ip":                   &hcldec.AttrSpec{Name: "ssh_private_ip", Type: cty.Bool, Required: false},
-		"skip_create_image":                &hcldec.AttrSpec{Name: "skip_create_image", Type: cty.Bool, Required: false},
-		"oss_bucket_name":                  &hcldec.AttrSpec{Name: "oss_bucket_name", Type: cty.String, Required: false},
-		"oss_key_name":                     &hcldec.AttrSpec{Name: "oss_key_name", Type: cty.String, Required: false},
-		"skip_clean":                       &hcldec.AttrSpec{Name: "skip_clean", Type: cty.Bool, Required: false},
-		"image_os_type":                    &hcldec.AttrSpec{Name: "image_os_type", Type: cty.String, Required: false},
-		"image_platform":                   &hcldec.AttrSpec{Name: "image_platform", Type: cty.String, Required: false},
-		"image_architecture":               &hcldec.AttrSpec{Name: "image_architecture", Type: cty.String, Required: false},
-		"image_system_size":                &hcldec.AttrSpec{Name: "image_system_size", Type: cty.String, Required: false},
-		"format":                           &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"packer_build_name":                  &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":                &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":                &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                       &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                       &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":                    &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":              &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":         &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"access_key":                         &hcldec.AttrSpec{Name: "access_key", Type: cty.String, Required: false},
+		"secret_key":                         &hcldec.AttrSpec{Name: "secret_key", Type: cty.String, Required: false},
+		"region":                             &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"ram_role_name":                      &hcldec.AttrSpec{Name: "ram_role_name", Type: cty.String, Required: false},
+		"ram_role_arn":                       &hcldec.AttrSpec{Name: "ram_role_arn", Type: cty.String, Required: false},
+		"ram_session_name":                   &hcldec.AttrSpec{Name: "ram_session_name", Type: cty.String, Required: false},
+		"skip_region_validation":             &hcldec.AttrSpec{Name: "skip_region_validation", Type: cty.Bool, Required: false},
+		"skip_image_validation":              &hcldec.AttrSpec{Name: "skip_image_validation", Type: cty.Bool, Required: false},
+		"profile":                            &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"shared_credentials_file":            &hcldec.AttrSpec{Name: "shared_credentials_file", Type: cty.String, Required: false},
+		"security_token":                     &hcldec.AttrSpec{Name: "security_token", Type: cty.String, Required: false},
+		"custom_endpoint_ecs":                &hcldec.AttrSpec{Name: "custom_endpoint_ecs", Type: cty.String, Required: false},
+		"endpoints":                          &hcldec.BlockSpec{TypeName: "endpoints", Nested: hcldec.ObjectSpec((*ecs.FlatEndpointsConfig)(nil).HCL2Spec())},
+		"http_proxy":                         &hcldec.AttrSpec{Name: "http_proxy", Type: cty.String, Required: false},
+		"https_proxy":                        &hcldec.AttrSpec{Name: "https_proxy", Type: cty.String, Required: false},
+		"no_proxy":                           &hcldec.AttrSpec{Name: "no_proxy", Type: cty.String, Required: false},
+		"ca_bundle":                          &hcldec.AttrSpec{Name: "ca_bundle", Type: cty.String, Required: false},
+		"assume_role":                        &hcldec.BlockSpec{TypeName: "assume_role", Nested: hcldec.ObjectSpec((*ecs.FlatAssumeRoleConfig)(nil).HCL2Spec())},
+		"image_name":                         &hcldec.AttrSpec{Name: "image_name", Type: cty.String, Required: false},
+		"image_version":                      &hcldec.AttrSpec{Name: "image_version", Type: cty.String, Required: false},
+		"image_description":                  &hcldec.AttrSpec{Name: "image_description", Type: cty.String, Required: false},
+		"resource_group_id":                  &hcldec.AttrSpec{Name: "resource_group_id", Type: cty.String, Required: false},
+		"image_share_account":                &hcldec.AttrSpec{Name: "image_share_account", Type: cty.List(cty.String), Required: false},
+		"image_unshare_account":              &hcldec.AttrSpec{Name: "image_unshare_account", Type: cty.List(cty.String), Required: false},
+		"image_copy_regions":                 &hcldec.AttrSpec{Name: "image_copy_regions", Type: cty.List(cty.String), Required: false},
+		"image_copy_names":                   &hcldec.AttrSpec{Name: "image_copy_names", Type: cty.List(cty.String), Required: false},
+		"image_encrypted":                    &hcldec.AttrSpec{Name: "image_encrypted", Type: cty.Bool, Required: false},
+		"image_encryption_mode":              &hcldec.AttrSpec{Name: "image_encryption_mode", Type: cty.String, Required: false},
+		"image_force_delete":                 &hcldec.AttrSpec{Name: "image_force_delete", Type: cty.Bool, Required: false},
+		"image_force_delete_snapshots":       &hcldec.AttrSpec{Name: "image_force_delete_snapshots", Type: cty.Bool, Required: false},
+		"image_force_delete_instances":       &hcldec.AttrSpec{Name: "image_force_delete_instances", Type: cty.Bool, Required: false},
+		"image_ignore_data_disks":            &hcldec.AttrSpec{Name: "image_ignore_data_disks", Type: cty.Bool, Required: false},
+		"tags":                               &hcldec.AttrSpec{Name: "tags", Type: cty.Map(cty.String), Required: false},
+		"tag":                                &hcldec.BlockListSpec{TypeName: "tag", Nested: hcldec.ObjectSpec((*config.FlatKeyValue)(nil).HCL2Spec())},
+		"system_disk_mapping":                &hcldec.BlockSpec{TypeName: "system_disk_mapping", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudDiskDevice)(nil).HCL2Spec())},
+		"image_disk_mappings":                &hcldec.BlockListSpec{TypeName: "image_disk_mappings", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudDiskDevice)(nil).HCL2Spec())},
+		"target_image_family":                &hcldec.AttrSpec{Name: "target_image_family", Type: cty.String, Required: false},
+		"boot_mode":                          &hcldec.AttrSpec{Name: "boot_mode", Type: cty.String, Required: false},
+		"imds_support":                       &hcldec.AttrSpec{Name: "imds_support", Type: cty.String, Required: false},
+		"kms_key_copy_ids":                   &hcldec.AttrSpec{Name: "kms_key_copy_ids", Type: cty.List(cty.String), Required: false},
+		"kms_key_id":                         &hcldec.AttrSpec{Name: "kms_key_id", Type: cty.String, Required: false},
+		"associate_public_ip_address":        &hcldec.AttrSpec{Name: "associate_public_ip_address", Type: cty.Bool, Required: false},
+		"zone_id":                            &hcldec.AttrSpec{Name: "zone_id", Type: cty.String, Required: false},
+		"io_optimized":                       &hcldec.AttrSpec{Name: "io_optimized", Type: cty.Bool, Required: false},
+		"instance_type":                      &hcldec.AttrSpec{Name: "instance_type", Type: cty.String, Required: false},
+		"description":                        &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
+		"instance_type_selector":             &hcldec.BlockSpec{TypeName: "instance_type_selector", Nested: hcldec.ObjectSpec((*ecs.FlatInstanceTypeSelector)(nil).HCL2Spec())},
+		"launch_template_id":                 &hcldec.AttrSpec{Name: "launch_template_id", Type: cty.String, Required: false},
+		"launch_template_name":               &hcldec.AttrSpec{Name: "launch_template_name", Type: cty.String, Required: false},
+		"launch_template_version":            &hcldec.AttrSpec{Name: "launch_template_version", Type: cty.Number, Required: false},
+		"source_image":                       &hcldec.AttrSpec{Name: "source_image", Type: cty.String, Required: false},
+		"image_family":                       &hcldec.AttrSpec{Name: "image_family", Type: cty.String, Required: false},
+		"force_stop_instance":                &hcldec.AttrSpec{Name: "force_stop_instance", Type: cty.Bool, Required: false},
+		"disable_stop_instance":              &hcldec.AttrSpec{Name: "disable_stop_instance", Type: cty.Bool, Required: false},
+		"ecs_ram_role_name":                  &hcldec.AttrSpec{Name: "ecs_ram_role_name", Type: cty.String, Required: false},
+		"temporary_ram_role_policy_document": &hcldec.AttrSpec{Name: "temporary_ram_role_policy_document", Type: cty.String, Required: false},
+		"deployment_set_id":                  &hcldec.AttrSpec{Name: "deployment_set_id", Type: cty.String, Required: false},
+		"dedicated_host_id":                  &hcldec.AttrSpec{Name: "dedicated_host_id", Type: cty.String, Required: false},
+		"tenancy":                            &hcldec.AttrSpec{Name: "tenancy", Type: cty.String, Required: false},
+		"private_ip_address":                 &hcldec.AttrSpec{Name: "private_ip_address", Type: cty.String, Required: false},
+		"host_name":                          &hcldec.AttrSpec{Name: "host_name", Type: cty.String, Required: false},
+		"hpc_cluster_id":                     &hcldec.AttrSpec{Name: "hpc_cluster_id", Type: cty.String, Required: false},
+		"credit_specification":               &hcldec.AttrSpec{Name: "credit_specification", Type: cty.String, Required: false},
+		"metadata_options":                   &hcldec.BlockSpec{TypeName: "metadata_options", Nested: hcldec.ObjectSpec((*ecs.FlatMetadataOptions)(nil).HCL2Spec())},
+		"run_tags":                           &hcldec.AttrSpec{Name: "run_tags", Type: cty.Map(cty.String), Required: false},
+		"security_group_id":                  &hcldec.AttrSpec{Name: "security_group_id", Type: cty.String, Required: false},
+		"security_group_name":                &hcldec.AttrSpec{Name: "security_group_name", Type: cty.String, Required: false},
+		"security_group_ids":                 &hcldec.AttrSpec{Name: "security_group_ids", Type: cty.List(cty.String), Required: false},
+		"security_group_filter":              &hcldec.BlockSpec{TypeName: "security_group_filter", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudResourceFilter)(nil).HCL2Spec())},
+		"security_enhancement_strategy":      &hcldec.AttrSpec{Name: "security_enhancement_strategy", Type: cty.String, Required: false},
+		"user_data":                          &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
+		"user_data_file":                     &hcldec.AttrSpec{Name: "user_data_file", Type: cty.String, Required: false},
+		"winrm_bootstrap":                    &hcldec.AttrSpec{Name: "winrm_bootstrap", Type: cty.Bool, Required: false},
+		"vpc_id":                             &hcldec.AttrSpec{Name: "vpc_id", Type: cty.String, Required: false},
+		"vpc_name":                           &hcldec.AttrSpec{Name: "vpc_name", Type: cty.String, Required: false},
+		"vpc_cidr_block":                     &hcldec.AttrSpec{Name: "vpc_cidr_block", Type: cty.String, Required: false},
+		"vswitch_id":                         &hcldec.AttrSpec{Name: "vswitch_id", Type: cty.String, Required: false},
+		"vswitch_name":                       &hcldec.AttrSpec{Name: "vswitch_name", Type: cty.String, Required: false},
+		"vswitch_cidr_prefix_length":         &hcldec.AttrSpec{Name: "vswitch_cidr_prefix_length", Type: cty.Number, Required: false},
+		"vpc_filter":                         &hcldec.BlockSpec{TypeName: "vpc_filter", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudResourceFilter)(nil).HCL2Spec())},
+		"vswitch_filter":                     &hcldec.BlockSpec{TypeName: "vswitch_filter", Nested: hcldec.ObjectSpec((*ecs.FlatAlicloudResourceFilter)(nil).HCL2Spec())},
+		"eip_id":                             &hcldec.AttrSpec{Name: "eip_id", Type: cty.String, Required: false},
+		"instance_name":                      &hcldec.AttrSpec{Name: "instance_name", Type: cty.String, Required: false},
+		"internet_charge_type":               &hcldec.AttrSpec{Name: "internet_charge_type", Type: cty.String, Required: false},
+		"internet_max_bandwidth_out":         &hcldec.AttrSpec{Name: "internet_max_bandwidth_out", Type: cty.Number, Required: false},
+		"wait_snapshot_ready_timeout":        &hcldec.AttrSpec{Name: "wait_snapshot_ready_timeout", Type: cty.Number, Required: false},
+		"wait_copying_image_ready_timeout":   &hcldec.AttrSpec{Name: "wait_copying_image_ready_timeout", Type: cty.Number, Required: false},
+		"timeouts":                           &hcldec.BlockSpec{TypeName: "timeouts", Nested: hcldec.ObjectSpec((*ecs.FlatTimeoutsConfig)(nil).HCL2Spec())},
+		"spot_strategy":                      &hcldec.AttrSpec{Name: "spot_strategy", Type: cty.String, Required: false},
+		"spot_price_limit":                   &hcldec.AttrSpec{Name: "spot_price_limit", Type: cty.Number, Required: false},
+		"max_hourly_price":                   &hcldec.AttrSpec{Name: "max_hourly_price", Type: cty.Number, Required: false},
+		"preflight_validation":               &hcldec.AttrSpec{Name: "preflight_validation", Type: cty.Bool, Required: false},
+		"build_report_path":                  &hcldec.AttrSpec{Name: "build_report_path", Type: cty.String, Required: false},
+		"communicator":                       &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":            &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                           &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                           &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                       &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                       &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":                   &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":            &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":            &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":            &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                        &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":          &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":        &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":               &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":               &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                            &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                        &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":                   &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                     &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":       &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":             &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":                   &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":                   &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":             &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":               &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":               &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":            &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":       &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":       &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":           &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_proxy_host":                     &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":                     &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":                 &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":                 &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":            &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_read_write_timeout":             &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":                 &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":                  &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                     &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                    &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                     &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                     &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                         &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                     &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                         &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                      &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                      &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                     &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                     &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"ssh_private_ip":                     &hcldec.AttrSpec{Name: "ssh_private_ip", Type: cty.Bool, Required: false},
+		"temporary_nat_gateway":              &hcldec.AttrSpec{Name: "temporary_nat_gateway", Type: cty.Bool, Required: false},
+		"skip_create_image":                  &hcldec.AttrSpec{Name: "skip_create_image", Type: cty.Bool, Required: false},
+		"oss_bucket_name":                    &hcldec.AttrSpec{Name: "oss_bucket_name", Type: cty.String, Required: false},
+		"oss_key_name":                       &hcldec.AttrSpec{Name: "oss_key_name", Type: cty.String, Required: false},
+		"skip_clean":                         &hcldec.AttrSpec{Name: "skip_clean", Type: cty.Bool, Required: false},
+		"image_os_type":                      &hcldec.AttrSpec{Name: "image_os_type", Type: cty.String, Required: false},
+		"image_platform":                     &hcldec.AttrSpec{Name: "image_platform", Type: cty.String, Required: false},
+		"image_architecture":                 &hcldec.AttrSpec{Name: "image_architecture", Type: cty.String, Required: false},
+		"image_system_size":                  &hcldec.AttrSpec{Name: "image_system_size", Type: cty.String, Required: false},
+		"format":                             &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
 	}
 	return s
 }